@@ -2,6 +2,8 @@
 package app
 
 import (
+	"os"
+
 	"github.com/venafi/vmware-avi-connector/internal/app/discovery"
 	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
 	"github.com/venafi/vmware-avi-connector/internal/handler/web"
@@ -30,6 +32,10 @@ func New() *fx.App {
 
 	logger.Info("VMware AVI connector starting")
 
+	if len(os.Getenv(vmwareavi.SnapshotDirectoryEnvironmentVariable)) == 0 {
+		logger.Warn("virtual service snapshots are disabled, the previous certificates of a virtual service cannot be rolled back", zap.String("environmentVariable", vmwareavi.SnapshotDirectoryEnvironmentVariable))
+	}
+
 	return app
 }
 
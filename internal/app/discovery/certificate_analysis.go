@@ -0,0 +1,138 @@
+package discovery
+
+import (
+	"bytes"
+	"crypto/dsa" //nolint:staticcheck // DSA keys are only measured to flag them as weak
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// MinimumRSAKeySize is the smallest RSA or DSA key size in bits that is not flagged as weak
+	MinimumRSAKeySize = 2048
+	// MinimumECDSAKeySize is the smallest elliptic curve key size in bits that is not flagged as weak
+	MinimumECDSAKeySize = 256
+)
+
+// CertificateMetadata is the analysis of a discovered certificate
+type CertificateMetadata struct {
+	// The hex encoded SHA-256 fingerprint of the DER encoded certificate, identical for every copy of the certificate
+	Fingerprint string `json:"fingerprint"`
+	// The public key algorithm, such as RSA or ECDSA
+	KeyAlgorithm string `json:"keyAlgorithm"`
+	// The public key size in bits
+	KeySize int `json:"keySize"`
+	// The signature algorithm, such as SHA256-RSA
+	SignatureAlgorithm string `json:"signatureAlgorithm"`
+	// The DNS names, IP addresses, email addresses and URIs of the subject alternative name extension
+	SubjectAlternativeNames []string `json:"subjectAlternativeNames"`
+	// The certificate is signed by its own key
+	SelfSigned bool `json:"selfSigned"`
+	// The public key is smaller than the minimum size of the key algorithm
+	WeakKey bool `json:"weakKey"`
+	// The certificate signature uses SHA-1
+	SHA1Signature bool `json:"sha1Signature"`
+	// The number of whole days until the certificate expires, negative for an expired certificate
+	DaysRemaining int `json:"daysRemaining"`
+}
+
+// parseCertificate will parse the first certificate of the PEM content
+func parseCertificate(content string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(content))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded certificate")
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %w", err)
+	}
+
+	return certificate, nil
+}
+
+// getFingerprint will return the hex encoded SHA-256 fingerprint of the certificate
+func getFingerprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// analyzeCertificate will describe the key, signature and validity of the certificate
+func analyzeCertificate(certificate *x509.Certificate, now time.Time) *CertificateMetadata {
+	metadata := &CertificateMetadata{
+		Fingerprint:             getFingerprint(certificate),
+		KeyAlgorithm:            certificate.PublicKeyAlgorithm.String(),
+		SignatureAlgorithm:      certificate.SignatureAlgorithm.String(),
+		SubjectAlternativeNames: getSubjectAlternativeNames(certificate),
+		SelfSigned:              isSelfSigned(certificate),
+		DaysRemaining:           int(math.Floor(certificate.NotAfter.Sub(now).Hours() / 24)),
+	}
+
+	switch key := certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		metadata.KeySize = key.N.BitLen()
+		metadata.WeakKey = metadata.KeySize < MinimumRSAKeySize
+	case *dsa.PublicKey:
+		metadata.KeySize = key.P.BitLen()
+		metadata.WeakKey = metadata.KeySize < MinimumRSAKeySize
+	case *ecdsa.PublicKey:
+		metadata.KeySize = key.Curve.Params().BitSize
+		metadata.WeakKey = metadata.KeySize < MinimumECDSAKeySize
+	case ed25519.PublicKey:
+		metadata.KeySize = 256
+	}
+
+	switch certificate.SignatureAlgorithm {
+	case x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		metadata.SHA1Signature = true
+	}
+
+	return metadata
+}
+
+func getSubjectAlternativeNames(certificate *x509.Certificate) []string {
+	names := make([]string, 0, len(certificate.DNSNames)+len(certificate.IPAddresses)+len(certificate.EmailAddresses)+len(certificate.URIs))
+
+	names = append(names, certificate.DNSNames...)
+	for _, ip := range certificate.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, certificate.EmailAddresses...)
+	for _, uri := range certificate.URIs {
+		names = append(names, uri.String())
+	}
+
+	return names
+}
+
+// isSelfSigned will check if the certificate is issued by its subject and signed by its own key, a signature using
+// an algorithm that is no longer verified, such as SHA-1, is not rejected
+func isSelfSigned(certificate *x509.Certificate) bool {
+	if !bytes.Equal(certificate.RawIssuer, certificate.RawSubject) {
+		return false
+	}
+
+	err := certificate.CheckSignature(certificate.SignatureAlgorithm, certificate.RawTBSCertificate, certificate.Signature)
+
+	var insecure x509.InsecureAlgorithmError
+	return err == nil || errors.As(err, &insecure)
+}
+
+func isExpired(certificate *x509.Certificate) bool {
+	return time.Now().After(certificate.NotAfter)
+}
+
+// isExpiringWithin will check if the certificate expires within the number of days, an expired certificate is
+// expiring within any number of days
+func isExpiringWithin(certificate *x509.Certificate, days int) bool {
+	return certificate.NotAfter.Before(time.Now().AddDate(0, 0, days))
+}
@@ -0,0 +1,168 @@
+package discovery
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testCertificate is a generated certificate with its key and PEM encoding
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         crypto.Signer
+	pem         string
+}
+
+// generateTestCertificate will create a certificate from the template, signed by the issuer or self-signed when the
+// issuer is nil. A nil key generates an ECDSA P-256 key.
+func generateTestCertificate(tb testing.TB, template *x509.Certificate, key crypto.Signer, issuer *testCertificate) *testCertificate {
+	tb.Helper()
+
+	var err error
+	if key == nil {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(tb, err)
+	}
+
+	if template.SerialNumber == nil {
+		template.SerialNumber, err = rand.Int(rand.Reader, big.NewInt(1<<62))
+		require.NoError(tb, err)
+	}
+
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().AddDate(1, 0, 0)
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.certificate, issuer.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	require.NoError(tb, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(tb, err)
+
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		pem:         string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}
+}
+
+func TestCertificateAnalysis(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	ca := generateTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+
+	t.Run("parse", func(t *testing.T) {
+		certificate, err := parseCertificate(ca.pem)
+		require.NoError(t, err)
+		require.Equal(t, "Test CA", certificate.Subject.CommonName)
+
+		_, err = parseCertificate("-----BEGIN CERTIFICATE-----\nadmin\n-----END CERTIFICATE-----\n")
+		require.Error(t, err)
+
+		_, err = parseCertificate("admin")
+		require.Error(t, err)
+	})
+
+	t.Run("ecdsa", func(t *testing.T) {
+		leaf := generateTestCertificate(t, &x509.Certificate{
+			Subject:        pkix.Name{CommonName: "www.example.com"},
+			DNSNames:       []string{"www.example.com", "example.com"},
+			IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+			EmailAddresses: []string{"admin@example.com"},
+			URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/web"}},
+			NotAfter:       now.Add(10*24*time.Hour + time.Hour),
+		}, nil, ca)
+
+		metadata := analyzeCertificate(leaf.certificate, now)
+		require.Len(t, metadata.Fingerprint, 64)
+		require.Equal(t, getFingerprint(leaf.certificate), metadata.Fingerprint)
+		require.NotEqual(t, getFingerprint(ca.certificate), metadata.Fingerprint)
+		require.Equal(t, "ECDSA", metadata.KeyAlgorithm)
+		require.Equal(t, 256, metadata.KeySize)
+		require.Equal(t, "ECDSA-SHA256", metadata.SignatureAlgorithm)
+		require.Equal(t, []string{"www.example.com", "example.com", "10.0.0.1", "admin@example.com", "spiffe://example.com/web"}, metadata.SubjectAlternativeNames)
+		require.False(t, metadata.SelfSigned)
+		require.False(t, metadata.WeakKey)
+		require.False(t, metadata.SHA1Signature)
+		require.Equal(t, 10, metadata.DaysRemaining)
+	})
+
+	t.Run("self_signed", func(t *testing.T) {
+		metadata := analyzeCertificate(ca.certificate, now)
+		require.True(t, metadata.SelfSigned)
+		require.Empty(t, metadata.SubjectAlternativeNames)
+
+		// a certificate issued by a CA with the same subject is not self-signed
+		impostor := generateTestCertificate(t, &x509.Certificate{
+			Subject: pkix.Name{CommonName: "Test CA"},
+		}, nil, ca)
+		require.False(t, analyzeCertificate(impostor.certificate, now).SelfSigned)
+	})
+
+	t.Run("weak_rsa", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+
+		weak := generateTestCertificate(t, &x509.Certificate{
+			Subject:            pkix.Name{CommonName: "legacy.example.com"},
+			SignatureAlgorithm: x509.SHA1WithRSA,
+			NotAfter:           now.Add(-36 * time.Hour),
+		}, key, nil)
+
+		metadata := analyzeCertificate(weak.certificate, now)
+		require.Equal(t, "RSA", metadata.KeyAlgorithm)
+		require.Equal(t, 1024, metadata.KeySize)
+		require.True(t, metadata.WeakKey)
+		require.True(t, metadata.SHA1Signature)
+		require.True(t, metadata.SelfSigned)
+		require.Equal(t, -2, metadata.DaysRemaining)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		expiring := generateTestCertificate(t, &x509.Certificate{
+			Subject:  pkix.Name{CommonName: "expiring.example.com"},
+			NotAfter: now.AddDate(0, 0, 10),
+		}, nil, ca)
+
+		require.False(t, isExpired(expiring.certificate))
+		require.True(t, isExpiringWithin(expiring.certificate, 30))
+		require.False(t, isExpiringWithin(expiring.certificate, 5))
+
+		expired := generateTestCertificate(t, &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "expired.example.com"},
+			NotBefore: now.AddDate(0, 0, -30),
+			NotAfter:  now.Add(-24 * time.Hour),
+		}, nil, ca)
+
+		require.True(t, isExpired(expired.certificate))
+		require.True(t, isExpiringWithin(expired.certificate, 5))
+	})
+}
@@ -0,0 +1,220 @@
+package discovery
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultCertificateAuthorityPageSize is the number of CA certificates per paged request when indexing the CA
+	// certificates of a tenant
+	DefaultCertificateAuthorityPageSize = 50
+	// MaxCertificateChainLength is the maximum number of CA certificates added to a chain, which stops a chain
+	// completion looping on cross-signed CA certificates
+	MaxCertificateChainLength = 10
+)
+
+// certificateAuthority is a parsed CA certificate of a tenant
+type certificateAuthority struct {
+	certificate *x509.Certificate
+	pem         string
+}
+
+// certificateAuthorityIndex holds the CA certificates of a tenant by subject key identifier and by subject, used to
+// find the issuer of a certificate when the chain is not referenced by the certificate
+type certificateAuthorityIndex struct {
+	tenant    string
+	byKeyID   map[string][]*certificateAuthority
+	bySubject map[string][]*certificateAuthority
+}
+
+// buildCertificateAuthorityIndex will read the CA certificates of the tenant, using the client session of the tenant
+// being discovered
+func buildCertificateAuthorityIndex(client *domain.Client, clientServices vmwareavi.ClientServices, tenant string) (*certificateAuthorityIndex, error) {
+	index := &certificateAuthorityIndex{
+		tenant:    tenant,
+		byKeyID:   make(map[string][]*certificateAuthority),
+		bySubject: make(map[string][]*certificateAuthority),
+	}
+
+	var options []session.ApiOptionsParams
+	if !strings.EqualFold(tenant, client.Tenant) {
+		options = append(options, session.SetOptTenant(tenant))
+	}
+
+	certificates, err := vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+		return clientServices.GetAllSSLKeysAndCertificates(client, options...)
+	}, map[string]string{
+		"export_key": "false",
+		"page_size":  strconv.Itoa(DefaultCertificateAuthorityPageSize),
+		"search":     "(type,SSL_CERTIFICATE_TYPE_CA)",
+		"sort":       "uuid",
+	}, options...)
+	if err != nil {
+		zap.L().Info("failed to read CA certificates for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("caTenant", tenant), zap.Error(err))
+		return nil, err
+	}
+
+	for _, certificate := range certificates {
+		if certificate.Certificate == nil || certificate.Certificate.Certificate == nil {
+			continue
+		}
+
+		parsed, parseErr := parseCertificate(*certificate.Certificate.Certificate)
+		if parseErr != nil {
+			zap.L().Info("skipping un-parsable CA certificate", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", tenant), zap.String("name", getCertificateName(certificate)), zap.Error(parseErr))
+			continue
+		}
+
+		index.add(&certificateAuthority{
+			certificate: parsed,
+			pem:         *certificate.Certificate.Certificate,
+		})
+	}
+
+	zap.L().Info("indexed CA certificates for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", tenant), zap.Int("subjects", len(index.bySubject)))
+	return index, nil
+}
+
+func (index *certificateAuthorityIndex) add(ca *certificateAuthority) {
+	if len(ca.certificate.SubjectKeyId) > 0 {
+		key := hex.EncodeToString(ca.certificate.SubjectKeyId)
+		index.byKeyID[key] = append(index.byKeyID[key], ca)
+	}
+
+	subject := string(ca.certificate.RawSubject)
+	index.bySubject[subject] = append(index.bySubject[subject], ca)
+}
+
+// findIssuer will return the CA certificate that signed the certificate, matching the authority key identifier of
+// the certificate and then its issuer, or nil when the tenant has no such CA certificate
+func (index *certificateAuthorityIndex) findIssuer(certificate *x509.Certificate) *certificateAuthority {
+	var candidates []*certificateAuthority
+	if len(certificate.AuthorityKeyId) > 0 {
+		candidates = index.byKeyID[hex.EncodeToString(certificate.AuthorityKeyId)]
+	}
+
+	if len(candidates) == 0 {
+		candidates = index.bySubject[string(certificate.RawIssuer)]
+	}
+
+	for _, candidate := range candidates {
+		if !bytes.Equal(candidate.certificate.RawSubject, certificate.RawIssuer) {
+			continue
+		}
+
+		err := certificate.CheckSignatureFrom(candidate.certificate)
+
+		var insecure x509.InsecureAlgorithmError
+		if err == nil || errors.As(err, &insecure) {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+// getCertificateAuthorityIndex will return the CA index of the tenant, read once by the processor. A failure to read
+// the index is also kept, so the failure is not repeated for every certificate of the tenant.
+func (p *certificateDiscoveryProcessor) getCertificateAuthorityIndex(client *domain.Client, tenant string) (*certificateAuthorityIndex, error) {
+	key := strings.ToLower(tenant)
+
+	if err, ok := p.caIndexErrors[key]; ok {
+		return nil, err
+	}
+
+	if index, ok := p.caIndexes[key]; ok {
+		return index, nil
+	}
+
+	index, err := buildCertificateAuthorityIndex(client, p.clientServices, tenant)
+	if err != nil {
+		p.caIndexErrors[key] = err
+		return nil, err
+	}
+
+	p.caIndexes[key] = index
+	return index, nil
+}
+
+// findIssuer will find the issuer of the certificate in the CA certificates of the tenant being discovered, and then
+// in the CA certificates of the admin tenant, which are shared with every tenant
+func (p *certificateDiscoveryProcessor) findIssuer(client *domain.Client, certificate *x509.Certificate) (*certificateAuthority, error) {
+	tenants := []string{client.Tenant}
+	if !strings.EqualFold(client.Tenant, vmwareavi.DefaultTenantName) {
+		tenants = append(tenants, vmwareavi.DefaultTenantName)
+	}
+
+	for _, tenant := range tenants {
+		index, err := p.getCertificateAuthorityIndex(client, tenant)
+		if err != nil {
+			return nil, err
+		}
+
+		if issuer := index.findIssuer(certificate); issuer != nil {
+			return issuer, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// completeChain will extend the chain of the discovered certificate with the issuers found in the CA certificates,
+// until the chain ends with a self-signed root. The chain is marked complete when it ends with a self-signed root,
+// and a certificate or a chain certificate that cannot be parsed leaves the chain incomplete.
+func (p *certificateDiscoveryProcessor) completeChain(client *domain.Client, certificate *x509.Certificate, dc *DiscoveredCertificate) error {
+	dc.ChainComplete = false
+	if certificate == nil {
+		return nil
+	}
+
+	tip := certificate
+	seen := map[string]bool{
+		string(certificate.Raw): true,
+	}
+
+	for _, pem := range dc.CertificateChain {
+		parsed, err := parseCertificate(pem)
+		if err != nil {
+			zap.L().Info("unable to complete certificate chain with un-parsable CA certificate", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+			return nil
+		}
+
+		tip = parsed
+		seen[string(parsed.Raw)] = true
+	}
+
+	for {
+		if isSelfSigned(tip) {
+			dc.ChainComplete = true
+			return nil
+		}
+
+		if len(dc.CertificateChain) >= MaxCertificateChainLength {
+			return nil
+		}
+
+		issuer, err := p.findIssuer(client, tip)
+		if err != nil {
+			return err
+		}
+
+		if issuer == nil || seen[string(issuer.certificate.Raw)] {
+			return nil
+		}
+
+		dc.CertificateChain = append(dc.CertificateChain, issuer.pem)
+		seen[string(issuer.certificate.Raw)] = true
+		tip = issuer.certificate
+	}
+}
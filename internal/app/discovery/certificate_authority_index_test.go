@@ -0,0 +1,245 @@
+package discovery
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+// getTenantOptionValue will return the tenant set by the options, or an empty string when the options do not set a tenant
+func getTenantOptionValue(options ...session.ApiOptionsParams) string {
+	opts := &session.ApiOptions{}
+	for _, opt := range options {
+		if err := opt(opts); err != nil {
+			return ""
+		}
+	}
+
+	return reflect.ValueOf(opts).Elem().FieldByName("tenant").String()
+}
+
+func newTestCertificateAuthority(tb testing.TB, name string, issuer *testCertificate) *testCertificate {
+	return generateTestCertificate(tb, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, issuer)
+}
+
+func toSSLKeyAndCertificate(name string, certificate *testCertificate) *models.SSLKeyAndCertificate {
+	return &models.SSLKeyAndCertificate{
+		Certificate: &models.SSLCertificate{
+			Certificate: toPointer(certificate.pem),
+		},
+		Name: toPointer(name),
+		UUID: toPointer("uuid-" + name),
+		URL:  toPointer("https://localhost/api/sslkeyandcertificate/uuid-" + name),
+	}
+}
+
+func TestCertificateAuthorityIndex(t *testing.T) {
+	t.Parallel()
+
+	root := newTestCertificateAuthority(t, "Test Root", nil)
+	intermediate := newTestCertificateAuthority(t, "Test Intermediate", root)
+	impostor := newTestCertificateAuthority(t, "Test Intermediate", nil)
+
+	leaf := generateTestCertificate(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "www.example.com"},
+		DNSNames: []string{"www.example.com"},
+	}, nil, intermediate)
+
+	t.Run("find_issuer", func(t *testing.T) {
+		index := &certificateAuthorityIndex{
+			byKeyID:   make(map[string][]*certificateAuthority),
+			bySubject: make(map[string][]*certificateAuthority),
+		}
+
+		// a CA certificate with the issuer subject that did not sign the certificate is not its issuer
+		index.add(&certificateAuthority{certificate: impostor.certificate, pem: impostor.pem})
+		require.Nil(t, index.findIssuer(leaf.certificate))
+
+		index.add(&certificateAuthority{certificate: intermediate.certificate, pem: intermediate.pem})
+		index.add(&certificateAuthority{certificate: root.certificate, pem: root.pem})
+
+		issuer := index.findIssuer(leaf.certificate)
+		require.NotNil(t, issuer)
+		require.Equal(t, intermediate.pem, issuer.pem)
+
+		issuer = index.findIssuer(intermediate.certificate)
+		require.NotNil(t, issuer)
+		require.Equal(t, root.pem, issuer.pem)
+
+		// the issuer subject is matched when the certificate has no authority key identifier
+		orphan := *leaf.certificate
+		orphan.AuthorityKeyId = nil
+
+		issuer = index.findIssuer(&orphan)
+		require.NotNil(t, issuer)
+		require.Equal(t, intermediate.pem, issuer.pem)
+	})
+
+	t.Run("discovery", func(t *testing.T) {
+		e := echo.New()
+
+		unknown := newTestCertificateAuthority(t, "Unknown Root", nil)
+		selfSigned := generateTestCertificate(t, &x509.Certificate{
+			Subject: pkix.Name{CommonName: "self.example.com"},
+		}, nil, nil)
+		orphan := generateTestCertificate(t, &x509.Certificate{
+			Subject: pkix.Name{CommonName: "orphan.example.com"},
+		}, nil, unknown)
+
+		certificates := map[string][]*models.SSLKeyAndCertificate{
+			"Venafi": {
+				toSSLKeyAndCertificate("a-leaf", leaf),
+				toSSLKeyAndCertificate("b-self-signed", selfSigned),
+				toSSLKeyAndCertificate("c-orphan", orphan),
+			},
+		}
+
+		// the intermediate is a CA certificate of the tenant, and the root is shared by the admin tenant
+		authorities := map[string][]*models.SSLKeyAndCertificate{
+			"admin":  {toSSLKeyAndCertificate("root", root)},
+			"Venafi": {toSSLKeyAndCertificate("intermediate", intermediate)},
+		}
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 1)
+
+		var mutex sync.Mutex
+		reads := map[string]int{}
+
+		mockClientServices.EXPECT().
+			GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+				search, _ := getParameterOptionsValue("search", options...)
+				if !strings.Contains(search, "SSL_CERTIFICATE_TYPE_CA") {
+					return getCertificateCursorPage(certificates[client.Tenant], options...), nil
+				}
+
+				tenant := getTenantOptionValue(options...)
+				if len(tenant) == 0 {
+					tenant = client.Tenant
+				}
+
+				mutex.Lock()
+				reads[tenant]++
+				mutex.Unlock()
+
+				return getCertificateCursorPage(authorities[tenant], options...), nil
+			}).
+			AnyTimes()
+
+		setupExpectNoCertificateUsage(mockClientServices)
+		setupExpectNoVirtualServiceInventory(mockClientServices)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return([]*models.VirtualService{}, nil).
+			AnyTimes()
+
+		discoveryServices := NewDiscoveryService(mockClientServices)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				Tenants: "Venafi",
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 100,
+			},
+		})
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 3)
+
+		require.True(t, response.Messages[0].ChainComplete)
+		require.Equal(t, []string{intermediate.pem, root.pem}, response.Messages[0].CertificateChain)
+
+		require.True(t, response.Messages[1].ChainComplete)
+		require.Empty(t, response.Messages[1].CertificateChain)
+
+		require.False(t, response.Messages[2].ChainComplete)
+		require.Empty(t, response.Messages[2].CertificateChain)
+
+		// the CA certificates of each tenant are read once by the request
+		require.Equal(t, map[string]int{"admin": 1, "Venafi": 1}, reads)
+		require.Empty(t, response.Warnings)
+	})
+
+	t.Run("discovery_failure", func(t *testing.T) {
+		e := echo.New()
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 1)
+
+		mockClientServices.EXPECT().
+			GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+				search, _ := getParameterOptionsValue("search", options...)
+				if strings.Contains(search, "SSL_CERTIFICATE_TYPE_CA") {
+					return nil, fmt.Errorf("permission denied")
+				}
+
+				return getCertificateCursorPage([]*models.SSLKeyAndCertificate{
+					toSSLKeyAndCertificate("a-leaf", leaf),
+				}, options...), nil
+			}).
+			AnyTimes()
+
+		setupExpectNoCertificateUsage(mockClientServices)
+		setupExpectNoVirtualServiceInventory(mockClientServices)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return([]*models.VirtualService{}, nil).
+			AnyTimes()
+
+		discoveryServices := NewDiscoveryService(mockClientServices)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				Tenants: "Venafi",
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 100,
+			},
+		})
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 1)
+		require.False(t, response.Messages[0].ChainComplete)
+
+		require.Len(t, response.Warnings, 1)
+		require.Equal(t, "a-leaf", response.Warnings[0].Object)
+		require.Equal(t, "incomplete certificate chain: failed to read the CA certificates: permission denied", response.Warnings[0].Reason)
+	})
+}
@@ -3,9 +3,11 @@ package discovery
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/venafi/vmware-avi-connector/internal/app/domain"
 	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
@@ -17,13 +19,27 @@ import (
 const (
 	// DefaultPageSize is the constant representing the number of results per paged request to VMware
 	DefaultPageSize = 10
+	// CertificateDiscoveryPaginatorVersion is the version of the certificate discovery paginator, a paginator of
+	// another version is discarded and the discovery of the tenant is restarted
+	CertificateDiscoveryPaginatorVersion = 2
+	// DefaultTenantConcurrency is the number of tenants discovered in parallel when not configured
+	DefaultTenantConcurrency = 4
+	// MaxTenantConcurrency is the maximum number of tenants discovered in parallel
+	MaxTenantConcurrency = 16
+	// DefaultStreamBuffer is the number of certificates a tenant of a streamed discovery builds ahead of the stream,
+	// a tenant then waits for the tenants before it to be written
+	DefaultStreamBuffer = 8
 	// DefaultCertificateSearch will include only system and virtual service certificates -- excluding CA certificates
 	DefaultCertificateSearch = "(type,SSL_CERTIFICATE_TYPE_SYSTEM)|(type,SSL_CERTIFICATE_TYPE_VIRTUALSERVICE)"
 )
 
+// certificateDiscoveryPaginator is a cursor over the tenant certificates sorted by UUID. Certificates created or
+// deleted between discovery requests do not move the cursor, so no certificate is skipped or repeated.
 type certificateDiscoveryPaginator struct {
-	Page  int `json:"page"`
-	Index int `json:"index"`
+	Version int    `json:"version"`
+	After   string `json:"after"`
+	// Since is the watermark of an incremental discovery of the tenant, when only changed certificates are discovered
+	Since string `json:"since,omitempty"`
 }
 
 type certificateDiscoveryProcessor struct {
@@ -33,6 +49,40 @@ type certificateDiscoveryProcessor struct {
 	control        *DiscoveryControl
 	paginator      *certificateDiscoveryPaginator
 	clientServices vmwareavi.ClientServices
+
+	// virtualServiceIndexes is shared by the discovery requests, the index of the current tenant is loaded on first use,
+	// and virtualServiceIndexErrors are the failures to build the index by tenant, so a failed index is not rebuilt for
+	// every certificate
+	virtualServiceIndexes     *virtualServiceIndexCache
+	virtualServiceIndex       *virtualServiceIndex
+	virtualServiceIndexErrors map[string]error
+	continuation              bool
+	// runtimeErrReported is set once the failure to read the virtual service inventory is reported by the request, so
+	// it is reported once for the tenant by each request rather than for every virtual service
+	runtimeErrReported bool
+
+	// changes are the changes of the tenant for an incremental discovery
+	changes *tenantChanges
+
+	// caIndexes are the CA certificates of the tenants by lowercase tenant name, used to complete certificate chains
+	caIndexes     map[string]*certificateAuthorityIndex
+	caIndexErrors map[string]error
+
+	// usageIndexes are the pools, PKI profiles and GSLB services of the tenants by lowercase tenant name, and
+	// systemUsage is the portal and secure channel usage of the admin tenant certificates
+	usageIndexes     map[string]*certificateUsageIndex
+	usageIndexErrors map[string]error
+	systemUsage      map[string][]string
+	systemUsageErr   error
+
+	// wildcard is set when the certificates of every tenant are discovered with the wildcard tenant, and the
+	// certificates of each tenant are processed with a client of the tenant sharing the session
+	wildcard      bool
+	tenantClients map[string]*domain.Client
+
+	// discovered is called with each certificate as it is built when the discovery is streamed, the certificates are
+	// then not returned by discover
+	discovered func(dcr *discoveredCertificateAndURL)
 }
 
 func newCertificateDiscovery(services vmwareavi.ClientServices, connection *domain.Connection, configuration *DiscoverCertificatesConfiguration, control *DiscoveryControl) *certificateDiscoveryProcessor {
@@ -42,16 +92,45 @@ func newCertificateDiscovery(services vmwareavi.ClientServices, connection *doma
 		configuration:  configuration,
 		control:        control,
 		paginator: &certificateDiscoveryPaginator{
-			Page:  1,
-			Index: 0,
+			Version: CertificateDiscoveryPaginatorVersion,
 		},
-		clientServices: services,
+		clientServices:            services,
+		virtualServiceIndexes:     newVirtualServiceIndexCache(),
+		virtualServiceIndexErrors: map[string]error{},
+		caIndexes:                 map[string]*certificateAuthorityIndex{},
+		caIndexErrors:             map[string]error{},
+		usageIndexes:              map[string]*certificateUsageIndex{},
+		usageIndexErrors:          map[string]error{},
+		tenantClients:             map[string]*domain.Client{},
 	}
 }
 
-func (p *certificateDiscoveryProcessor) addCaCertificates(client *domain.Client, certificateName string, caCerts []*models.CertificateAuthority, dc *DiscoveredCertificate) {
+// getVirtualServiceIndex will return the virtual service index of the client tenant. An index built by an earlier
+// request is only reused when continuing the discovery of the tenant, so every new discovery sees current usage.
+func (p *certificateDiscoveryProcessor) getVirtualServiceIndex(client *domain.Client) (*virtualServiceIndex, error) {
+	if p.virtualServiceIndex != nil && p.virtualServiceIndex.tenant == client.Tenant {
+		return p.virtualServiceIndex, nil
+	}
+
+	if err, ok := p.virtualServiceIndexErrors[client.Tenant]; ok {
+		return nil, err
+	}
+
+	index, err := p.virtualServiceIndexes.get(client, p.clientServices, p.continuation)
+	if err != nil {
+		p.virtualServiceIndexErrors[client.Tenant] = err
+		return nil, err
+	}
+
+	p.virtualServiceIndex = index
+	return index, nil
+}
+
+// addCaCertificates will set the issuing chain of the discovered certificate, the returned error describes why the
+// chain could not be read and the chain is left empty
+func (p *certificateDiscoveryProcessor) addCaCertificates(client *domain.Client, certificateName string, caCerts []*models.CertificateAuthority, dc *DiscoveredCertificate) error {
 	if len(caCerts) == 0 {
-		return
+		return nil
 	}
 
 	var err error
@@ -60,13 +139,13 @@ func (p *certificateDiscoveryProcessor) addCaCertificates(client *domain.Client,
 	for idx, caCert := range caCerts {
 		if caCert == nil {
 			zap.L().Info("null CA Certificate in collection", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", certificateName))
-			return
+			return fmt.Errorf("null CA certificate in the chain")
 		}
 
 		if caCert.CaRef == nil {
 			if caCert.Name == nil || len(*caCert.Name) == 0 {
 				zap.L().Info("missing CA Certificate reference", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", certificateName))
-				return
+				return fmt.Errorf("missing CA certificate reference")
 			}
 
 			var caCert2 *models.SSLKeyAndCertificate
@@ -76,7 +155,7 @@ func (p *certificateDiscoveryProcessor) addCaCertificates(client *domain.Client,
 			}))
 			if err != nil {
 				zap.L().Info("failed to retrieve CA Certificate by name", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", certificateName), zap.String("caCertificateName", *caCert.Name))
-				return
+				return fmt.Errorf(`failed to retrieve the CA certificate "%s": %w`, *caCert.Name, err)
 			}
 
 			caCert.CaRef = caCert2.URL
@@ -86,7 +165,7 @@ func (p *certificateDiscoveryProcessor) addCaCertificates(client *domain.Client,
 		id, err = getUUIDFromURL(*caCert.CaRef)
 		if err != nil {
 			zap.L().Info("unable to parse CA Certificate reference", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", certificateName), zap.Error(err))
-			return
+			return fmt.Errorf("unable to parse the CA certificate reference: %w", err)
 		}
 
 		var exists bool
@@ -101,17 +180,17 @@ func (p *certificateDiscoveryProcessor) addCaCertificates(client *domain.Client,
 		cac, err = p.clientServices.GetSSLKeyAndCertificateByID(client, id)
 		if err != nil {
 			zap.L().Info("failed to retrieve CA Certificate by reference", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", certificateName), zap.String("reference", *caCert.CaRef), zap.Error(err))
-			return
+			return fmt.Errorf(`failed to retrieve the CA certificate "%s": %w`, *caCert.CaRef, err)
 		}
 
 		if cac == nil || cac.Certificate == nil || cac.Certificate.Certificate == nil {
 			zap.L().Info("CA Certificate reference has no pem", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", certificateName), zap.String("reference", *caCert.CaRef))
-			return
+			return fmt.Errorf(`the CA certificate "%s" has no pem`, *caCert.CaRef)
 		}
 
 		if len(*cac.Certificate.Certificate) == 0 {
 			zap.L().Info("CA Certificate reference has empty pem", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", certificateName), zap.String("reference", *caCert.CaRef))
-			return
+			return fmt.Errorf(`the CA certificate "%s" has an empty pem`, *caCert.CaRef)
 		}
 
 		chain[idx] = *cac.Certificate.Certificate
@@ -119,14 +198,17 @@ func (p *certificateDiscoveryProcessor) addCaCertificates(client *domain.Client,
 	}
 
 	dc.CertificateChain = chain
+	return nil
 }
 
 func (p *certificateDiscoveryProcessor) discover(client *domain.Client, page *DiscoveryPage) (finished bool, results []*discoveredCertificateAndURL, err error) {
-	if !strings.EqualFold(client.Tenant, *page.Tenant) {
+	if !p.wildcard && !strings.EqualFold(client.Tenant, *page.Tenant) {
 		page.Paginator = ""
 		return true, nil, nil
 	}
 
+	p.continuation = len(page.Paginator) > 0
+
 	if len(page.Paginator) > 0 {
 		err = json.Unmarshal([]byte(page.Paginator), p.paginator)
 		if err != nil {
@@ -135,75 +217,133 @@ func (p *certificateDiscoveryProcessor) discover(client *domain.Client, page *Di
 			zap.L().Error("failed to unmarshal the certificate discovery page paginator", zap.String("address", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
 			return true, nil, fmt.Errorf("failed to unmarshal certificate discovery page paginator: %w", err)
 		}
+
+		if p.paginator.Version != CertificateDiscoveryPaginatorVersion {
+			zap.L().Info("restarting the tenant discovery for an unsupported paginator version", zap.String("address", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.Int("version", p.paginator.Version))
+
+			p.continuation = false
+			p.paginator = &certificateDiscoveryPaginator{
+				Version: CertificateDiscoveryPaginatorVersion,
+			}
+		}
+	}
+
+	if p.configuration.Incremental {
+		err = p.scanChanges(client, page)
+		if err != nil {
+			page.Paginator = ""
+			return true, nil, fmt.Errorf(`failed to read VMware NSX-ALB changes for the tenant "%s": %w`, client.Tenant, err)
+		}
 	}
 
 	discoveredCertificates := make([]*discoveredCertificateAndURL, 0)
+	count := 0
 
 	for {
-		var ok bool
 		var certificates []*models.SSLKeyAndCertificate
+		var chunk []string
+
+		params := p.configuration.filters.getCertificateSearchParams()
+		params["export_key"] = "false"
+		params["page_size"] = strconv.Itoa(DefaultPageSize)
+		params["sort"] = "uuid"
+
+		if p.changes != nil && p.changes.changed != nil {
+			// only the changed certificates of the tenant are discovered
+			chunk = p.changes.after(p.paginator.After, DefaultPageSize)
+			if len(chunk) == 0 {
+				break
+			}
 
-		certificates, err = p.clientServices.GetAllSSLKeysAndCertificates(client, session.SetParams(map[string]string{
-			"export_key": "false",
-			"page":       strconv.Itoa(p.paginator.Page),
-			"page_size":  strconv.Itoa(DefaultPageSize),
-			"search":     DefaultCertificateSearch,
-		}))
-		if err != nil {
-			var ae session.AviError
+			params["uuid.in"] = strings.Join(chunk, ",")
+		} else if len(p.paginator.After) > 0 {
+			params["uuid.gt"] = p.paginator.After
+		}
+
+		options := []session.ApiOptionsParams{session.SetParams(params)}
+		if p.wildcard {
+			params["include_name"] = "true"
+			options = append(options, session.SetOptTenant(vmwareavi.WildcardTenantName))
+		}
+
+		after := p.paginator.After
 
-			ae, ok = err.(session.AviError)
-			if !ok || ae.AviResult.Message == nil || !strings.Contains(*ae.AviResult.Message, "That page contains no results") {
+		certificates, err = p.clientServices.GetAllSSLKeysAndCertificates(client, options...)
+		if err != nil {
+			if !vmwareavi.IsNoResultsPage(err) {
 				page.Paginator = ""
 
 				zap.L().Error("Error reading VMware NSX-ALB certificates", zap.String("address", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
 				return true, nil, fmt.Errorf(`failed to read VMware NSX-ALB certificates for the tenant "%s": %w`, client.Tenant, err)
 			}
 
-			p.paginator.Page = 1
-			p.paginator.Index = 0
 			break
 		}
 
-		for p.paginator.Index < len(certificates) {
-			cert := certificates[p.paginator.Index]
-			p.paginator.Index++
-
+		for _, cert := range certificates {
 			if cert == nil {
 				continue
 			}
 
+			if id := getCertificateUUID(cert); len(id) > 0 {
+				p.paginator.After = id
+			}
+
 			if cert.Name == nil {
 				zap.L().Info("skipping certificate with no name", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", getCertificateName(cert)))
 				continue
 			}
 
+			tenantClient := client
+			if p.wildcard {
+				tenant := vmwareavi.GetNameFromRef(cert.TenantRef)
+				if !p.includesTenant(tenant) {
+					zap.L().Info("skipping certificate of a tenant not discovered", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenant), zap.String("name", getCertificateName(cert)))
+					continue
+				}
+
+				tenantClient = p.getTenantClient(client, tenant)
+			}
+
+			if !p.configuration.filters.includesCertificate(cert) {
+				zap.L().Info("skipping certificate excluded by the discovery filters", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)))
+				continue
+			}
+
 			certificate := cert.Certificate
 			if certificate == nil {
-				zap.L().Info("skipping certificate with no certificate content", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", getCertificateName(cert)))
+				zap.L().Info("skipping certificate with no certificate content", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)))
 				continue
 			}
 
-			if p.configuration.ExcludeExpiredCertificates {
-				var expired bool
-				expired, err = isExpired(certificate)
-				if err != nil {
-					zap.L().Info("skipping  discoveredCertificates certificate with un-parsable not after", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", getCertificateName(cert)), zap.String("notAfter", getValue(certificate.NotAfter)))
+			if certificate.Certificate == nil {
+				zap.L().Info("skipping certificate with no pem", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)))
+				continue
+			}
+
+			parsed, parseErr := parseCertificate(*certificate.Certificate)
+			if parseErr != nil {
+				zap.L().Info("unable to analyze certificate", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)), zap.Error(parseErr))
+			}
+
+			if p.configuration.ExcludeExpiredCertificates || p.configuration.ExpiringWithinDays > 0 {
+				if parsed == nil {
+					zap.L().Info("skipping certificate with un-parsable pem", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)))
 					continue
 				}
 
-				if expired {
-					zap.L().Info("skipping expired certificate", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", getCertificateName(cert)))
+				if p.configuration.ExcludeExpiredCertificates && isExpired(parsed) {
+					zap.L().Info("skipping expired certificate", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)))
 					continue
 				}
-			}
 
-			if certificate.Certificate == nil {
-				zap.L().Info("skipping certificate with no pem", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", getCertificateName(cert)))
-				continue
+				if p.configuration.ExpiringWithinDays > 0 && !isExpiringWithin(parsed, p.configuration.ExpiringWithinDays) {
+					zap.L().Info("skipping certificate not expiring within the configured days", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)), zap.Int("days", p.configuration.ExpiringWithinDays))
+					continue
+				}
 			}
 
-			zap.L().Info("discoveredCertificates certificate", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", getCertificateName(cert)))
+			zap.L().Info("discoveredCertificates certificate", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)))
 
 			dc := &DiscoveredCertificate{
 				Certificate:       *certificate.Certificate,
@@ -212,7 +352,11 @@ func (p *certificateDiscoveryProcessor) discover(client *domain.Client, page *Di
 				MachineIdentities: make([]*MachineIdentity, 0),
 			}
 
-			p.addCaCertificates(client, getCertificateName(cert), cert.CaCerts, dc)
+			if parsed != nil {
+				dc.Metadata = analyzeCertificate(parsed, time.Now())
+			}
+
+			chainErr := p.addCaCertificates(tenantClient, getCertificateName(cert), cert.CaCerts, dc)
 
 			var uuid string
 			if cert.UUID != nil {
@@ -220,30 +364,74 @@ func (p *certificateDiscoveryProcessor) discover(client *domain.Client, page *Di
 			} else if cert.URL != nil {
 				uuid, err = getUUIDFromURL(*cert.URL)
 				if err != nil {
-					zap.L().Info("skipping certificate with invalid url", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", getCertificateName(cert)), zap.Error(err))
+					zap.L().Info("skipping certificate with invalid url", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)), zap.Error(err))
 					continue
 				}
 			} else {
-				zap.L().Info("skipping certificate with no uuid or url", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", client.Tenant), zap.String("name", getCertificateName(cert)))
+				zap.L().Info("skipping certificate with no uuid or url", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)))
 				continue
 			}
 
 			dcr := &discoveredCertificateAndURL{
 				Name:   getCertificateName(cert),
 				Result: dc,
+				Tenant: tenantClient.Tenant,
 				UUID:   uuid,
 			}
 
-			err = processVirtualServices(client, p.clientServices, dcr)
-			if err != nil {
-				_ = p.updateDiscoveryPaginator(client, true, page)
-				return true, nil, err
+			if completeErr := p.completeChain(tenantClient, parsed, dc); completeErr != nil {
+				zap.L().Info("unable to complete certificate chain", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", getCertificateName(cert)), zap.Error(completeErr))
+				chainErr = errors.Join(chainErr, fmt.Errorf("failed to read the CA certificates: %w", completeErr))
+			}
+
+			if chainErr != nil && !dc.ChainComplete {
+				dcr.warn(tenantClient.Tenant, DiscoveryIssueCertificate, dcr.Name, fmt.Sprintf("incomplete certificate chain: %s", chainErr.Error()))
+			}
+
+			// a failure to read the virtual services fails the tenant of a strict discovery, otherwise it is reported
+			// as a warning of the certificate, which is returned without its virtual service usage
+			warnings := len(dcr.warnings)
+
+			index, indexErr := p.getVirtualServiceIndex(client)
+			if indexErr != nil {
+				if p.configuration.Strict {
+					_ = p.updateDiscoveryPaginator(client, true, page)
+					return true, nil, indexErr
+				}
+
+				zap.L().Info("unable to read certificate virtual service usage", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("name", dcr.Name), zap.Error(indexErr))
+				dcr.warn(tenantClient.Tenant, DiscoveryIssueCertificate, dcr.Name, fmt.Sprintf("incomplete certificate usage: %s", indexErr.Error()))
+			} else {
+				processVirtualServices(tenantClient, p.clientServices, index, p.configuration.filters, dcr)
 			}
 
-			if !p.configuration.ExcludeInactiveCertificates || len(dcr.Result.MachineIdentities) > 0 {
-				discoveredCertificates = append(discoveredCertificates, dcr)
+			fingerprint := ""
+			if dc.Metadata != nil {
+				fingerprint = dc.Metadata.Fingerprint
+			}
 
-				if len(discoveredCertificates) >= p.control.MaxResults {
+			p.processCertificateUsage(client, tenantClient, fingerprint, dcr)
+
+			// a certificate whose usage could not be read is returned with its warnings, since it can be in use
+			usageIncomplete := len(dcr.warnings) > warnings
+
+			if !p.configuration.ExcludeInactiveCertificates || len(dcr.Result.MachineIdentities) > 0 || usageIncomplete {
+				// the state of the virtual services is missing when the inventory could not be read, reported once
+				// with the first virtual service using a certificate
+				if vsName := getVirtualServiceUsageName(dcr); index != nil && index.runtimeErr != nil && len(vsName) > 0 && !p.runtimeErrReported {
+					p.runtimeErrReported = true
+					dcr.warn(tenantClient.Tenant, DiscoveryIssueVirtualService, vsName, index.runtimeErr.Error())
+				}
+
+				dcr.paginator = *p.paginator
+				if p.discovered != nil {
+					p.discovered(dcr)
+				} else {
+					discoveredCertificates = append(discoveredCertificates, dcr)
+				}
+
+				count++
+				if count >= p.control.MaxResults {
 					finished = false
 
 					err = p.updateDiscoveryPaginator(client, finished, page)
@@ -252,14 +440,17 @@ func (p *certificateDiscoveryProcessor) discover(client *domain.Client, page *Di
 			}
 		}
 
-		p.paginator.Index = 0
+		if len(chunk) > 0 {
+			// a changed certificate deleted since the scan is not returned, continue after the whole chunk
+			p.paginator.After = chunk[len(chunk)-1]
+			continue
+		}
 
-		if len(certificates) == DefaultPageSize {
-			p.paginator.Page++
+		// a short page is the last page, and a page that does not move the cursor cannot be continued
+		if len(certificates) == DefaultPageSize && p.paginator.After != after {
 			continue
 		}
 
-		p.paginator.Page = 1
 		break
 	}
 
@@ -269,6 +460,44 @@ func (p *certificateDiscoveryProcessor) discover(client *domain.Client, page *Di
 	return finished, discoveredCertificates, err
 }
 
+// scanChanges will scan the tenant for the changes since the watermark of the discovery page. The watermark and the
+// resynchronization are only reported when the discovery of the tenant starts, a continuing discovery of the tenant
+// only uses the changed certificates. A resynchronized tenant discovers every certificate, also when continued.
+func (p *certificateDiscoveryProcessor) scanChanges(client *domain.Client, page *DiscoveryPage) error {
+	var since *TenantWatermark
+
+	starting := len(p.paginator.After) == 0
+	if starting {
+		since = page.Watermarks[client.Tenant]
+		if since != nil {
+			p.paginator.Since = since.LastModified
+		}
+	} else if len(p.paginator.Since) > 0 {
+		since = &TenantWatermark{
+			LastModified: p.paginator.Since,
+		}
+	} else {
+		// continuing a discovery of every certificate of the tenant
+		return nil
+	}
+
+	changes, err := scanTenantChanges(client, p.clientServices, p.configuration.filters, since)
+	if err != nil {
+		return err
+	}
+
+	if !starting {
+		changes.watermark = nil
+		changes.resynchronized = false
+	} else if changes.resynchronized {
+		changes.changed = nil
+		p.paginator.Since = ""
+	}
+
+	p.changes = changes
+	return nil
+}
+
 func (p *certificateDiscoveryProcessor) updateDiscoveryPaginator(client *domain.Client, finished bool, page *DiscoveryPage) error {
 	if !finished {
 		data, err := json.Marshal(p.paginator)
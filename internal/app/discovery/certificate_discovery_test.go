@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCertificateDiscoveryPaginator(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func(maxResults int) *DiscoverCertificatesRequest {
+		return &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				Tenants: "Venafi",
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: maxResults,
+			},
+		}
+	}
+
+	t.Run("change_tolerant", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		// the first request, and five requests for the remaining twenty certificates with the last finding no more
+		setupExpectClientUsage(t, mockClientServices, 6)
+
+		certificates := setupTenantCertificates([]string{"Venafi"}, []int{25})
+		setupPagedDiscovery(mockClientServices, certificates, "")
+
+		discoveryServices := NewDiscoveryService(mockClientServices)
+		request := newRequest(5)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, request)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 5)
+		require.NotNil(t, response.Page)
+
+		paginator := certificateDiscoveryPaginator{}
+		require.NoError(t, json.Unmarshal([]byte(response.Page.Paginator), &paginator))
+		require.Equal(t, CertificateDiscoveryPaginatorVersion, paginator.Version)
+		require.Equal(t, "uuid-Venafi-04", paginator.After)
+
+		discovered := make([]string, 0)
+		for _, dc := range response.Messages {
+			discovered = append(discovered, dc.Certificate)
+		}
+
+		// delete a discovered and an undiscovered certificate, and create a certificate before and after the cursor
+		added := setupTenantCertificates([]string{"Venafi"}, []int{26})["Venafi"][25]
+		added.UUID = toPointer("uuid-Venafi-03a")
+
+		later := setupTenantCertificates([]string{"Venafi"}, []int{27})["Venafi"][26]
+
+		collection := certificates["Venafi"]
+		collection = slices.Delete(collection, 12, 13)
+		collection = slices.Delete(collection, 2, 3)
+		certificates["Venafi"] = append(collection, added, later)
+
+		for requests := 0; response.Page != nil; requests++ {
+			require.Less(t, requests, 10)
+
+			request.Page = response.Page
+			response, code = runTenantDiscovery(t, e, discoveryServices, request)
+			require.Equal(t, http.StatusOK, code)
+
+			for _, dc := range response.Messages {
+				discovered = append(discovered, dc.Certificate)
+			}
+		}
+
+		expected := make([]string, 0)
+		for n, certificate := range setupTenantCertificates([]string{"Venafi"}, []int{27})["Venafi"] {
+			if n == 12 || n == 25 {
+				continue
+			}
+			expected = append(expected, *certificate.Certificate.Certificate)
+		}
+
+		require.Equal(t, expected, discovered)
+	})
+
+	t.Run("unsupported_version", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 1)
+
+		certificates := setupTenantCertificates([]string{"Venafi"}, []int{12})
+		setupPagedDiscovery(mockClientServices, certificates, "")
+
+		discoveryServices := NewDiscoveryService(mockClientServices)
+		request := newRequest(3)
+		request.Page = &DiscoveryPage{
+			Tenant:    toPointer("Venafi"),
+			Paginator: `{"page":2,"index":4}`,
+		}
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, request)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 3)
+		require.Equal(t, *certificates["Venafi"][0].Certificate.Certificate, response.Messages[0].Certificate)
+	})
+}
@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"go.uber.org/zap"
+)
+
+// processCertificateUsage will add a machine identity for each use of the certificate other than by a virtual service,
+// the pools, PKI profiles and GSLB services are indexed with the client of the discovery session. A failure to read the
+// usage is reported as a warning of the certificate and the usage that was read is kept. The PKI profiles are found by
+// the fingerprint of the certificate, not set when it cannot be parsed.
+func (p *certificateDiscoveryProcessor) processCertificateUsage(client, tenantClient *domain.Client, fingerprint string, dcr *discoveredCertificateAndURL) {
+	addUsage := func(usageType, objectName, objectTenant string) {
+		zap.L().Info("discovered certificate usage", zap.String("hostname", p.connection.HostnameOrAddress), zap.Int("port", p.connection.Port), zap.String("tenant", tenantClient.Tenant), zap.String("certificateName", dcr.Name), zap.String("usageType", usageType), zap.String("object", objectName))
+
+		mi := &MachineIdentity{
+			Keystore: &domain.Keystore{
+				CertificateName: dcr.Name,
+				Tenant:          tenantClient.Tenant,
+			},
+			Binding: &domain.Binding{
+				UsageType:  usageType,
+				ObjectName: objectName,
+			},
+		}
+
+		if len(objectTenant) > 0 && !strings.EqualFold(objectTenant, tenantClient.Tenant) {
+			mi.Binding.Tenant = objectTenant
+		}
+
+		dcr.Result.MachineIdentities = append(dcr.Result.MachineIdentities, mi)
+	}
+
+	index, err := p.getCertificateUsageIndex(client)
+	if err != nil {
+		dcr.warn(tenantClient.Tenant, DiscoveryIssueCertificate, dcr.Name, fmt.Sprintf("incomplete certificate usage: %s", err.Error()))
+	} else {
+		for _, pool := range index.pools[dcr.UUID] {
+			addUsage(domain.UsageTypePool, getValue(pool.Name), vmwareavi.GetNameFromRef(pool.TenantRef))
+		}
+
+		if len(fingerprint) > 0 {
+			for _, profile := range index.pkiProfiles[fingerprint] {
+				addUsage(domain.UsageTypePkiProfile, getValue(profile.Name), vmwareavi.GetNameFromRef(profile.TenantRef))
+
+				id := getPKIProfileUUID(profile)
+				if len(id) == 0 {
+					continue
+				}
+
+				for _, gslbService := range index.gslbServices[id] {
+					addUsage(domain.UsageTypeGslb, getValue(gslbService.Name), vmwareavi.GetNameFromRef(gslbService.TenantRef))
+				}
+			}
+		}
+	}
+
+	// the portal and the secure channel only use certificates of the admin tenant
+	if !strings.EqualFold(tenantClient.Tenant, vmwareavi.DefaultTenantName) {
+		return
+	}
+
+	usage, err := p.getSystemCertificateUsage(tenantClient)
+	if err != nil {
+		dcr.warn(tenantClient.Tenant, DiscoveryIssueCertificate, dcr.Name, fmt.Sprintf("incomplete certificate usage: %s", err.Error()))
+		return
+	}
+
+	for _, usageType := range usage[dcr.UUID] {
+		addUsage(usageType, "", "")
+	}
+}
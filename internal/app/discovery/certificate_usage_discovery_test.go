@@ -0,0 +1,213 @@
+package discovery
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+// setupExpectNoCertificateUsage will expect the certificate usage reads of a discovery, returning no pools, PKI
+// profiles, GSLB services or system configuration usage
+func setupExpectNoCertificateUsage(clientServices *mocks.MockClientServices) {
+	clientServices.EXPECT().
+		GetAllPools(gomock.Any(), gomock.Any()).
+		Return([]*models.Pool{}, nil).
+		AnyTimes()
+	clientServices.EXPECT().
+		GetAllPKIProfiles(gomock.Any(), gomock.Any()).
+		Return([]*models.PKIprofile{}, nil).
+		AnyTimes()
+	clientServices.EXPECT().
+		GetAllGslbServices(gomock.Any(), gomock.Any()).
+		Return([]*models.GslbService{}, nil).
+		AnyTimes()
+	clientServices.EXPECT().
+		GetSystemConfiguration(gomock.Any()).
+		Return(&models.SystemConfiguration{}, nil).
+		AnyTimes()
+}
+
+func TestCertificateUsageDiscovery(t *testing.T) {
+	e := echo.New()
+
+	ca := newTestCertificateAuthority(t, "Test Root", nil)
+	leaf := generateTestCertificate(t, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "www.example.com"},
+	}, nil, ca)
+
+	newRequest := func(excludeInactive bool) *DiscoverCertificatesRequest {
+		return &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				ExcludeInactiveCertificates: excludeInactive,
+				Tenants:                     "admin",
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 100,
+			},
+		}
+	}
+
+	t.Run("usage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 1)
+		setupExpectGetAllSSLKeysAndCertificates(mockClientServices, map[string][]*models.SSLKeyAndCertificate{
+			"admin": {
+				toSSLKeyAndCertificate("a-portal", leaf),
+				toSSLKeyAndCertificate("b-pool", leaf),
+				toSSLKeyAndCertificate("c-ca", ca),
+				toSSLKeyAndCertificate("d-unused", leaf),
+			},
+		}, 2, 2)
+
+		setupExpectNoVirtualServiceInventory(mockClientServices)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return([]*models.VirtualService{}, nil).
+			AnyTimes()
+
+		// the objects of every tenant are read for the certificates of the admin tenant
+		mockClientServices.EXPECT().
+			GetAllPools(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Pool, error) {
+				require.Equal(t, vmwareavi.WildcardTenantName, getTenantOptionValue(options...))
+
+				return []*models.Pool{
+					{
+						Name:                    toPointer("blue-pool"),
+						SslKeyAndCertificateRef: toPointer("https://localhost/api/sslkeyandcertificate/uuid-b-pool"),
+						TenantRef:               toPointer("https://localhost/api/tenant/tenant-Blue#Blue"),
+					},
+					{
+						Name:      toPointer("plain-pool"),
+						TenantRef: toPointer("https://localhost/api/tenant/admin#admin"),
+					},
+				}, nil
+			}).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetAllPKIProfiles(gomock.Any(), gomock.Any()).
+			Return([]*models.PKIprofile{
+				{
+					CaCerts:   []*models.SSLCertificate{{Certificate: toPointer(ca.pem)}},
+					Name:      toPointer("pki-root"),
+					TenantRef: toPointer("https://localhost/api/tenant/admin#admin"),
+					UUID:      toPointer("pkiprofile-root"),
+				},
+			}, nil).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetAllGslbServices(gomock.Any(), gomock.Any()).
+			Return([]*models.GslbService{
+				{
+					Name:          toPointer("gslb-app"),
+					PkiProfileRef: toPointer("https://localhost/api/pkiprofile/pkiprofile-root"),
+					TenantRef:     toPointer("https://localhost/api/tenant/admin#admin"),
+				},
+			}, nil).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetSystemConfiguration(gomock.Any()).
+			Return(&models.SystemConfiguration{
+				PortalConfiguration: &models.PortalConfiguration{
+					SslkeyandcertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/uuid-a-portal"},
+				},
+				SecureChannelConfiguration: &models.SecureChannelConfiguration{
+					SslkeyandcertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/uuid-a-portal"},
+				},
+			}, nil).
+			Times(1)
+
+		response, code := runTenantDiscovery(t, e, NewDiscoveryService(mockClientServices), newRequest(true))
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, response.Warnings)
+
+		// the unused certificate is inactive and excluded
+		require.Len(t, response.Messages, 3)
+
+		bindings := func(dc *DiscoveredCertificate) []domain.Binding {
+			collection := make([]domain.Binding, 0)
+			for _, mi := range dc.MachineIdentities {
+				require.Equal(t, "admin", mi.Keystore.Tenant)
+				collection = append(collection, *mi.Binding)
+			}
+
+			return collection
+		}
+
+		require.Equal(t, []domain.Binding{
+			{UsageType: domain.UsageTypePortal},
+			{UsageType: domain.UsageTypeSecureChannel},
+		}, bindings(response.Messages[0]))
+
+		require.Equal(t, []domain.Binding{
+			{UsageType: domain.UsageTypePool, ObjectName: "blue-pool", Tenant: "Blue"},
+		}, bindings(response.Messages[1]))
+
+		require.Equal(t, []domain.Binding{
+			{UsageType: domain.UsageTypePkiProfile, ObjectName: "pki-root"},
+			{UsageType: domain.UsageTypeGslb, ObjectName: "gslb-app"},
+		}, bindings(response.Messages[2]))
+		require.Empty(t, response.Messages[2].Installations)
+	})
+
+	t.Run("usage_failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 1)
+		setupExpectGetAllSSLKeysAndCertificates(mockClientServices, map[string][]*models.SSLKeyAndCertificate{
+			"admin": {
+				toSSLKeyAndCertificate("a-leaf", leaf),
+				toSSLKeyAndCertificate("b-leaf", leaf),
+			},
+		}, 2, 2)
+
+		setupExpectNoVirtualServiceInventory(mockClientServices)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return([]*models.VirtualService{}, nil).
+			AnyTimes()
+
+		// the failure is read once, and reported for every certificate of the tenant
+		mockClientServices.EXPECT().
+			GetAllPools(gomock.Any(), gomock.Any()).
+			Return(nil, fmt.Errorf("permission denied")).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetSystemConfiguration(gomock.Any()).
+			Return(&models.SystemConfiguration{}, nil).
+			Times(1)
+
+		response, code := runTenantDiscovery(t, e, NewDiscoveryService(mockClientServices), newRequest(false))
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 2)
+		require.Empty(t, response.Messages[0].MachineIdentities)
+
+		require.Len(t, response.Warnings, 2)
+		require.Equal(t, "a-leaf", response.Warnings[0].Object)
+		require.Equal(t, DiscoveryIssueCertificate, response.Warnings[0].ObjectType)
+		require.Equal(t, "incomplete certificate usage: failed to read the pools: permission denied", response.Warnings[0].Reason)
+	})
+}
@@ -0,0 +1,229 @@
+package discovery
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultCertificateUsagePageSize is the number of objects per paged request when indexing the pools, PKI profiles
+	// and GSLB services of a tenant
+	DefaultCertificateUsagePageSize = 100
+)
+
+// certificateUsageIndex maps the certificates of a tenant to the objects, other than virtual services, using them
+type certificateUsageIndex struct {
+	tenant string
+	// pools are the pools using a certificate as their client certificate, by certificate UUID
+	pools map[string][]*models.Pool
+	// pkiProfiles are the PKI profiles including a CA certificate, by SHA-256 fingerprint of the CA certificate
+	pkiProfiles map[string][]*models.PKIprofile
+	// gslbServices are the GSLB services validating with a PKI profile, by PKI profile UUID
+	gslbServices map[string][]*models.GslbService
+}
+
+// getTenantScope will return the tenant option of the objects read for the certificates of the client tenant. The
+// objects of every tenant are read for the admin tenant, since the certificates of the admin tenant can be used by the
+// objects of other tenants.
+func getTenantScope(client *domain.Client) []session.ApiOptionsParams {
+	if strings.EqualFold(client.Tenant, vmwareavi.DefaultTenantName) {
+		return []session.ApiOptionsParams{session.SetOptTenant(vmwareavi.WildcardTenantName)}
+	}
+
+	return nil
+}
+
+// getTenantScopeParams will return the query parameters of a collection read in the tenant scope of the client tenant,
+// the names of the references are included when the objects of every tenant are read
+func getTenantScopeParams(client *domain.Client, pageSize int) map[string]string {
+	params := map[string]string{
+		"page_size": strconv.Itoa(pageSize),
+	}
+
+	if len(getTenantScope(client)) > 0 {
+		params["include_name"] = "true"
+	}
+
+	return params
+}
+
+// buildCertificateUsageIndex will read every pool, PKI profile and GSLB service of the tenant once and index them by
+// the certificates they use
+func buildCertificateUsageIndex(client *domain.Client, clientServices vmwareavi.ClientServices) (*certificateUsageIndex, error) {
+	index := &certificateUsageIndex{
+		tenant:       client.Tenant,
+		pools:        make(map[string][]*models.Pool),
+		pkiProfiles:  make(map[string][]*models.PKIprofile),
+		gslbServices: make(map[string][]*models.GslbService),
+	}
+
+	pools, err := vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.Pool, error) {
+		return clientServices.GetAllPools(client, options...)
+	}, getTenantScopeParams(client, DefaultCertificateUsagePageSize), getTenantScope(client)...)
+	if err != nil {
+		zap.L().Info("failed to read pools for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+		return nil, fmt.Errorf("failed to read the pools: %w", err)
+	}
+
+	for _, pool := range pools {
+		if pool.SslKeyAndCertificateRef == nil {
+			continue
+		}
+
+		id, idErr := getUUIDFromURL(*pool.SslKeyAndCertificateRef)
+		if idErr != nil || len(id) == 0 {
+			continue
+		}
+
+		index.pools[id] = append(index.pools[id], pool)
+	}
+
+	profiles, err := vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.PKIprofile, error) {
+		return clientServices.GetAllPKIProfiles(client, options...)
+	}, getTenantScopeParams(client, DefaultCertificateUsagePageSize), getTenantScope(client)...)
+	if err != nil {
+		zap.L().Info("failed to read PKI profiles for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+		return nil, fmt.Errorf("failed to read the PKI profiles: %w", err)
+	}
+
+	for _, profile := range profiles {
+		seen := map[string]bool{}
+		for _, caCert := range profile.CaCerts {
+			if caCert == nil || caCert.Certificate == nil {
+				continue
+			}
+
+			parsed, parseErr := parseCertificate(*caCert.Certificate)
+			if parseErr != nil {
+				zap.L().Info("skipping un-parsable CA certificate of PKI profile", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("pkiProfile", getValue(profile.Name)), zap.Error(parseErr))
+				continue
+			}
+
+			fingerprint := getFingerprint(parsed)
+			if seen[fingerprint] {
+				continue
+			}
+
+			seen[fingerprint] = true
+			index.pkiProfiles[fingerprint] = append(index.pkiProfiles[fingerprint], profile)
+		}
+	}
+
+	gslbServices, err := vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.GslbService, error) {
+		return clientServices.GetAllGslbServices(client, options...)
+	}, getTenantScopeParams(client, DefaultCertificateUsagePageSize), getTenantScope(client)...)
+	if err != nil {
+		zap.L().Info("failed to read GSLB services for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+		return nil, fmt.Errorf("failed to read the GSLB services: %w", err)
+	}
+
+	for _, gslbService := range gslbServices {
+		if gslbService.PkiProfileRef == nil {
+			continue
+		}
+
+		id, idErr := getUUIDFromURL(*gslbService.PkiProfileRef)
+		if idErr != nil || len(id) == 0 {
+			continue
+		}
+
+		index.gslbServices[id] = append(index.gslbServices[id], gslbService)
+	}
+
+	zap.L().Info("indexed certificate usage for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Int("pools", len(index.pools)), zap.Int("pkiProfiles", len(profiles)), zap.Int("gslbServices", len(gslbServices)))
+	return index, nil
+}
+
+// getPKIProfileUUID will return the UUID of the PKI profile, or an empty string when the PKI profile has no UUID or URL
+func getPKIProfileUUID(profile *models.PKIprofile) string {
+	if profile.UUID != nil && len(*profile.UUID) > 0 {
+		return *profile.UUID
+	}
+
+	if profile.URL != nil {
+		if id, err := getUUIDFromURL(*profile.URL); err == nil {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// readSystemCertificateUsage will return the usage types of the certificates of the admin tenant used by the
+// controller portal and by the secure channel, by certificate UUID
+func readSystemCertificateUsage(client *domain.Client, clientServices vmwareavi.ClientServices) (map[string][]string, error) {
+	configuration, err := clientServices.GetSystemConfiguration(client)
+	if err != nil {
+		zap.L().Info("failed to read system configuration", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+		return nil, fmt.Errorf("failed to read the system configuration: %w", err)
+	}
+
+	usage := make(map[string][]string)
+	if configuration == nil {
+		return usage, nil
+	}
+
+	add := func(usageType string, refs []string) {
+		for _, ref := range refs {
+			id, idErr := getUUIDFromURL(ref)
+			if idErr != nil || len(id) == 0 {
+				continue
+			}
+
+			if !slices.Contains(usage[id], usageType) {
+				usage[id] = append(usage[id], usageType)
+			}
+		}
+	}
+
+	if configuration.PortalConfiguration != nil {
+		add(domain.UsageTypePortal, configuration.PortalConfiguration.SslkeyandcertificateRefs)
+	}
+
+	if configuration.SecureChannelConfiguration != nil {
+		add(domain.UsageTypeSecureChannel, configuration.SecureChannelConfiguration.SslkeyandcertificateRefs)
+	}
+
+	return usage, nil
+}
+
+// getCertificateUsageIndex will return the certificate usage index of the client tenant, read once by the processor.
+// A failure to read the index is also kept, so the failure is not repeated for every certificate of the tenant.
+func (p *certificateDiscoveryProcessor) getCertificateUsageIndex(client *domain.Client) (*certificateUsageIndex, error) {
+	key := strings.ToLower(client.Tenant)
+
+	if err, ok := p.usageIndexErrors[key]; ok {
+		return nil, err
+	}
+
+	if index, ok := p.usageIndexes[key]; ok {
+		return index, nil
+	}
+
+	index, err := buildCertificateUsageIndex(client, p.clientServices)
+	if err != nil {
+		p.usageIndexErrors[key] = err
+		return nil, err
+	}
+
+	p.usageIndexes[key] = index
+	return index, nil
+}
+
+// getSystemCertificateUsage will return the portal and secure channel usage of the admin tenant certificates, read
+// once by the processor
+func (p *certificateDiscoveryProcessor) getSystemCertificateUsage(client *domain.Client) (map[string][]string, error) {
+	if p.systemUsage == nil && p.systemUsageErr == nil {
+		p.systemUsage, p.systemUsageErr = readSystemCertificateUsage(client, p.clientServices)
+	}
+
+	return p.systemUsage, p.systemUsageErr
+}
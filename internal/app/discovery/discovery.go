@@ -2,7 +2,9 @@ package discovery
 
 import (
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
 
@@ -10,47 +12,87 @@ import (
 	"github.com/venafi/vmware-avi-connector/internal/app/domain"
 	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
 	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
 	"go.uber.org/zap"
 )
 
 // DiscoveryService implementation of ClientServices
 type DiscoveryService struct {
 	ClientServices vmwareavi.ClientServices
+
+	virtualServiceIndexes *virtualServiceIndexCache
 }
 
 // NewDiscoveryService create a new DiscoveryService
 func NewDiscoveryService(clientServices vmwareavi.ClientServices) *DiscoveryService {
 	return &DiscoveryService{
-		ClientServices: clientServices,
+		ClientServices:        clientServices,
+		virtualServiceIndexes: newVirtualServiceIndexCache(),
 	}
 }
 
 // DiscoverCertificates will attempt to start or continue a discovery of certificates
 func (svc *DiscoveryService) DiscoverCertificates(c echo.Context) error {
-	var err error
+	req, err := bindDiscoverCertificatesRequest(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	response, err := svc.discoverCertificates(req, nil)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// DiscoverCertificatesStream will attempt to start or continue a discovery of certificates, writing each discovered
+// certificate as a newline delimited JSON record once its tenant is collected, followed by a trailer record with the
+// discovery page and the failures of the discovery
+func (svc *DiscoveryService) DiscoverCertificatesStream(c echo.Context) error {
+	req, err := bindDiscoverCertificatesRequest(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	stream := newDiscoveryStream(c.Response())
+
+	response, err := svc.discoverCertificates(req, stream.write)
+	if err != nil {
+		if !stream.started {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
 
-	req := DiscoverCertificatesRequest{
+		// the status was sent with the first certificate, the failure is reported by the trailer
+		zap.L().Error("streamed discovery failed after writing certificates", zap.Int("written", stream.written), zap.Error(err))
+		return stream.fail(err)
+	}
+
+	return stream.close(response)
+}
+
+// bindDiscoverCertificatesRequest will read the discovery request and compile its configuration
+func bindDiscoverCertificatesRequest(c echo.Context) (*DiscoverCertificatesRequest, error) {
+	req := &DiscoverCertificatesRequest{
 		Configuration: DiscoverCertificatesConfiguration{
 			ExcludeExpiredCertificates:  false,
 			ExcludeInactiveCertificates: false,
 		},
 	}
 
-	if err = c.Bind(&req); err != nil {
+	if err := c.Bind(req); err != nil {
 		zap.L().Error("invalid request, failed to unmarshall json", zap.Error(err))
-		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall request json: %s", err.Error()))
+		return nil, fmt.Errorf("failed to unmarshall request json: %s", err.Error())
 	}
 
-	var tenants TenantNames
-	if len(req.Configuration.Tenants) == 0 {
-		tenants, err = svc.getAllTenants(req.Connection)
-		if err != nil {
-			return c.String(http.StatusBadRequest, err.Error())
-		}
-	} else {
-		tenants = strings.Split(req.Configuration.Tenants, ",")
+	if err := req.Configuration.compileFilters(); err != nil {
+		zap.L().Error("invalid discovery configuration", zap.Error(err))
+		return nil, fmt.Errorf("invalid discovery configuration: %s", err.Error())
+	}
+
+	if len(req.Configuration.Tenants) > 0 {
 		req.Configuration.tenants = make([]string, 0)
-		for _, value := range tenants {
+		for _, value := range strings.Split(req.Configuration.Tenants, ",") {
 			tenant := strings.TrimSpace(value)
 			if len(tenant) > 0 && !req.Configuration.tenants.contains(tenant) {
 				req.Configuration.tenants = append(req.Configuration.tenants, tenant)
@@ -58,10 +100,6 @@ func (svc *DiscoveryService) DiscoverCertificates(c echo.Context) error {
 		}
 	}
 
-	sort.Slice(tenants, func(i, j int) bool { return lessLower(tenants[i], tenants[j]) })
-
-	csp := newCertificateDiscovery(svc.ClientServices, req.Connection, &req.Configuration, &req.Control)
-
 	if req.Page == nil {
 		req.Page = &DiscoveryPage{
 			Tenant:    nil,
@@ -69,64 +107,285 @@ func (svc *DiscoveryService) DiscoverCertificates(c echo.Context) error {
 		}
 	}
 
-	var page *DiscoveryPage
-	var client *domain.Client
+	return req, nil
+}
 
-	results := newTenantDiscoveryResults()
+// discoverCertificates will discover the certificates of the request. When set, collected is called with the results
+// each time a tenant is collected, so the certificates can be written before the discovery completes.
+func (svc *DiscoveryService) discoverCertificates(req *DiscoverCertificatesRequest, collected func(results *tenantDiscoveryResults) error) (*DiscoverCertificatesResponse, error) {
+	var err error
+
+	maxResults := max(req.Control.MaxResults, 1)
+
+	if req.Configuration.WildcardTenant {
+		return svc.discoverCertificatesWithWildcardTenant(req, maxResults, collected)
+	}
 
-	for i, tenant := range tenants {
-		if req.Page.Tenant == nil {
-			req.Page.Tenant = &tenants[i]
+	var tenants TenantNames
+	if len(req.Configuration.tenants) > 0 {
+		// the configured tenants are trimmed, without empty or repeated names, when the request is read
+		tenants = slices.Clone(req.Configuration.tenants)
+	} else {
+		tenants, err = svc.getAllTenants(req.Connection)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if !strings.EqualFold(tenant, *req.Page.Tenant) {
-			continue
+	tenants = slices.DeleteFunc(tenants, func(tenant string) bool {
+		if req.Configuration.filters.includesTenant(strings.TrimSpace(tenant)) {
+			return false
+		}
+
+		zap.L().Info("skipping tenant excluded by the discovery filters", zap.String("tenant", tenant))
+		return true
+	})
+
+	sort.Slice(tenants, func(i, j int) bool { return lessLower(tenants[i], tenants[j]) })
+
+	first := 0
+	if req.Page.Tenant != nil {
+		first = slices.IndexFunc(tenants, func(tenant string) bool { return strings.EqualFold(tenant, *req.Page.Tenant) })
+		if first < 0 {
+			zap.L().Info("discovery page tenant is not configured, completing the discovery", zap.String("tenant", *req.Page.Tenant))
+			first = len(tenants)
 		}
+	}
 
-		if client == nil || !strings.EqualFold(client.Tenant, tenant) {
-			if client != nil {
-				svc.ClientServices.Close(client)
+	var page *DiscoveryPage
+
+	results := newTenantDiscoveryResults()
+	if req.Page.Tenant != nil {
+		results.carry(req.Page)
+	}
+
+	for tenant, watermark := range req.Page.Watermarks {
+		results.Watermarks[tenant] = watermark
+	}
+
+	paginator := req.Page.Paginator
+	concurrency := getTenantConcurrency(&req.Configuration)
+
+	// the tenants are discovered in parallel batches and collected in tenant order, so a discovery stopping at
+	// maxResults is continued from the same tenant and paginator whatever the order the workers finished in. A batch
+	// only discovers the results remaining, and the certificates of a tenant beyond them are never collected.
+	done := false
+	for first < len(tenants) && !done {
+		last := min(first+concurrency, len(tenants))
+
+		outcomes := svc.discoverTenants(req.Connection, &req.Configuration, maxResults-results.Discovered, tenants[first:last], paginator, req.Page.Watermarks, collected != nil)
+		paginator = ""
+
+		// every worker of a batch is waited for before the next batch starts, or the discovery returns, so no more
+		// than the configured number of tenants are discovered at once
+		for idx, outcome := range outcomes {
+			// the certificates of a streamed discovery are written as they are built, in the order of the tenants
+			if outcome.certificates != nil {
+				if err = outcome.stream(results, maxResults, collected); err != nil {
+					waitTenants(outcomes)
+					return nil, err
+				}
 			}
 
-			client = svc.ClientServices.NewClient(req.Connection, tenant)
-			err = svc.ClientServices.Connect(client)
+			<-outcome.done
+
+			if outcome.err != nil && req.Configuration.Strict {
+				waitTenants(outcomes)
+				return nil, outcome.err
+			}
+
+			page, err = outcome.collect(results, maxResults)
 			if err != nil {
-				return c.String(http.StatusBadRequest, err.Error())
+				waitTenants(outcomes)
+				return nil, err
 			}
-		}
 
-		err = svc.runDiscover(client, &req.Control, csp, req.Page, results)
-		if err != nil {
-			return c.String(http.StatusBadRequest, err.Error())
-		}
+			if page != nil {
+				done = true
+				break
+			}
 
-		if results.Discovered < req.Control.MaxResults {
-			req.Page.Tenant = nil
-			continue
+			if results.Discovered >= maxResults {
+				// the tenant is finished, the next request starts at the following tenant
+				if first+idx+1 < len(tenants) {
+					page = &DiscoveryPage{
+						Tenant:    &tenants[first+idx+1],
+						Paginator: "",
+					}
+				}
+
+				done = true
+				break
+			}
 		}
 
-		break
+		waitTenants(outcomes)
+		first = last
 	}
 
-	if client != nil {
-		svc.ClientServices.Close(client)
+	if !req.Configuration.Incremental {
+		return svc.buildResponse(req, page, results), nil
+	}
+
+	if page == nil {
+		// the completed discovery only keeps the watermarks of the discovered tenants
+		maps.DeleteFunc(results.Watermarks, func(tenant string, _ *TenantWatermark) bool { return !slices.Contains(tenants, tenant) })
+	}
+
+	response := svc.buildResponse(req, page, results)
+	response.Resynchronized = results.Resynchronized
+
+	if page != nil {
+		page.Watermarks = results.Watermarks
+	} else {
+		response.Watermarks = results.Watermarks
+	}
+
+	return response, nil
+}
+
+// discoverCertificatesWithWildcardTenant will start or continue a discovery of the certificates of every tenant with a
+// single session. A discovery page of another tenant, left by a discovery started without the wildcard tenant, restarts
+// the discovery.
+func (svc *DiscoveryService) discoverCertificatesWithWildcardTenant(req *DiscoverCertificatesRequest, maxResults int, collected func(results *tenantDiscoveryResults) error) (*DiscoverCertificatesResponse, error) {
+	paginator := ""
+	if req.Page.Tenant != nil && *req.Page.Tenant == vmwareavi.WildcardTenantName {
+		paginator = req.Page.Paginator
+	}
+
+	outcome := newTenantDiscovery(vmwareavi.WildcardTenantName, collected != nil)
+	defer waitTenants([]*tenantDiscovery{outcome})
+
+	go func() {
+		svc.discoverWildcardTenant(outcome, req.Connection, &req.Configuration, maxResults, paginator)
+		outcome.complete()
+	}()
+
+	results := newTenantDiscoveryResults()
+	if len(paginator) > 0 {
+		results.carry(req.Page)
 	}
 
-	page = req.Page
-	if req.Page.Tenant == nil {
-		page = nil
+	// the certificates of a streamed discovery are written as they are built, in the order they are read
+	if outcome.certificates != nil {
+		if err := outcome.stream(results, maxResults, collected); err != nil {
+			return nil, err
+		}
 	}
 
-	return c.JSON(http.StatusOK, buildResponse(page, results))
+	<-outcome.done
+
+	if outcome.err != nil && req.Configuration.Strict {
+		return nil, outcome.err
+	}
+
+	page, err := outcome.collect(results, maxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.buildResponse(req, page, results), nil
 }
 
-func buildResponse(discoveryPage *DiscoveryPage, discoveredResults *tenantDiscoveryResults) *DiscoverCertificatesResponse {
+// buildResponse will build the response of the discovery request. The copies of the certificates are carried by the
+// discovery page of a continuing discovery, and the duplicates of the whole discovery are returned once it completes.
+func (svc *DiscoveryService) buildResponse(req *DiscoverCertificatesRequest, discoveryPage *DiscoveryPage, discoveredResults *tenantDiscoveryResults) *DiscoverCertificatesResponse {
 	discoveredCertificates := discoveredResults.collapse()
+	discoveredResults.recordCopies()
 
-	return &DiscoverCertificatesResponse{
+	response := &DiscoverCertificatesResponse{
 		Page:     discoveryPage,
 		Messages: discoveredCertificates,
+		Warnings: discoveredResults.Warnings,
+		Errors:   discoveredResults.Errors,
+	}
+
+	if discoveryPage != nil {
+		discoveryPage.Copies = discoveredResults.carried()
+		discoveryPage.CopiesTruncated = discoveredResults.truncated
+	} else {
+		response.Duplicates = discoveredResults.duplicates()
+		response.Warnings = append(response.Warnings, svc.readCarriedUsage(req.Connection, &req.Configuration, response.Duplicates)...)
+
+		if discoveredResults.truncated {
+			response.Warnings = append(response.Warnings, &DiscoveryIssue{
+				Reason: fmt.Sprintf("incomplete duplicates: more than %d certificate copies were carried by the discovery", DefaultMaxCarriedCopies),
+			})
+		}
+	}
+
+	return response
+}
+
+// readCarriedUsage will read the usage of the duplicate copies carried from the previous requests of the discovery,
+// with a session of each tenant. The virtual service index built when the tenant was discovered is reused. A failure
+// to read the usage of a copy is returned as a warning of the certificate, and the copy is not marked unused.
+func (svc *DiscoveryService) readCarriedUsage(connection *domain.Connection, configuration *DiscoverCertificatesConfiguration, duplicates []*DuplicateCertificateGroup) []*DiscoveryIssue {
+	warnings := make([]*DiscoveryIssue, 0)
+
+	fingerprints := map[*DuplicateCertificate]string{}
+	tenants := make([]string, 0)
+	carried := map[string][]*DuplicateCertificate{}
+	for _, group := range duplicates {
+		for _, dc := range group.Certificates {
+			if !dc.carried {
+				continue
+			}
+
+			if _, ok := carried[dc.Tenant]; !ok {
+				tenants = append(tenants, dc.Tenant)
+			}
+
+			fingerprints[dc] = group.Fingerprint
+			carried[dc.Tenant] = append(carried[dc.Tenant], dc)
+		}
+	}
+
+	for _, tenant := range tenants {
+		client := svc.ClientServices.NewClient(connection, tenant)
+		err := svc.ClientServices.Connect(client)
+		if err != nil {
+			zap.L().Info("unable to read the usage of the duplicate certificates of tenant", zap.String("hostname", connection.HostnameOrAddress), zap.Int("port", connection.Port), zap.String("tenant", tenant), zap.Error(err))
+		}
+
+		csp := newCertificateDiscovery(svc.ClientServices, connection, configuration, &DiscoveryControl{})
+		csp.virtualServiceIndexes = svc.virtualServiceIndexes
+		csp.continuation = true
+
+		for _, dc := range carried[tenant] {
+			dcr := &discoveredCertificateAndURL{
+				Name:   dc.CertificateName,
+				Result: &DiscoveredCertificate{},
+				Tenant: tenant,
+				UUID:   dc.uuid,
+			}
+
+			if err != nil {
+				dcr.warn(tenant, DiscoveryIssueCertificate, dcr.Name, fmt.Sprintf("incomplete certificate usage: %s", err.Error()))
+			} else {
+				index, indexErr := csp.getVirtualServiceIndex(client)
+				if indexErr != nil {
+					dcr.warn(tenant, DiscoveryIssueCertificate, dcr.Name, fmt.Sprintf("incomplete certificate usage: %s", indexErr.Error()))
+				} else {
+					processVirtualServices(client, svc.ClientServices, index, configuration.filters, dcr)
+				}
+
+				csp.processCertificateUsage(client, client, fingerprints[dc], dcr)
+			}
+
+			dc.Usage = make([]*domain.Binding, 0, len(dcr.Result.MachineIdentities))
+			for _, mi := range dcr.Result.MachineIdentities {
+				dc.Usage = append(dc.Usage, mi.Binding)
+			}
+			dc.Unused = len(dc.Usage) == 0 && len(dcr.warnings) == 0
+
+			warnings = append(warnings, dcr.warnings...)
+		}
+
+		svc.ClientServices.Close(client)
 	}
+
+	return warnings
 }
 
 func (svc *DiscoveryService) getAllTenants(connection *domain.Connection) (tenants TenantNames, err error) {
@@ -142,7 +401,10 @@ func (svc *DiscoveryService) getAllTenants(connection *domain.Connection) (tenan
 
 	var aviTenants []*models.Tenant
 
-	aviTenants, err = svc.ClientServices.GetAllTenants(client)
+	// a collection request only returns the first page, so every page of tenants is read
+	aviTenants, err = vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.Tenant, error) {
+		return svc.ClientServices.GetAllTenants(client, options...)
+	}, nil)
 	if err != nil {
 		zap.L().Error("Error reading VMware NSX-ALB tenants", zap.String("address", connection.HostnameOrAddress), zap.Int("port", connection.Port), zap.Error(err))
 		return nil, fmt.Errorf("failed to connect to VMware NSX-ALB: %w", err)
@@ -165,28 +427,3 @@ func (svc *DiscoveryService) getAllTenants(connection *domain.Connection) (tenan
 
 	return tenants, nil
 }
-func (svc *DiscoveryService) runDiscover(client *domain.Client, control *DiscoveryControl, csp *certificateDiscoveryProcessor, page *DiscoveryPage, results *tenantDiscoveryResults) error {
-	var err error
-	var finished bool
-	for {
-		control.MaxResults -= results.Discovered
-
-		var discovered []*discoveredCertificateAndURL
-
-		finished, discovered, err = csp.discover(client, page)
-		if err != nil {
-			return err
-		}
-
-		control.MaxResults += results.Discovered
-
-		results.append(client.Tenant, discovered)
-		discovered = nil
-
-		if !finished && results.Discovered < control.MaxResults {
-			continue
-		}
-
-		return nil
-	}
-}
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// MIMEApplicationNDJSON is the content type of a streamed discovery
+	MIMEApplicationNDJSON = "application/x-ndjson"
+)
+
+// discoveryStreamTrailerRecord is the trailer of a streamed discovery, wrapped so it cannot be mistaken for a
+// discovered certificate
+type discoveryStreamTrailerRecord struct {
+	Trailer *DiscoveryStreamTrailer `json:"trailer"`
+}
+
+// discoveryStream writes the discovered certificates of a discovery as newline delimited JSON records
+type discoveryStream struct {
+	response *echo.Response
+	encoder  *json.Encoder
+
+	// started is set once the status has been sent, a failure can then only be reported by the trailer
+	started bool
+	written int
+}
+
+func newDiscoveryStream(response *echo.Response) *discoveryStream {
+	return &discoveryStream{
+		response: response,
+		encoder:  json.NewEncoder(response),
+	}
+}
+
+func (stream *discoveryStream) start() {
+	if stream.started {
+		return
+	}
+
+	stream.response.Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	stream.response.WriteHeader(http.StatusOK)
+	stream.started = true
+}
+
+// write will write the collected certificates of the results and release them from the results
+func (stream *discoveryStream) write(results *tenantDiscoveryResults) error {
+	released := results.release()
+	if len(released) == 0 {
+		return nil
+	}
+
+	stream.start()
+
+	for _, dc := range released {
+		if err := stream.encoder.Encode(dc); err != nil {
+			return fmt.Errorf("failed to write the discovered certificate: %w", err)
+		}
+
+		stream.written++
+	}
+
+	stream.response.Flush()
+	return nil
+}
+
+// close will write the trailer with the discovery page and the failures of the response
+func (stream *discoveryStream) close(response *DiscoverCertificatesResponse) error {
+	return stream.writeTrailer(&DiscoveryStreamTrailer{
+		Page:           response.Page,
+		Resynchronized: response.Resynchronized,
+		Watermarks:     response.Watermarks,
+		Warnings:       response.Warnings,
+		Errors:         response.Errors,
+		Duplicates:     response.Duplicates,
+	})
+}
+
+// fail will write the trailer of a discovery that failed after certificates were written
+func (stream *discoveryStream) fail(err error) error {
+	return stream.writeTrailer(&DiscoveryStreamTrailer{
+		Failure: err.Error(),
+	})
+}
+
+func (stream *discoveryStream) writeTrailer(trailer *DiscoveryStreamTrailer) error {
+	stream.start()
+
+	if err := stream.encoder.Encode(&discoveryStreamTrailerRecord{Trailer: trailer}); err != nil {
+		return fmt.Errorf("failed to write the discovery trailer: %w", err)
+	}
+
+	stream.response.Flush()
+	return nil
+}
@@ -0,0 +1,380 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+// streamRecorder counts the records written by a streamed discovery, so the count can be read while the discovery
+// is running
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+
+	mutex   sync.Mutex
+	records int
+}
+
+func newStreamRecorder() *streamRecorder {
+	return &streamRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+	}
+}
+
+func (recorder *streamRecorder) Write(data []byte) (int, error) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.records += bytes.Count(data, []byte("\n"))
+	return recorder.ResponseRecorder.Write(data)
+}
+
+func (recorder *streamRecorder) written() int {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.records
+}
+
+// runStreamedDiscovery will request a streamed discovery and return the certificate records and the trailer record
+func runStreamedDiscovery(t *testing.T, e *echo.Echo, discoveryServices *DiscoveryService, request *DiscoverCertificatesRequest) ([]*DiscoveredCertificate, *DiscoveryStreamTrailer, int) {
+	return runStreamedDiscoveryTo(t, e, discoveryServices, request, newStreamRecorder())
+}
+
+// runStreamedDiscoveryTo will request a streamed discovery written to the recorder
+func runStreamedDiscoveryTo(t *testing.T, e *echo.Echo, discoveryServices *DiscoveryService, request *DiscoverCertificatesRequest, recorder *streamRecorder) ([]*DiscoveredCertificate, *DiscoveryStreamTrailer, int) {
+	raw, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	httpRequest := httptest.NewRequest(http.MethodPost, "/v1/discovercertificatesstream", bytes.NewReader(raw))
+	httpRequest.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	ctx := e.NewContext(httpRequest, recorder)
+
+	err = discoveryServices.DiscoverCertificatesStream(ctx)
+	require.NoError(t, err)
+
+	if recorder.Code != http.StatusOK {
+		return nil, nil, recorder.Code
+	}
+
+	require.Equal(t, MIMEApplicationNDJSON, recorder.Header().Get(echo.HeaderContentType))
+
+	certificates := make([]*DiscoveredCertificate, 0)
+	var trailer *DiscoveryStreamTrailer
+
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		require.Nil(t, trailer, "the trailer must be the last record")
+
+		record := &discoveryStreamTrailerRecord{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), record))
+		if record.Trailer != nil {
+			trailer = record.Trailer
+			continue
+		}
+
+		dc := &DiscoveredCertificate{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), dc))
+		certificates = append(certificates, dc)
+	}
+	require.NoError(t, scanner.Err())
+	require.NotNil(t, trailer)
+
+	return certificates, trailer, recorder.Code
+}
+
+func TestDiscoverCertificatesStream(t *testing.T) {
+	e := echo.New()
+
+	tenants := []string{"admin", "Blue", "green", "Orange", "red"}
+	counts := []int{3, 0, 12, 1, 7}
+
+	expected := make([]string, 0)
+	for idx, tenant := range tenants {
+		for n := 0; n < counts[idx]; n++ {
+			expected = append(expected, fmt.Sprintf("-----BEGIN CERTIFICATE-----\n%s-%02d\n-----END CERTIFICATE-----\n", tenant, n))
+		}
+	}
+
+	newRequest := func(maxResults int) *DiscoverCertificatesRequest {
+		return &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				TenantConcurrency: 2,
+				Tenants:           strings.Join(tenants, ","),
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: maxResults,
+			},
+		}
+	}
+
+	for _, maxResults := range []int{4, 50} {
+		t.Run(fmt.Sprintf("paged_%d_results", maxResults), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClientServices := mocks.NewMockClientServices(ctrl)
+			minTimes, maxTimes := getTenantDiscoveries(counts, maxResults, 2)
+			setupExpectBoundedClientUsage(t, mockClientServices, minTimes, maxTimes)
+			setupPagedDiscovery(mockClientServices, setupTenantCertificates(tenants, counts), "")
+
+			discoveryServices := NewDiscoveryService(mockClientServices)
+			request := newRequest(maxResults)
+
+			// the streamed discovery pages the same way as a discovery
+			discovered := make([]string, 0)
+			for requests := 0; ; requests++ {
+				require.Less(t, requests, len(expected)+len(tenants))
+
+				certificates, trailer, code := runStreamedDiscovery(t, e, discoveryServices, request)
+				require.Equal(t, http.StatusOK, code)
+				require.LessOrEqual(t, len(certificates), maxResults)
+				require.Empty(t, trailer.Failure)
+
+				for _, dc := range certificates {
+					discovered = append(discovered, dc.Certificate)
+				}
+
+				if trailer.Page == nil {
+					break
+				}
+
+				request.Page = trailer.Page
+			}
+
+			require.Equal(t, expected, discovered)
+		})
+	}
+
+	t.Run("tenant_failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 5)
+		setupPagedDiscovery(mockClientServices, setupTenantCertificates(tenants, counts), "green")
+
+		certificates, trailer, code := runStreamedDiscovery(t, e, NewDiscoveryService(mockClientServices), newRequest(50))
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, certificates, 11)
+		require.Nil(t, trailer.Page)
+		require.Equal(t, []*DiscoveryIssue{
+			{Tenant: "green", Reason: `failed to read VMware NSX-ALB certificates for the tenant "green": tenant green is unavailable`},
+		}, trailer.Errors)
+	})
+
+	t.Run("strict_failure_before_write", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 2)
+		setupPagedDiscovery(mockClientServices, setupTenantCertificates(tenants, counts), "admin")
+
+		request := newRequest(50)
+		request.Configuration.Strict = true
+
+		// nothing was written, so the failure is returned as a failed request
+		_, _, code := runStreamedDiscovery(t, e, NewDiscoveryService(mockClientServices), request)
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("strict_failure_after_write", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 4)
+		setupPagedDiscovery(mockClientServices, setupTenantCertificates(tenants, counts), "green")
+
+		request := newRequest(50)
+		request.Configuration.Strict = true
+
+		// the certificates of the tenants before the failed tenant were written, the trailer reports the failure
+		certificates, trailer, code := runStreamedDiscovery(t, e, NewDiscoveryService(mockClientServices), request)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, certificates, 3)
+		require.Nil(t, trailer.Page)
+		require.Equal(t, `failed to read VMware NSX-ALB certificates for the tenant "green": tenant green is unavailable`, trailer.Failure)
+	})
+
+	// the workers of the tenants after the tenant being written wait for the stream once their buffer is full
+	t.Run("buffered_tenants", func(t *testing.T) {
+		buffered := []string{"admin", "Blue", "green"}
+		bufferedCounts := []int{2*DefaultStreamBuffer + 1, 3 * DefaultStreamBuffer, DefaultStreamBuffer + 2}
+
+		bufferedExpected := make([]string, 0)
+		for idx, tenant := range buffered {
+			for n := 0; n < bufferedCounts[idx]; n++ {
+				bufferedExpected = append(bufferedExpected, fmt.Sprintf("-----BEGIN CERTIFICATE-----\n%s-%02d\n-----END CERTIFICATE-----\n", tenant, n))
+			}
+		}
+
+		for _, strict := range []bool{false, true} {
+			ctrl := gomock.NewController(t)
+
+			mockClientServices := mocks.NewMockClientServices(ctrl)
+			setupExpectClientUsage(t, mockClientServices, len(buffered))
+
+			failing := ""
+			if strict {
+				failing = "admin"
+			}
+			setupPagedDiscovery(mockClientServices, setupTenantCertificates(buffered, bufferedCounts), failing)
+
+			request := newRequest(100)
+			request.Configuration.TenantConcurrency = len(buffered)
+			request.Configuration.Tenants = strings.Join(buffered, ",")
+			request.Configuration.Strict = strict
+
+			certificates, trailer, code := runStreamedDiscovery(t, e, NewDiscoveryService(mockClientServices), request)
+			if strict {
+				// the failure is returned while the other tenants wait for the stream, and their workers are released
+				require.Equal(t, http.StatusBadRequest, code)
+			} else {
+				require.Equal(t, http.StatusOK, code)
+				require.Nil(t, trailer.Page)
+
+				discovered := make([]string, 0, len(certificates))
+				for _, dc := range certificates {
+					discovered = append(discovered, dc.Certificate)
+				}
+				require.Equal(t, bufferedExpected, discovered)
+			}
+
+			ctrl.Finish()
+		}
+	})
+
+	for _, wildcard := range []bool{false, true} {
+		t.Run(fmt.Sprintf("written_as_built_wildcard_%t", wildcard), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClientServices := mocks.NewMockClientServices(ctrl)
+			if wildcard {
+				setupExpectClientUsage(t, mockClientServices, 1)
+			} else {
+				setupExpectClientUsage(t, mockClientServices, 2)
+			}
+
+			certificates := setupTenantCertificates([]string{"admin", "Blue"}, []int{12, 2})
+			all := make([]*models.SSLKeyAndCertificate, 0)
+			for tenant, collection := range certificates {
+				for _, certificate := range collection {
+					certificate.TenantRef = toPointer("https://localhost/api/tenant/tenant-" + tenant + "#" + tenant)
+					all = append(all, certificate)
+				}
+			}
+
+			recorder := newStreamRecorder()
+
+			// the certificates of the first page are written before the next page of certificates is read
+			mockClientServices.EXPECT().
+				GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+					if after, _ := getParameterOptionsValue("uuid.gt", options...); len(after) > 0 {
+						require.Eventually(t, func() bool { return recorder.written() >= DefaultPageSize }, 5*time.Second, time.Millisecond)
+					}
+
+					if getTenantOptionValue(options...) == vmwareavi.WildcardTenantName {
+						return getCertificateCursorPage(all, options...), nil
+					}
+
+					return getCertificateCursorPage(certificates[client.Tenant], options...), nil
+				}).
+				AnyTimes()
+
+			setupExpectNoCertificateUsage(mockClientServices)
+			setupExpectNoVirtualServiceInventory(mockClientServices)
+
+			mockClientServices.EXPECT().
+				GetAllVirtualServices(gomock.Any(), gomock.Any()).
+				Return([]*models.VirtualService{}, nil).
+				AnyTimes()
+
+			request := newRequest(50)
+			request.Configuration.Tenants = "admin,Blue"
+			request.Configuration.WildcardTenant = wildcard
+
+			discovered, trailer, code := runStreamedDiscoveryTo(t, e, NewDiscoveryService(mockClientServices), request, recorder)
+			require.Equal(t, http.StatusOK, code)
+			require.Len(t, discovered, 14)
+			require.Nil(t, trailer.Page)
+			require.Empty(t, trailer.Errors)
+		})
+	}
+
+	t.Run("duplicates", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		leaf := generateTestCertificate(t, &x509.Certificate{
+			Subject: pkix.Name{CommonName: "www.example.com"},
+		}, nil, nil)
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		// the usage of the copies carried from the previous requests is read again with a session of each tenant, the
+		// copy of the second tenant is carried when its discovery is continued by a request finding no certificate
+		minTimes, maxTimes := getTenantDiscoveries([]int{1, 1}, 1, 2)
+		setupExpectBoundedClientUsage(t, mockClientServices, minTimes+1, maxTimes+2)
+		setupPagedDiscovery(mockClientServices, map[string][]*models.SSLKeyAndCertificate{
+			"admin": {toSSLKeyAndCertificate("copy-admin", leaf)},
+			"Blue":  {toSSLKeyAndCertificate("copy-blue", leaf)},
+		}, "")
+
+		request := newRequest(1)
+		request.Configuration.Tenants = "admin,Blue"
+
+		// the copies are carried by the discovery page, and grouped by the trailer of the completed discovery
+		var trailer *DiscoveryStreamTrailer
+		for requests := 0; ; requests++ {
+			require.Less(t, requests, 5)
+
+			var code int
+			_, trailer, code = runStreamedDiscovery(t, e, NewDiscoveryService(mockClientServices), request)
+			require.Equal(t, http.StatusOK, code)
+
+			if trailer.Page == nil {
+				break
+			}
+
+			require.Empty(t, trailer.Duplicates)
+			require.NotEmpty(t, trailer.Page.Copies)
+			request.Page = trailer.Page
+		}
+
+		require.Len(t, trailer.Duplicates, 1)
+		require.Equal(t, getFingerprint(leaf.certificate), trailer.Duplicates[0].Fingerprint)
+
+		names := make([]string, 0)
+		for _, copied := range trailer.Duplicates[0].Certificates {
+			names = append(names, copied.CertificateName)
+			require.True(t, copied.Unused)
+		}
+		require.Equal(t, []string{"copy-admin", "copy-blue"}, names)
+	})
+}
@@ -9,7 +9,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -72,25 +75,34 @@ func getParameterOptionsValue(key string, options ...session.ApiOptionsParams) (
 	return "", nil
 }
 
+// expectedDiscoveries is the number of tenant discoveries of a test, each with its own client session reading a single
+// page of certificates, and the number of virtual service indexes built. The maximum values include the tenants
+// discovered by parallel workers before the preceding tenants reached the results of the request.
+type expectedDiscoveries struct {
+	minTenants int
+	maxTenants int
+	minIndexes int
+	maxIndexes int
+}
+
 func setupDiscovery(
 	t *testing.T,
 	clientServices *mocks.MockClientServices,
-	maxResults int,
+	expected expectedDiscoveries,
 	sslKeysAndCertificates map[string][]*models.SSLKeyAndCertificate,
 	tenantVirtualServices map[string]map[string][]*models.VirtualService) (tdr *tenantDiscoveryResults, err error) {
 
 	var ok bool
 
-	times := setupExpectGetAllSSLKeysAndCertificates(clientServices, sslKeysAndCertificates, maxResults)
+	setupExpectGetAllSSLKeysAndCertificates(clientServices, sslKeysAndCertificates, expected.minTenants, expected.maxTenants)
 
-	setupExpectClientUsage(clientServices, times)
+	setupExpectBoundedClientUsage(t, clientServices, expected.minTenants, expected.maxTenants)
 
-	times = 0
-	for _, collection := range sslKeysAndCertificates {
-		times += len(collection)
-	}
+	setupExpectNoCertificateUsage(clientServices)
+	setupExpectNoVirtualServiceInventory(clientServices)
 
-	clientServices.EXPECT().
+	// the virtual services of a tenant are indexed when its discovery starts and reused by the requests continuing it
+	expectBetween(clientServices.EXPECT().
 		GetAllVirtualServices(gomock.Any(), gomock.Any()).
 		DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
 			var referencedVirtualServices map[string][]*models.VirtualService
@@ -102,15 +114,11 @@ func setupDiscovery(
 
 			refersTo, err = getParameterOptionsValue("refers_to", options...)
 			require.NoError(t, err)
-			require.NotEmpty(t, refersTo)
-
-			var virtualServices []*models.VirtualService
-			virtualServices, ok = referencedVirtualServices[refersTo]
-			require.True(t, ok)
+			require.Empty(t, refersTo)
 
-			return virtualServices, nil
-		}).
-		Times(times)
+			return getIndexedVirtualServices(referencedVirtualServices), nil
+		}),
+		expected.minIndexes, expected.maxIndexes)
 
 	tdr = newTenantDiscoveryResults()
 	for tenant, certificates := range sslKeysAndCertificates {
@@ -145,6 +153,7 @@ func setupDiscovery(
 					},
 					Binding: &domain.Binding{
 						VirtualServiceName: getVirtualServiceName(vs),
+						UsageType:          domain.UsageTypeVirtualService,
 					},
 				}
 
@@ -159,56 +168,132 @@ func setupDiscovery(
 	return tdr, nil
 }
 
-func setupExpectGetAllSSLKeysAndCertificates(clientServices *mocks.MockClientServices, responses map[string][]*models.SSLKeyAndCertificate, maxResults int) int {
-	limit := maxResults
-	times := 0
-	for _, collection := range responses {
-		count := len(collection)
-		for count > limit {
-			times++
-
-			count -= limit
-
-			limit = maxResults
+// getIndexedVirtualServices will return a copy of the virtual services referencing each certificate, with the
+// certificate reference set as returned by VMware
+func getIndexedVirtualServices(referencedVirtualServices map[string][]*models.VirtualService) []*models.VirtualService {
+	virtualServices := make([]*models.VirtualService, 0)
+	for refersTo, referencing := range referencedVirtualServices {
+		ref := "https://localhost/api/sslkeyandcertificate/" + strings.TrimPrefix(refersTo, "sslkeyandcertificate:")
+		for _, vs := range referencing {
+			clone := *vs
+			clone.SslKeyAndCertificateRefs = []string{ref}
+			virtualServices = append(virtualServices, &clone)
 		}
-
-		limit -= count
-
-		times++
 	}
 
-	clientServices.EXPECT().
+	return virtualServices
+}
+
+// setupExpectGetAllSSLKeysAndCertificates will expect between minTimes and maxTimes reads of a page of certificates, a
+// read beyond minTimes is made by a parallel worker discovering a tenant whose results can be discarded
+func setupExpectGetAllSSLKeysAndCertificates(clientServices *mocks.MockClientServices, responses map[string][]*models.SSLKeyAndCertificate, minTimes int, maxTimes int) {
+	expectBetween(clientServices.EXPECT().
 		GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
 		DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
 			certificates, ok := responses[client.Tenant]
 			if ok {
-				return certificates, nil
+				return getCertificateCursorPage(certificates, options...), nil
 			}
 
 			return nil, fmt.Errorf("%s: That page contains no results", client.Tenant)
-		}).
-		Times(times)
+		}),
+		minTimes, maxTimes)
+}
+
+// getCertificateCursorPage will return the page of certificates following the uuid.gt cursor, or of the uuid.in
+// certificates, in UUID order as VMware does for the discovery paginator. A numbered page, as read by a scan of every
+// certificate, is the page of the certificates following the cursor.
+func getCertificateCursorPage(certificates []*models.SSLKeyAndCertificate, options ...session.ApiOptionsParams) []*models.SSLKeyAndCertificate {
+	after, _ := getParameterOptionsValue("uuid.gt", options...)
+	in, _ := getParameterOptionsValue("uuid.in", options...)
+	pageSize, _ := getParameterOptionsValue("page_size", options...)
+	pageNumber, _ := getParameterOptionsValue("page", options...)
+
+	size, err := strconv.Atoi(pageSize)
+	if err != nil || size < 1 {
+		size = len(certificates)
+	}
+
+	skip := 0
+	if number, numberErr := strconv.Atoi(pageNumber); numberErr == nil && number > 1 {
+		skip = (number - 1) * size
+	}
+
+	sorted := slices.Clone(certificates)
+	slices.SortFunc(sorted, func(a, b *models.SSLKeyAndCertificate) int { return strings.Compare(*a.UUID, *b.UUID) })
+
+	page := make([]*models.SSLKeyAndCertificate, 0, size)
+	for _, certificate := range sorted {
+		if len(page) == size {
+			break
+		}
 
-	return times
+		if len(in) > 0 && !slices.Contains(strings.Split(in, ","), *certificate.UUID) {
+			continue
+		}
+
+		if *certificate.UUID <= after {
+			continue
+		}
+
+		if skip > 0 {
+			skip--
+			continue
+		}
+
+		page = append(page, certificate)
+	}
+
+	return page
+}
+
+// setupExpectClientUsage will expect times client sessions and check every session is closed
+func setupExpectClientUsage(tb testing.TB, clientServices *mocks.MockClientServices, times int) {
+	setupExpectBoundedClientUsage(tb, clientServices, times, times)
 }
 
-func setupExpectClientUsage(clientServices *mocks.MockClientServices, times int) {
-	clientServices.EXPECT().
+// setupExpectBoundedClientUsage will expect between minTimes and maxTimes client sessions and check every session is
+// closed, a session beyond minTimes is opened by a parallel worker discovering a tenant whose results can be discarded
+func setupExpectBoundedClientUsage(tb testing.TB, clientServices *mocks.MockClientServices, minTimes int, maxTimes int) {
+	var open atomic.Int32
+
+	expectBetween(clientServices.EXPECT().
 		NewClient(gomock.Any(), gomock.Any()).
 		DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+			open.Add(1)
 			return &domain.Client{
 				Connection: connection,
 				Tenant:     tenant,
 			}
-		}).
-		Times(times)
-	clientServices.EXPECT().
+		}),
+		minTimes, maxTimes)
+	expectBetween(clientServices.EXPECT().
 		Connect(gomock.Any()).
-		Return(nil).
-		Times(times)
-	clientServices.EXPECT().
+		Return(nil),
+		minTimes, maxTimes)
+	expectBetween(clientServices.EXPECT().
 		Close(gomock.Any()).
-		Times(times)
+		Do(func(client *domain.Client) {
+			open.Add(-1)
+		}),
+		minTimes, maxTimes)
+
+	tb.Cleanup(func() {
+		require.Zero(tb, open.Load())
+	})
+}
+
+// expectBetween will expect between minTimes and maxTimes calls, MaxTimes clearing a minimum of one and MinTimes
+// clearing a maximum of one
+func expectBetween(call *gomock.Call, minTimes int, maxTimes int) *gomock.Call {
+	switch {
+	case minTimes == maxTimes:
+		return call.Times(minTimes)
+	case minTimes == 1:
+		return call.MaxTimes(maxTimes).MinTimes(minTimes)
+	default:
+		return call.MinTimes(minTimes).MaxTimes(maxTimes)
+	}
 }
 
 func setupPost(e *echo.Echo, path string, body io.Reader) (*httptest.ResponseRecorder, echo.Context) {
@@ -300,7 +385,7 @@ func TestDiscovery(t *testing.T) {
 
 		tdr, err = setupDiscovery(t,
 			mockClientServices,
-			request.Control.MaxResults,
+			expectedDiscoveries{minTenants: 1, maxTenants: 1, minIndexes: 1, maxIndexes: 1},
 			map[string][]*models.SSLKeyAndCertificate{
 				"admin": []*models.SSLKeyAndCertificate{
 					&models.SSLKeyAndCertificate{
@@ -381,7 +466,7 @@ func TestDiscovery(t *testing.T) {
 
 		tdr, err = setupDiscovery(t,
 			mockClientServices,
-			request.Control.MaxResults,
+			expectedDiscoveries{minTenants: 2, maxTenants: 2, minIndexes: 1, maxIndexes: 1},
 			map[string][]*models.SSLKeyAndCertificate{
 				"admin": []*models.SSLKeyAndCertificate{
 					&models.SSLKeyAndCertificate{
@@ -500,7 +585,7 @@ func TestDiscovery(t *testing.T) {
 
 		tdr, err = setupDiscovery(t,
 			mockClientServices,
-			request.Control.MaxResults,
+			expectedDiscoveries{minTenants: 3, maxTenants: 3, minIndexes: 3, maxIndexes: 3},
 			map[string][]*models.SSLKeyAndCertificate{
 				"admin": []*models.SSLKeyAndCertificate{
 					&models.SSLKeyAndCertificate{
@@ -650,9 +735,11 @@ func TestDiscovery(t *testing.T) {
 			Page: nil,
 		}
 
+		// the first request stops at Swordfish, and Venafi is discovered by a parallel worker when it starts before admin
+		// and Swordfish finished
 		tdr, err = setupDiscovery(t,
 			mockClientServices,
-			request.Control.MaxResults,
+			expectedDiscoveries{minTenants: 4, maxTenants: 5, minIndexes: 3, maxIndexes: 4},
 			map[string][]*models.SSLKeyAndCertificate{
 				"admin": []*models.SSLKeyAndCertificate{
 					&models.SSLKeyAndCertificate{
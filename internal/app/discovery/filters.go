@@ -0,0 +1,257 @@
+package discovery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vmware/alb-sdk/go/models"
+)
+
+const (
+	// CertificateTypeSystem selects the system certificates, such as the controller portal certificate
+	CertificateTypeSystem = "system"
+	// CertificateTypeVirtualService selects the certificates for use by virtual services
+	CertificateTypeVirtualService = "virtualService"
+	// CertificateTypeCA selects the CA certificates
+	CertificateTypeCA = "ca"
+)
+
+// certificateTypes maps the manifest certificate types to the VMware certificate types
+var certificateTypes = map[string]string{
+	CertificateTypeSystem:         "SSL_CERTIFICATE_TYPE_SYSTEM",
+	CertificateTypeVirtualService: "SSL_CERTIFICATE_TYPE_VIRTUALSERVICE",
+	CertificateTypeCA:             "SSL_CERTIFICATE_TYPE_CA",
+}
+
+// namePattern is a compiled name filter, the literal is set when the pattern is a glob and holds the longest part
+// of the pattern without wildcards
+type namePattern struct {
+	expression *regexp.Regexp
+	literal    string
+}
+
+// namePatterns is a collection of name filters, matching a name when any of the filters match
+type namePatterns []*namePattern
+
+// splitNamePatterns will split a comma separated list of name filters. A comma inside a regular expression enclosed in
+// slashes, such as /^web-\d{1,3}$/, does not end the filter, and the regular expression ends at a slash followed by a
+// comma or the end of the list.
+func splitNamePatterns(value string) []string {
+	entries := make([]string, 0)
+
+	start := 0
+	expression := false
+	for idx, r := range value {
+		switch {
+		case r == '/' && !expression && len(strings.TrimSpace(value[start:idx])) == 0:
+			expression = true
+		case r == '/' && expression:
+			if rest := strings.TrimSpace(value[idx+1:]); len(rest) == 0 || rest[0] == ',' {
+				expression = false
+			}
+		case r == ',' && !expression:
+			entries = append(entries, value[start:idx])
+			start = idx + 1
+		}
+	}
+
+	if expression {
+		// a regular expression with no closing slash is split like any other filter
+		return append(entries, strings.Split(value[start:], ",")...)
+	}
+
+	return append(entries, value[start:])
+}
+
+// compileNamePatterns will compile a comma separated list of case-insensitive name filters. A filter enclosed in
+// slashes, such as /^web-[0-9]+$/, is a regular expression, and any other filter is a glob where * matches any
+// sequence of characters and ? matches a single character.
+func compileNamePatterns(value string) (namePatterns, error) {
+	patterns := make(namePatterns, 0)
+
+	for _, entry := range splitNamePatterns(value) {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+			expression, err := regexp.Compile("(?i)" + entry[1:len(entry)-1])
+			if err != nil {
+				return nil, fmt.Errorf(`invalid regular expression "%s": %w`, entry, err)
+			}
+
+			patterns = append(patterns, &namePattern{expression: expression})
+			continue
+		}
+
+		var sb strings.Builder
+		literal := ""
+		current := ""
+
+		sb.WriteString("(?i)^")
+		for _, r := range entry {
+			switch r {
+			case '*':
+				sb.WriteString(".*")
+			case '?':
+				sb.WriteString(".")
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(r)))
+				current += string(r)
+				continue
+			}
+
+			if len(current) > len(literal) {
+				literal = current
+			}
+			current = ""
+		}
+		sb.WriteString("$")
+
+		if len(current) > len(literal) {
+			literal = current
+		}
+
+		patterns = append(patterns, &namePattern{
+			expression: regexp.MustCompile(sb.String()),
+			literal:    literal,
+		})
+	}
+
+	return patterns, nil
+}
+
+func (patterns namePatterns) matches(name string) bool {
+	for _, pattern := range patterns {
+		if pattern.expression.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// includes will check the name against include and exclude filters, an empty include filter includes every name
+func includes(include, exclude namePatterns, name string) bool {
+	if len(include) > 0 && !include.matches(name) {
+		return false
+	}
+
+	return !exclude.matches(name)
+}
+
+// discoveryFilters are the compiled discovery filters of the discovery configuration
+type discoveryFilters struct {
+	certificateInclude    namePatterns
+	certificateExclude    namePatterns
+	virtualServiceInclude namePatterns
+	virtualServiceExclude namePatterns
+	tenantExclude         namePatterns
+	certificateTypes      []string
+}
+
+// compileFilters will validate and compile the discovery filters of the configuration
+func (configuration *DiscoverCertificatesConfiguration) compileFilters() error {
+	var err error
+
+	filters := &discoveryFilters{}
+
+	if filters.certificateInclude, err = compileNamePatterns(configuration.CertificateNameInclude); err != nil {
+		return fmt.Errorf("invalid certificate name include filter: %w", err)
+	}
+
+	if filters.certificateExclude, err = compileNamePatterns(configuration.CertificateNameExclude); err != nil {
+		return fmt.Errorf("invalid certificate name exclude filter: %w", err)
+	}
+
+	if filters.virtualServiceInclude, err = compileNamePatterns(configuration.VirtualServiceNameInclude); err != nil {
+		return fmt.Errorf("invalid virtual service name include filter: %w", err)
+	}
+
+	if filters.virtualServiceExclude, err = compileNamePatterns(configuration.VirtualServiceNameExclude); err != nil {
+		return fmt.Errorf("invalid virtual service name exclude filter: %w", err)
+	}
+
+	if filters.tenantExclude, err = compileNamePatterns(configuration.ExcludeTenants); err != nil {
+		return fmt.Errorf("invalid tenant exclude filter: %w", err)
+	}
+
+	if configuration.Incremental && configuration.WildcardTenant {
+		return fmt.Errorf("incremental discovery is not supported with the wildcard tenant discovery")
+	}
+
+	if configuration.ExpiringWithinDays < 0 {
+		return fmt.Errorf("invalid expiring within days value %d", configuration.ExpiringWithinDays)
+	}
+
+	for _, value := range configuration.CertificateTypes {
+		certificateType, ok := certificateTypes[value]
+		if !ok {
+			return fmt.Errorf(`invalid certificate type "%s"`, value)
+		}
+
+		filters.certificateTypes = append(filters.certificateTypes, certificateType)
+	}
+
+	configuration.filters = filters
+	return nil
+}
+
+// getCertificateSearchParams will return the controller query parameters selecting the certificates of the filters,
+// a name filter is only pushed down to the controller when it is a single glob
+func (filters *discoveryFilters) getCertificateSearchParams() map[string]string {
+	params := map[string]string{
+		"search": DefaultCertificateSearch,
+	}
+
+	if filters == nil {
+		return params
+	}
+
+	if len(filters.certificateTypes) > 0 {
+		search := make([]string, 0, len(filters.certificateTypes))
+		for _, certificateType := range filters.certificateTypes {
+			search = append(search, fmt.Sprintf("(type,%s)", certificateType))
+		}
+
+		params["search"] = strings.Join(search, "|")
+	}
+
+	if len(filters.certificateInclude) == 1 && len(filters.certificateInclude[0].literal) > 0 {
+		params["name.icontains"] = filters.certificateInclude[0].literal
+	}
+
+	return params
+}
+
+func (filters *discoveryFilters) includesCertificate(certificate *models.SSLKeyAndCertificate) bool {
+	if filters == nil {
+		return true
+	}
+
+	if len(filters.certificateTypes) > 0 && certificate.Type != nil {
+		found := false
+		for _, certificateType := range filters.certificateTypes {
+			if strings.EqualFold(certificateType, *certificate.Type) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return includes(filters.certificateInclude, filters.certificateExclude, getCertificateName(certificate))
+}
+
+func (filters *discoveryFilters) includesTenant(tenant string) bool {
+	return filters == nil || !filters.tenantExclude.matches(tenant)
+}
+
+func (filters *discoveryFilters) includesVirtualService(name string) bool {
+	return filters == nil || includes(filters.virtualServiceInclude, filters.virtualServiceExclude, name)
+}
@@ -0,0 +1,292 @@
+package discovery
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDiscoveryFilters(t *testing.T) {
+	t.Run("name_patterns", func(t *testing.T) {
+		patterns, err := compileNamePatterns(" web-* , api-?.example.com,/^legacy-[0-9]+$/,")
+		require.NoError(t, err)
+		require.Len(t, patterns, 3)
+
+		require.True(t, patterns.matches("web-frontend"))
+		require.True(t, patterns.matches("WEB-"))
+		require.False(t, patterns.matches("my-web-frontend"))
+		require.True(t, patterns.matches("api-1.example.com"))
+		require.False(t, patterns.matches("api-12.example.com"))
+		require.False(t, patterns.matches("api-1xexample.com"))
+		require.True(t, patterns.matches("Legacy-42"))
+		require.False(t, patterns.matches("legacy-42a"))
+
+		require.Equal(t, "web-", patterns[0].literal)
+		require.Equal(t, ".example.com", patterns[1].literal)
+		require.Empty(t, patterns[2].literal)
+
+		patterns, err = compileNamePatterns("")
+		require.NoError(t, err)
+		require.Empty(t, patterns)
+		require.False(t, patterns.matches("anything"))
+
+		_, err = compileNamePatterns("/[/")
+		require.Error(t, err)
+
+		// a comma of a regular expression quantifier does not split the filter
+		patterns, err = compileNamePatterns(`/^web-\d{1,3}$/, api-*, /^a/b$/`)
+		require.NoError(t, err)
+		require.Len(t, patterns, 3)
+
+		require.True(t, patterns.matches("web-12"))
+		require.False(t, patterns.matches("web-1234"))
+		require.True(t, patterns.matches("api-gateway"))
+		require.True(t, patterns.matches("a/b"))
+
+		require.Equal(t, []string{"/abc", "def"}, splitNamePatterns("/abc,def"))
+	})
+
+	t.Run("compile", func(t *testing.T) {
+		configuration := &DiscoverCertificatesConfiguration{}
+		require.NoError(t, configuration.compileFilters())
+		require.NotNil(t, configuration.filters)
+		require.Equal(t, map[string]string{"search": DefaultCertificateSearch}, configuration.filters.getCertificateSearchParams())
+
+		configuration = &DiscoverCertificatesConfiguration{
+			CertificateNameInclude: "*.venafi.com",
+			CertificateTypes:       []string{CertificateTypeCA, CertificateTypeSystem},
+		}
+		require.NoError(t, configuration.compileFilters())
+		require.Equal(t, map[string]string{
+			"name.icontains": ".venafi.com",
+			"search":         "(type,SSL_CERTIFICATE_TYPE_CA)|(type,SSL_CERTIFICATE_TYPE_SYSTEM)",
+		}, configuration.filters.getCertificateSearchParams())
+
+		// several include filters are only applied by the connector
+		configuration = &DiscoverCertificatesConfiguration{
+			CertificateNameInclude: "a*,b*",
+		}
+		require.NoError(t, configuration.compileFilters())
+		require.NotContains(t, configuration.filters.getCertificateSearchParams(), "name.icontains")
+
+		for _, invalid := range []*DiscoverCertificatesConfiguration{
+			{CertificateNameInclude: "/(/"},
+			{CertificateNameExclude: "/(/"},
+			{VirtualServiceNameInclude: "/(/"},
+			{VirtualServiceNameExclude: "/(/"},
+			{ExcludeTenants: "/(/"},
+			{CertificateTypes: []string{"SSL_CERTIFICATE_TYPE_CA"}},
+			{ExpiringWithinDays: -1},
+		} {
+			require.Error(t, invalid.compileFilters())
+		}
+	})
+
+	t.Run("includes", func(t *testing.T) {
+		configuration := &DiscoverCertificatesConfiguration{
+			CertificateNameExclude:    "*-test",
+			CertificateNameInclude:    "web-*",
+			CertificateTypes:          []string{CertificateTypeVirtualService},
+			ExcludeTenants:            "sandbox-*",
+			VirtualServiceNameExclude: "/-staging$/",
+		}
+		require.NoError(t, configuration.compileFilters())
+
+		filters := configuration.filters
+
+		require.True(t, filters.includesCertificate(&models.SSLKeyAndCertificate{Name: toPointer("web-1")}))
+		require.True(t, filters.includesCertificate(&models.SSLKeyAndCertificate{Name: toPointer("web-1"), Type: toPointer("SSL_CERTIFICATE_TYPE_VIRTUALSERVICE")}))
+		require.False(t, filters.includesCertificate(&models.SSLKeyAndCertificate{Name: toPointer("web-1"), Type: toPointer("SSL_CERTIFICATE_TYPE_CA")}))
+		require.False(t, filters.includesCertificate(&models.SSLKeyAndCertificate{Name: toPointer("web-1-test")}))
+		require.False(t, filters.includesCertificate(&models.SSLKeyAndCertificate{Name: toPointer("api-1")}))
+
+		require.True(t, filters.includesTenant("admin"))
+		require.False(t, filters.includesTenant("Sandbox-1"))
+
+		require.True(t, filters.includesVirtualService("shop"))
+		require.False(t, filters.includesVirtualService("shop-staging"))
+
+		var none *discoveryFilters
+		require.True(t, none.includesCertificate(&models.SSLKeyAndCertificate{Name: toPointer("web-1")}))
+		require.True(t, none.includesTenant("admin"))
+		require.True(t, none.includesVirtualService("shop"))
+	})
+}
+
+func TestFilteredDiscovery(t *testing.T) {
+	e := echo.New()
+
+	tenants := []string{"admin", "sandbox-1", "Venafi"}
+	certificates := setupTenantCertificates(tenants, []int{4, 2, 3})
+
+	for idx, days := range []int{5, 90, -1} {
+		generated := generateTestCertificate(t, &x509.Certificate{
+			Subject:   pkix.Name{CommonName: *certificates["Venafi"][idx].Name},
+			NotBefore: time.Now().AddDate(0, 0, -30),
+			NotAfter:  time.Now().AddDate(0, 0, days),
+		}, nil, nil)
+		certificates["Venafi"][idx].Certificate.Certificate = &generated.pem
+	}
+
+	setup := func(t *testing.T) (*DiscoveryService, *[]map[string]string) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 1)
+
+		searches := make([]map[string]string, 0)
+
+		mockClientServices.EXPECT().
+			GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+				search, _ := getParameterOptionsValue("search", options...)
+				contains, _ := getParameterOptionsValue("name.icontains", options...)
+				searches = append(searches, map[string]string{"search": search, "name.icontains": contains})
+
+				return getCertificateCursorPage(certificates[client.Tenant], options...), nil
+			}).
+			AnyTimes()
+
+		setupExpectNoCertificateUsage(mockClientServices)
+		setupExpectNoVirtualServiceInventory(mockClientServices)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+				virtualServices := make([]*models.VirtualService, 0)
+				for _, certificate := range certificates[client.Tenant] {
+					for _, name := range []string{"shop", "shop-staging"} {
+						virtualServices = append(virtualServices, &models.VirtualService{
+							Name:                     toPointer(*certificate.Name + "-" + name),
+							SslKeyAndCertificateRefs: []string{*certificate.URL},
+						})
+					}
+				}
+
+				return virtualServices, nil
+			}).
+			AnyTimes()
+
+		return NewDiscoveryService(mockClientServices), &searches
+	}
+
+	newRequest := func(configuration DiscoverCertificatesConfiguration) *DiscoverCertificatesRequest {
+		configuration.TenantConcurrency = 1
+		return &DiscoverCertificatesRequest{
+			Configuration: configuration,
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 100,
+			},
+		}
+	}
+
+	getNames := func(response *DiscoverCertificatesResponse) map[string][]string {
+		names := map[string][]string{}
+		for _, dc := range response.Messages {
+			for _, mi := range dc.MachineIdentities {
+				names[mi.Keystore.CertificateName] = append(names[mi.Keystore.CertificateName], mi.Binding.VirtualServiceName)
+			}
+		}
+
+		return names
+	}
+
+	t.Run("names", func(t *testing.T) {
+		discoveryServices, searches := setup(t)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, newRequest(DiscoverCertificatesConfiguration{
+			CertificateNameExclude:    "*-01",
+			CertificateNameInclude:    "admin-*",
+			ExcludeTenants:            "sandbox-*",
+			Tenants:                   "admin,sandbox-1",
+			VirtualServiceNameExclude: "*-staging",
+		}))
+		require.Equal(t, http.StatusOK, code)
+		require.Nil(t, response.Page)
+
+		require.Equal(t, map[string][]string{
+			"admin-00": {"admin-00-shop"},
+			"admin-02": {"admin-02-shop"},
+			"admin-03": {"admin-03-shop"},
+		}, getNames(response))
+
+		require.NotEmpty(t, *searches)
+		for _, search := range *searches {
+			require.Equal(t, DefaultCertificateSearch, search["search"])
+			require.Equal(t, "admin-", search["name.icontains"])
+		}
+	})
+
+	t.Run("inactive_after_virtual_service_filter", func(t *testing.T) {
+		discoveryServices, _ := setup(t)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, newRequest(DiscoverCertificatesConfiguration{
+			ExcludeInactiveCertificates: true,
+			Tenants:                     "admin",
+			VirtualServiceNameInclude:   "/^admin-0[12]-shop$/",
+		}))
+		require.Equal(t, http.StatusOK, code)
+
+		require.Equal(t, map[string][]string{
+			"admin-01": {"admin-01-shop"},
+			"admin-02": {"admin-02-shop"},
+		}, getNames(response))
+		require.Len(t, response.Messages, 2)
+	})
+
+	t.Run("types_and_expiring", func(t *testing.T) {
+		discoveryServices, searches := setup(t)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, newRequest(DiscoverCertificatesConfiguration{
+			CertificateTypes:   []string{CertificateTypeVirtualService},
+			ExpiringWithinDays: 30,
+			Tenants:            "Venafi",
+		}))
+		require.Equal(t, http.StatusOK, code)
+
+		// the expired certificate is expiring within any number of days
+		require.Len(t, response.Messages, 2)
+		require.Contains(t, getNames(response), "Venafi-00")
+		require.Contains(t, getNames(response), "Venafi-02")
+
+		for _, dc := range response.Messages {
+			require.NotNil(t, dc.Metadata)
+			require.True(t, dc.Metadata.SelfSigned)
+			require.Equal(t, "ECDSA", dc.Metadata.KeyAlgorithm)
+		}
+
+		for _, search := range *searches {
+			require.Equal(t, "(type,SSL_CERTIFICATE_TYPE_VIRTUALSERVICE)", search["search"])
+			require.Empty(t, search["name.icontains"])
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		discoveryServices := NewDiscoveryService(mocks.NewMockClientServices(ctrl))
+
+		_, code := runTenantDiscovery(t, e, discoveryServices, newRequest(DiscoverCertificatesConfiguration{
+			CertificateNameInclude: "/[a-/",
+			Tenants:                "admin",
+		}))
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+}
@@ -0,0 +1,245 @@
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultScanPageSize is the number of objects per paged request when scanning a tenant for changes, the scan
+	// only reads the identifying fields so the pages are larger than the discovery pages
+	DefaultScanPageSize = 200
+)
+
+// TenantWatermark is the high-water mark of the discovery of a tenant, used by an incremental discovery to only
+// discover the certificates and virtual services changed since the previous discovery
+type TenantWatermark struct {
+	// The largest _last_modified value of the certificates and virtual services of the tenant
+	LastModified string `json:"lastModified"`
+	// The digest of the UUIDs of the certificates of the tenant, used to find whether a certificate was deleted since
+	CertificateDigest *ObjectDigest `json:"certificateDigest,omitempty"`
+	// The digest of the references of the virtual services to the certificates of the tenant, used to find whether a
+	// reference was removed since
+	ReferenceDigest *ObjectDigest `json:"referenceDigest,omitempty"`
+}
+
+// ObjectDigest is the count and the combined SHA-256 hash of a set of objects, so the watermark does not grow with the
+// objects of the tenant
+type ObjectDigest struct {
+	// The number of objects
+	Count int `json:"count"`
+	// The hex encoded XOR of the SHA-256 hash of each object
+	Hash string `json:"hash"`
+}
+
+// objectDigest is the digest of a set of objects being built, each object must only be added once
+type objectDigest struct {
+	count int
+	hash  [sha256.Size]byte
+}
+
+// add will add the object to the digest
+func (d *objectDigest) add(object string) {
+	sum := sha256.Sum256([]byte(object))
+	for idx := range d.hash {
+		d.hash[idx] ^= sum[idx]
+	}
+
+	d.count++
+}
+
+// digest will return the digest of the objects added
+func (d *objectDigest) digest() *ObjectDigest {
+	return &ObjectDigest{
+		Count: d.count,
+		Hash:  hex.EncodeToString(d.hash[:]),
+	}
+}
+
+// isUnchanged will return true when no object of the previous digest was removed, either the objects are the same or
+// the objects added since are exactly the modified objects. An object set both modified and added since is not told
+// apart from a removed object, so it is reported as a possible removal.
+func isUnchanged(previous *ObjectDigest, current, unmodified *objectDigest) bool {
+	if previous == nil {
+		return false
+	}
+
+	return *previous == *current.digest() || *previous == *unmodified.digest()
+}
+
+// tenantChanges are the changes of a tenant since the previous incremental discovery
+type tenantChanges struct {
+	watermark *TenantWatermark
+	// changed are the sorted UUIDs of the certificates to discover, nil when every certificate is discovered
+	changed []string
+	// resynchronized is set when a certificate or a reference of a virtual service may have been removed since the
+	// watermark, every certificate of the tenant is then discovered
+	resynchronized bool
+}
+
+// after will return up to count changed certificate UUIDs following the UUID
+func (changes *tenantChanges) after(uuid string, count int) []string {
+	idx, _ := slices.BinarySearch(changes.changed, uuid)
+	if idx < len(changes.changed) && changes.changed[idx] == uuid {
+		idx++
+	}
+
+	return changes.changed[idx:min(idx+count, len(changes.changed))]
+}
+
+// isLaterModified will compare two _last_modified values, which are the microseconds since the epoch
+func isLaterModified(value, than string) bool {
+	if len(value) != len(than) {
+		return len(value) > len(than)
+	}
+
+	return value > than
+}
+
+// scanTenantChanges will read the identifying fields of the certificates and of the virtual services of the tenant,
+// to find the certificates to discover and the new watermark of the tenant. A certificate is changed when it was
+// modified, or a virtual service referencing it was modified, since the watermark. When the digests of the watermark
+// show a certificate or a reference of a virtual service may have been removed since, the tenant is resynchronized
+// and every certificate is discovered, so the deleted certificates and the stale usage are replaced.
+func scanTenantChanges(client *domain.Client, clientServices vmwareavi.ClientServices, filters *discoveryFilters, since *TenantWatermark) (*tenantChanges, error) {
+	changes := &tenantChanges{
+		watermark: &TenantWatermark{},
+	}
+
+	if since != nil {
+		changes.watermark.LastModified = since.LastModified
+	}
+
+	// included are the certificates of the tenant matching the discovery filters
+	included := map[string]bool{}
+	changed := map[string]bool{}
+
+	// the digests of every certificate and reference, and of the ones not modified since the watermark
+	var certificateDigest, unmodifiedCertificates, referenceDigest, unmodifiedReferences objectDigest
+
+	params := filters.getCertificateSearchParams()
+	params["fields"] = "uuid,name,type,_last_modified"
+	params["page_size"] = strconv.Itoa(DefaultScanPageSize)
+	params["sort"] = "uuid"
+
+	certificates, err := vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+		return clientServices.GetAllSSLKeysAndCertificates(client, options...)
+	}, params)
+	if err != nil {
+		zap.L().Info("failed to scan certificates for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+		return nil, err
+	}
+
+	for _, certificate := range certificates {
+		uuid := getCertificateUUID(certificate)
+		if len(uuid) == 0 || certificate.Name == nil || !filters.includesCertificate(certificate) || included[uuid] {
+			continue
+		}
+
+		included[uuid] = true
+		certificateDigest.add(uuid)
+
+		lastModified := getValue(certificate.LastModified)
+		if certificate.LastModified == nil || since == nil || isLaterModified(lastModified, since.LastModified) {
+			changed[uuid] = true
+		} else {
+			unmodifiedCertificates.add(uuid)
+		}
+
+		if certificate.LastModified != nil && isLaterModified(lastModified, changes.watermark.LastModified) {
+			changes.watermark.LastModified = lastModified
+		}
+	}
+
+	references := map[string]bool{}
+	err = scanVirtualServices(client, clientServices, func(vs *models.VirtualService) {
+		if vs.LastModified != nil && isLaterModified(*vs.LastModified, changes.watermark.LastModified) {
+			changes.watermark.LastModified = *vs.LastModified
+		}
+
+		modified := vs.LastModified == nil || since == nil || isLaterModified(*vs.LastModified, since.LastModified)
+
+		for _, ref := range vs.SslKeyAndCertificateRefs {
+			id, refErr := getUUIDFromURL(ref)
+			if refErr != nil || !included[id] {
+				continue
+			}
+
+			reference := getValue(vs.UUID) + "/" + id
+			if references[reference] {
+				continue
+			}
+
+			references[reference] = true
+			referenceDigest.add(reference)
+
+			if modified {
+				changed[id] = true
+			} else {
+				unmodifiedReferences.add(reference)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changes.watermark.CertificateDigest = certificateDigest.digest()
+	changes.watermark.ReferenceDigest = referenceDigest.digest()
+
+	if since == nil {
+		zap.L().Info("scanned certificates for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Int("certificates", len(included)))
+		return changes, nil
+	}
+
+	changes.changed = make([]string, 0, len(changed))
+	for uuid := range changed {
+		changes.changed = append(changes.changed, uuid)
+	}
+	slices.Sort(changes.changed)
+
+	changes.resynchronized = !isUnchanged(since.CertificateDigest, &certificateDigest, &unmodifiedCertificates) ||
+		!isUnchanged(since.ReferenceDigest, &referenceDigest, &unmodifiedReferences)
+
+	zap.L().Info("scanned certificate changes for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("since", since.LastModified), zap.Int("changed", len(changes.changed)), zap.Bool("resynchronized", changes.resynchronized))
+	return changes, nil
+}
+
+// scanVirtualServices will read the identifying fields and the certificate references of every virtual service. The
+// certificates of the admin tenant can be used by the virtual services of other tenants.
+func scanVirtualServices(client *domain.Client, clientServices vmwareavi.ClientServices, process func(vs *models.VirtualService)) error {
+	admin := strings.EqualFold(client.Tenant, vmwareavi.DefaultTenantName)
+
+	params := map[string]string{
+		"fields":    "uuid,ssl_key_and_certificate_refs,_last_modified",
+		"page_size": strconv.Itoa(DefaultScanPageSize),
+	}
+
+	var options []session.ApiOptionsParams
+	if admin {
+		options = append(options, session.SetOptTenant(vmwareavi.WildcardTenantName))
+	}
+
+	virtualServices, err := vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+		return clientServices.GetAllVirtualServices(client, options...)
+	}, params, options...)
+	if err != nil {
+		zap.L().Info("failed to scan virtual services for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+		return err
+	}
+
+	for _, vs := range virtualServices {
+		process(vs)
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+// incrementalController holds the certificates and virtual services of the mock controller, so they can be changed
+// between discoveries
+type incrementalController struct {
+	mutex           sync.Mutex
+	certificates    map[string][]*models.SSLKeyAndCertificate
+	virtualServices map[string][]*models.VirtualService
+	// fetched are the certificates read with their content, by tenant
+	fetched map[string][]string
+}
+
+// setupIncrementalController will expect between minSessions and maxSessions tenant discoveries
+func setupIncrementalController(t *testing.T, tenants []string, counts []int, minSessions int, maxSessions int) (*incrementalController, *DiscoveryService) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	mockClientServices := mocks.NewMockClientServices(ctrl)
+	setupExpectBoundedClientUsage(t, mockClientServices, minSessions, maxSessions)
+
+	controller := &incrementalController{
+		certificates:    setupTenantCertificates(tenants, counts),
+		virtualServices: map[string][]*models.VirtualService{},
+		fetched:         map[string][]string{},
+	}
+
+	for tenant, certificates := range controller.certificates {
+		for _, certificate := range certificates {
+			certificate.LastModified = toPointer("1700000000000000")
+			controller.virtualServices[tenant] = append(controller.virtualServices[tenant], &models.VirtualService{
+				LastModified:             toPointer("1700000000000000"),
+				Name:                     toPointer(*certificate.Name + "-vs"),
+				UUID:                     toPointer("vs-uuid-" + *certificate.Name),
+				SslKeyAndCertificateRefs: []string{*certificate.URL},
+			})
+		}
+	}
+
+	mockClientServices.EXPECT().
+		GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+			controller.mutex.Lock()
+			defer controller.mutex.Unlock()
+
+			page := getCertificateCursorPage(controller.certificates[client.Tenant], options...)
+
+			fields, _ := getParameterOptionsValue("fields", options...)
+			if len(fields) == 0 {
+				for _, certificate := range page {
+					controller.fetched[client.Tenant] = append(controller.fetched[client.Tenant], *certificate.Name)
+				}
+			}
+
+			return page, nil
+		}).
+		AnyTimes()
+
+	setupExpectNoCertificateUsage(mockClientServices)
+	setupExpectNoVirtualServiceInventory(mockClientServices)
+
+	mockClientServices.EXPECT().
+		GetAllVirtualServices(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+			controller.mutex.Lock()
+			defer controller.mutex.Unlock()
+
+			page, _ := getParameterOptionsValue("page", options...)
+			if page != "1" {
+				return nil, session.AviError{AviResult: session.AviResult{Message: toPointer("That page contains no results")}}
+			}
+
+			return slices.Clone(controller.virtualServices[client.Tenant]), nil
+		}).
+		AnyTimes()
+
+	return controller, NewDiscoveryService(mockClientServices)
+}
+
+func newIncrementalRequest(tenants string, maxResults int) *DiscoverCertificatesRequest {
+	return &DiscoverCertificatesRequest{
+		Configuration: DiscoverCertificatesConfiguration{
+			Incremental: true,
+			Tenants:     tenants,
+		},
+		Connection: &domain.Connection{
+			HostnameOrAddress: "localhost",
+			Password:          "password",
+			Username:          "user",
+		},
+		Control: DiscoveryControl{
+			MaxResults: maxResults,
+		},
+	}
+}
+
+// runIncrementalDiscovery will run every request of a discovery, returning the discovered certificate names, the
+// resynchronized tenants and the watermarks of the completed discovery
+func runIncrementalDiscovery(t *testing.T, e *echo.Echo, discoveryServices *DiscoveryService, request *DiscoverCertificatesRequest) ([]string, []string, map[string]*TenantWatermark) {
+	discovered := make([]string, 0)
+	resynchronized := make([]string, 0)
+
+	for requests := 0; ; requests++ {
+		require.Less(t, requests, 50)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, request)
+		require.Equal(t, http.StatusOK, code)
+		require.LessOrEqual(t, len(response.Messages), request.Control.MaxResults)
+
+		for _, dc := range response.Messages {
+			for _, mi := range dc.MachineIdentities {
+				discovered = append(discovered, mi.Keystore.CertificateName)
+			}
+		}
+
+		resynchronized = append(resynchronized, response.Resynchronized...)
+
+		// a completed discovery returns the watermarks in the response, a continued discovery in the discovery page
+		if response.Page == nil {
+			require.NotNil(t, response.Watermarks)
+			return discovered, resynchronized, response.Watermarks
+		}
+
+		require.NotNil(t, response.Page.Tenant)
+		require.NotNil(t, response.Page.Watermarks)
+		require.Nil(t, response.Watermarks)
+
+		request.Page = response.Page
+	}
+}
+
+func TestIncrementalDiscovery(t *testing.T) {
+	e := echo.New()
+
+	for _, maxResults := range []int{1, 3, 100} {
+		t.Run(fmt.Sprintf("changes_%d_results", maxResults), func(t *testing.T) {
+			// the discoveries of every certificate, of no changes, of the two changed certificates of the admin tenant
+			// and of the resynchronized Venafi tenant, of no changes, of the certificate referenced by a new virtual
+			// service, of the tenants resynchronized by a removed reference and a deleted virtual service, and of no
+			// changes
+			minSessions, maxSessions := 0, 0
+			for _, changed := range [][]int{{12, 4}, {0, 0}, {2, 3}, {0, 0}, {1, 0}, {12, 3}, {0, 0}} {
+				minTimes, maxTimes := getTenantDiscoveries(changed, maxResults, DefaultTenantConcurrency)
+				minSessions += minTimes
+				maxSessions += maxTimes
+			}
+
+			controller, discoveryServices := setupIncrementalController(t, []string{"admin", "Venafi"}, []int{12, 4}, minSessions, maxSessions)
+
+			discover := func(watermarks map[string]*TenantWatermark) ([]string, []string, map[string]*TenantWatermark) {
+				clear(controller.fetched)
+
+				request := newIncrementalRequest("admin,Venafi", maxResults)
+				if watermarks != nil {
+					request.Page = &DiscoveryPage{Watermarks: watermarks}
+				}
+
+				return runIncrementalDiscovery(t, e, discoveryServices, request)
+			}
+
+			discovered, resynchronized, watermarks := discover(nil)
+			require.Len(t, discovered, 16)
+			require.Empty(t, resynchronized)
+
+			// the watermark holds the high-water mark and the digests of the certificates and references of each tenant,
+			// whatever the number of certificates
+			data, err := json.Marshal(watermarks["Venafi"])
+			require.NoError(t, err)
+
+			var certificates, references objectDigest
+			for n := 0; n < 4; n++ {
+				certificates.add(fmt.Sprintf("uuid-Venafi-%02d", n))
+				references.add(fmt.Sprintf("vs-uuid-Venafi-%02d/uuid-Venafi-%02d", n, n))
+			}
+			require.JSONEq(t, `{
+				"lastModified":"1700000000000000",
+				"certificateDigest":{"count":4,"hash":"`+certificates.digest().Hash+`"},
+				"referenceDigest":{"count":4,"hash":"`+references.digest().Hash+`"}
+			}`, string(data))
+			require.Equal(t, "1700000000000000", watermarks["admin"].LastModified)
+			require.Equal(t, 12, watermarks["admin"].CertificateDigest.Count)
+
+			// an unchanged controller has nothing to discover, the next discovery starts from the watermarks
+			discovered, resynchronized, watermarks = discover(watermarks)
+			require.Empty(t, discovered)
+			require.Empty(t, resynchronized)
+			require.Empty(t, controller.fetched)
+			require.Equal(t, "1700000000000000", watermarks["admin"].LastModified)
+
+			// modify a certificate and a virtual service, and delete a certificate
+			controller.mutex.Lock()
+			controller.certificates["admin"][10].LastModified = toPointer("1700000000000100")
+			controller.virtualServices["admin"][1].LastModified = toPointer("1700000000000200")
+			controller.certificates["Venafi"] = slices.Delete(controller.certificates["Venafi"], 2, 3)
+			controller.mutex.Unlock()
+
+			discovered, resynchronized, watermarks = discover(watermarks)
+			// the tenant with the deleted certificate is resynchronized, every remaining certificate is discovered
+			require.Equal(t, []string{"admin-01", "admin-10", "Venafi-00", "Venafi-01", "Venafi-03"}, discovered)
+			require.Equal(t, []string{"Venafi"}, resynchronized)
+			// a continuing request reads the rest of the changed certificates again
+			require.Subset(t, []string{"admin-01", "admin-10"}, controller.fetched["admin"])
+
+			require.Equal(t, "1700000000000200", watermarks["admin"].LastModified)
+			require.Equal(t, "1700000000000000", watermarks["Venafi"].LastModified)
+			require.Equal(t, 3, watermarks["Venafi"].CertificateDigest.Count)
+
+			// the tenant is no longer resynchronized
+			discovered, resynchronized, watermarks = discover(watermarks)
+			require.Empty(t, discovered)
+			require.Empty(t, resynchronized)
+
+			// a new virtual service only adds a reference, the referenced certificate is discovered again
+			controller.mutex.Lock()
+			controller.virtualServices["admin"] = append(controller.virtualServices["admin"], &models.VirtualService{
+				LastModified:             toPointer("1700000000000300"),
+				Name:                     toPointer("admin-new-vs"),
+				UUID:                     toPointer("vs-uuid-admin-new"),
+				SslKeyAndCertificateRefs: []string{*controller.certificates["admin"][4].URL},
+			})
+			controller.mutex.Unlock()
+
+			discovered, resynchronized, watermarks = discover(watermarks)
+			require.Equal(t, []string{"admin-04", "admin-04"}, discovered)
+			require.Empty(t, resynchronized)
+
+			// a virtual service dropping a reference, and a deleted virtual service, leave the usage of a certificate
+			// stale, so the tenants are resynchronized
+			controller.mutex.Lock()
+			controller.virtualServices["admin"][5].LastModified = toPointer("1700000000000400")
+			controller.virtualServices["admin"][5].SslKeyAndCertificateRefs = nil
+			controller.virtualServices["Venafi"] = slices.Delete(controller.virtualServices["Venafi"], 0, 1)
+			controller.mutex.Unlock()
+
+			discovered, resynchronized, watermarks = discover(watermarks)
+			require.Equal(t, []string{"admin", "Venafi"}, resynchronized)
+			// every certificate is discovered again, the certificates no longer used have no machine identity
+			require.Contains(t, controller.fetched["admin"], "admin-05")
+			require.Contains(t, controller.fetched["Venafi"], "Venafi-00")
+			require.NotContains(t, discovered, "admin-05")
+			require.NotContains(t, discovered, "Venafi-00")
+			require.Len(t, discovered, 14)
+
+			discovered, resynchronized, _ = discover(watermarks)
+			require.Empty(t, discovered)
+			require.Empty(t, resynchronized)
+		})
+	}
+
+	t.Run("digests", func(t *testing.T) {
+		var previous, current, unmodified objectDigest
+		previous.add("a")
+		previous.add("b")
+
+		// the same objects, in any order
+		current.add("b")
+		current.add("a")
+		require.True(t, isUnchanged(previous.digest(), &current, &unmodified))
+
+		// only the modified objects were added since
+		unmodified.add("a")
+		unmodified.add("b")
+		current.add("c")
+		require.True(t, isUnchanged(previous.digest(), &current, &unmodified))
+
+		// an object was removed
+		var removed, removedUnmodified objectDigest
+		removed.add("a")
+		removed.add("c")
+		removedUnmodified.add("a")
+		require.False(t, isUnchanged(previous.digest(), &removed, &removedUnmodified))
+
+		// a watermark without a digest cannot tell
+		require.False(t, isUnchanged(nil, &current, &unmodified))
+	})
+
+	t.Run("not_incremental", func(t *testing.T) {
+		_, discoveryServices := setupIncrementalController(t, []string{"Venafi"}, []int{4}, 1, 1)
+
+		request := newIncrementalRequest("Venafi", 100)
+		request.Configuration.Incremental = false
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, request)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 4)
+		require.Nil(t, response.Page)
+	})
+
+	t.Run("paginator", func(t *testing.T) {
+		paginator := certificateDiscoveryPaginator{
+			Version: CertificateDiscoveryPaginatorVersion,
+			After:   "uuid-a",
+			Since:   "1700000000000000",
+		}
+
+		data, err := json.Marshal(&paginator)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"version":2,"after":"uuid-a","since":"1700000000000000"}`, string(data))
+
+		require.True(t, isLaterModified("1700000000000001", "1700000000000000"))
+		require.True(t, isLaterModified("10000000000000000", "9999999999999999"))
+		require.False(t, isLaterModified("1700000000000000", "1700000000000000"))
+		require.True(t, isLaterModified("1", ""))
+
+		changes := &tenantChanges{
+			changed: []string{"a", "c", "e", "g"},
+		}
+		require.Equal(t, []string{"a", "c"}, changes.after("", 2))
+		require.Equal(t, []string{"e", "g"}, changes.after("c", 2))
+		require.Equal(t, []string{"e"}, changes.after("d", 1))
+		require.Empty(t, changes.after("g", 2))
+	})
+}
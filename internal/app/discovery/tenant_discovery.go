@@ -0,0 +1,283 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+// tenantDiscovery is the outcome of discovering the certificates of a single tenant
+type tenantDiscovery struct {
+	tenant     string
+	discovered []*discoveredCertificateAndURL
+	finished   bool
+	paginator  string
+	err        error
+
+	// watermark and resynchronized are the changes of an incremental discovery starting the tenant
+	watermark      *TenantWatermark
+	resynchronized bool
+
+	// certificates receives each certificate of a streamed discovery as it is built, in place of the discovered
+	// certificates, and count is the number of certificates discovered by the tenant
+	certificates chan *discoveredCertificateAndURL
+	count        int
+	// done is closed once the tenant is discovered, or skipped since the tenants before it discovered the results of
+	// the request
+	done chan struct{}
+}
+
+func newTenantDiscovery(tenant string, streamed bool) *tenantDiscovery {
+	outcome := &tenantDiscovery{
+		tenant: tenant,
+		done:   make(chan struct{}),
+	}
+
+	if streamed {
+		// the worker waits for the stream once the buffer is full, the stream drains the tenants in order
+		outcome.certificates = make(chan *discoveredCertificateAndURL, DefaultStreamBuffer)
+	}
+
+	return outcome
+}
+
+// complete will mark the tenant as discovered or skipped
+func (outcome *tenantDiscovery) complete() {
+	if outcome.certificates != nil {
+		close(outcome.certificates)
+	}
+
+	close(outcome.done)
+}
+
+// waitTenants will wait for every tenant of the outcomes to be discovered or skipped. The certificates of a streamed
+// tenant not yet collected are discarded, so its worker is not left waiting for the stream.
+func waitTenants(outcomes []*tenantDiscovery) {
+	for _, outcome := range outcomes {
+		if outcome.certificates != nil {
+			for range outcome.certificates {
+			}
+		}
+
+		<-outcome.done
+	}
+}
+
+// getTenantConcurrency will return the configured number of tenants to discover in parallel
+func getTenantConcurrency(configuration *DiscoverCertificatesConfiguration) int {
+	if configuration.TenantConcurrency < 1 {
+		return DefaultTenantConcurrency
+	}
+
+	if configuration.TenantConcurrency > MaxTenantConcurrency {
+		return MaxTenantConcurrency
+	}
+
+	return configuration.TenantConcurrency
+}
+
+// discoverTenants will start the discovery of the tenants by a pool of workers, the outcomes are returned in the order
+// of the tenants and each is done once its tenant is discovered. Only the first tenant is continued from the
+// paginator, every other tenant is discovered from the start. The watermarks are only read by the workers. A worker
+// skips a tenant, which is then done without certificates, when the tenants before it that already finished have
+// discovered maxResults certificates. The skip is only a saving, a tenant started while a tenant before it is still
+// being discovered is discovered in full and its certificates beyond the results are discarded by the collection. The
+// certificates of a streamed discovery are received by the outcomes as they are built, and a worker waits for the
+// stream to reach its tenant once DefaultStreamBuffer of its certificates are not yet collected.
+func (svc *DiscoveryService) discoverTenants(connection *domain.Connection, configuration *DiscoverCertificatesConfiguration, maxResults int, tenants []string, paginator string, watermarks map[string]*TenantWatermark, streamed bool) []*tenantDiscovery {
+	outcomes := make([]*tenantDiscovery, len(tenants))
+	for idx, tenant := range tenants {
+		outcomes[idx] = newTenantDiscovery(tenant, streamed)
+	}
+
+	// discarded only counts the tenants already discovered, the tenants before idx still being discovered count none
+	var mutex sync.Mutex
+	discarded := func(idx int) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		discovered := 0
+		for _, outcome := range outcomes[:idx] {
+			discovered += outcome.count
+		}
+
+		return discovered >= maxResults
+	}
+
+	workers := min(getTenantConcurrency(configuration), len(tenants))
+	work := make(chan int)
+
+	for range workers {
+		go func() {
+			for idx := range work {
+				outcome := outcomes[idx]
+
+				if discarded(idx) {
+					zap.L().Info("skipping tenant beyond the results of the request", zap.String("tenant", tenants[idx]))
+					outcome.complete()
+					continue
+				}
+
+				start := ""
+				if idx == 0 {
+					start = paginator
+				}
+
+				count := svc.discoverTenant(outcome, connection, configuration, maxResults, start, watermarks)
+
+				mutex.Lock()
+				outcome.count = count
+				mutex.Unlock()
+
+				outcome.complete()
+			}
+		}()
+	}
+
+	go func() {
+		for idx := range tenants {
+			work <- idx
+		}
+		close(work)
+	}()
+
+	return outcomes
+}
+
+// discoverTenant will discover up to maxResults certificates of the tenant of the outcome using a dedicated client
+// session, returning the number of certificates discovered
+func (svc *DiscoveryService) discoverTenant(outcome *tenantDiscovery, connection *domain.Connection, configuration *DiscoverCertificatesConfiguration, maxResults int, paginator string, watermarks map[string]*TenantWatermark) int {
+	client := svc.ClientServices.NewClient(connection, outcome.tenant)
+	err := svc.ClientServices.Connect(client)
+	defer func() {
+		svc.ClientServices.Close(client)
+	}()
+	if err != nil {
+		outcome.err = err
+		return 0
+	}
+
+	csp := newCertificateDiscovery(svc.ClientServices, connection, configuration, &DiscoveryControl{MaxResults: maxResults})
+	csp.virtualServiceIndexes = svc.virtualServiceIndexes
+
+	streamed := 0
+	if outcome.certificates != nil {
+		csp.discovered = func(dcr *discoveredCertificateAndURL) {
+			streamed++
+			outcome.certificates <- dcr
+		}
+	}
+
+	page := &DiscoveryPage{
+		Tenant:     &outcome.tenant,
+		Paginator:  paginator,
+		Watermarks: watermarks,
+	}
+
+	outcome.finished, outcome.discovered, outcome.err = csp.discover(client, page)
+	outcome.paginator = page.Paginator
+
+	if csp.changes != nil {
+		outcome.watermark = csp.changes.watermark
+		outcome.resynchronized = csp.changes.resynchronized
+	}
+
+	return streamed + len(outcome.discovered)
+}
+
+// stream will collect each certificate of a streamed tenant discovery as it is built, and call collected so the
+// certificate is written before the discovery of the tenant completes. A certificate beyond maxResults is not
+// collected, the discovery of the tenant is then continued after the last collected certificate.
+func (outcome *tenantDiscovery) stream(results *tenantDiscoveryResults, maxResults int, collected func(results *tenantDiscoveryResults) error) error {
+	var last *discoveredCertificateAndURL
+	truncated := false
+
+	for dcr := range outcome.certificates {
+		if results.Discovered >= maxResults {
+			truncated = true
+			continue
+		}
+
+		results.append(outcome.tenant, []*discoveredCertificateAndURL{dcr})
+		last = dcr
+
+		if err := collected(results); err != nil {
+			return err
+		}
+	}
+
+	<-outcome.done
+
+	if !truncated {
+		return nil
+	}
+
+	outcome.finished = false
+	outcome.paginator = ""
+
+	if last != nil {
+		data, err := json.Marshal(&last.paginator)
+		if err != nil {
+			zap.L().Error("Error marshalling VMware NSX-ALB discovery page", zap.String("tenant", outcome.tenant), zap.Error(err))
+			return fmt.Errorf(`failed to marshal VMware NSX-ALB discovery page for the tenant "%s": %w`, outcome.tenant, err)
+		}
+
+		outcome.paginator = string(data)
+	}
+
+	return nil
+}
+
+// collect will append the discovered certificates of the tenant to the results without exceeding maxResults. The
+// returned page is set when the discovery of the tenant must be continued by the next request. A failed tenant is
+// reported in the errors of the results and keeps the watermark of the previous discovery.
+func (outcome *tenantDiscovery) collect(results *tenantDiscoveryResults, maxResults int) (*DiscoveryPage, error) {
+	if outcome.err != nil {
+		zap.L().Info("skipping failed tenant", zap.String("tenant", outcome.tenant), zap.Error(outcome.err))
+
+		results.Errors = append(results.Errors, &DiscoveryIssue{
+			Tenant: outcome.tenant,
+			Reason: outcome.err.Error(),
+		})
+		return nil, nil
+	}
+
+	if outcome.watermark != nil {
+		results.Watermarks[outcome.tenant] = outcome.watermark
+	}
+	if outcome.resynchronized {
+		results.Resynchronized = append(results.Resynchronized, outcome.tenant)
+	}
+
+	remaining := maxResults - results.Discovered
+	if len(outcome.discovered) <= remaining {
+		results.append(outcome.tenant, outcome.discovered)
+
+		if outcome.finished {
+			return nil, nil
+		}
+
+		return &DiscoveryPage{
+			Tenant:    &outcome.tenant,
+			Paginator: outcome.paginator,
+		}, nil
+	}
+
+	// the tenant discovered more certificates than remain, continue the tenant after the last collected certificate
+	collected := outcome.discovered[:remaining]
+	results.append(outcome.tenant, collected)
+
+	data, err := json.Marshal(&collected[len(collected)-1].paginator)
+	if err != nil {
+		zap.L().Error("Error marshalling VMware NSX-ALB discovery page", zap.String("tenant", outcome.tenant), zap.Error(err))
+		return nil, fmt.Errorf(`failed to marshal VMware NSX-ALB discovery page for the tenant "%s": %w`, outcome.tenant, err)
+	}
+
+	return &DiscoveryPage{
+		Tenant:    &outcome.tenant,
+		Paginator: string(data),
+	}, nil
+}
@@ -1,41 +1,202 @@
 package discovery
 
+import "github.com/venafi/vmware-avi-connector/internal/app/domain"
+
+const (
+	// DefaultMaxCarriedCopies is the largest number of certificate copies carried by the discovery page of a
+	// continuing discovery, so the page does not grow with the whole discovery
+	DefaultMaxCarriedCopies = 1000
+)
+
 type tenantDiscoveryResults struct {
 	Discovered int
 	TenantMap  map[string][]*discoveredCertificateAndURL
+
+	// Resynchronized and Watermarks are the changes of an incremental discovery
+	Resynchronized []string
+	Watermarks     map[string]*TenantWatermark
+
+	// Warnings and Errors are the failures that did not stop the discovery
+	Warnings []*DiscoveryIssue
+	Errors   []*DiscoveryIssue
+
+	// tenants is the order the tenants were appended in, used to collapse the results in a deterministic order
+	tenants []string
+
+	// copies are the copies of every certificate of the discovery grouped by fingerprint, in the order they were
+	// discovered, including the copies carried from the previous requests of the discovery without their usage
+	copies       []*DuplicateCertificateGroup
+	fingerprints map[string]*DuplicateCertificateGroup
+	// truncated is set once copies of the discovery were not carried, the duplicates of the discovery may be incomplete
+	truncated bool
 }
 
 func newTenantDiscoveryResults() *tenantDiscoveryResults {
 	return &tenantDiscoveryResults{
-		Discovered: 0,
-		TenantMap:  map[string][]*discoveredCertificateAndURL{},
+		Discovered:     0,
+		TenantMap:      map[string][]*discoveredCertificateAndURL{},
+		Resynchronized: make([]string, 0),
+		Watermarks:     map[string]*TenantWatermark{},
+		Warnings:       make([]*DiscoveryIssue, 0),
+		Errors:         make([]*DiscoveryIssue, 0),
+		tenants:        make([]string, 0),
+
+		copies:       make([]*DuplicateCertificateGroup, 0),
+		fingerprints: map[string]*DuplicateCertificateGroup{},
 	}
 }
 
-func (tdr *tenantDiscoveryResults) append(tenant string, dcc []*discoveredCertificateAndURL) {
-	existing, ok := tdr.TenantMap[tenant]
+// carry will add the copies found by the previous requests of the discovery, so the duplicates are grouped across
+// the requests of the discovery
+func (tdr *tenantDiscoveryResults) carry(page *DiscoveryPage) {
+	tdr.truncated = page.CopiesTruncated
+
+	for _, c := range page.Copies {
+		if c == nil || len(c.Fingerprint) == 0 {
+			continue
+		}
+
+		tdr.addCopy(c.Fingerprint, &DuplicateCertificate{
+			Tenant:          c.Tenant,
+			CertificateName: c.CertificateName,
+			uuid:            c.UUID,
+			carried:         true,
+		})
+	}
+}
+
+// carried will return the copies of the discovery to carry by the discovery page of a continuing discovery. Every
+// fingerprint discovered so far can become a duplicate of a certificate of a later tenant, so only the identity of
+// each copy is carried and its usage is read again if it is reported. At most DefaultMaxCarriedCopies copies are
+// carried, the copies of the fingerprints already duplicated first and then the single copies in the order they were
+// discovered, and the results are marked truncated when a copy is left out.
+func (tdr *tenantDiscoveryResults) carried() []*CertificateCopy {
+	carried := make([]*CertificateCopy, 0, min(len(tdr.fingerprints), DefaultMaxCarriedCopies))
+
+	add := func(group *DuplicateCertificateGroup) {
+		for _, dc := range group.Certificates {
+			if len(carried) == DefaultMaxCarriedCopies {
+				tdr.truncated = true
+				return
+			}
+
+			carried = append(carried, &CertificateCopy{
+				Fingerprint:     group.Fingerprint,
+				Tenant:          dc.Tenant,
+				CertificateName: dc.CertificateName,
+				UUID:            dc.uuid,
+			})
+		}
+	}
+
+	for _, group := range tdr.duplicates() {
+		add(group)
+	}
+
+	for _, group := range tdr.copies {
+		if len(group.Certificates) == 1 {
+			add(group)
+		}
+	}
+
+	return carried
+}
+
+// addCopy will add the copy to the group of its fingerprint
+func (tdr *tenantDiscoveryResults) addCopy(fingerprint string, dc *DuplicateCertificate) {
+	group, ok := tdr.fingerprints[fingerprint]
 	if !ok {
-		tdr.Discovered += len(dcc)
-		tdr.TenantMap[tenant] = dcc
-		return
+		group = &DuplicateCertificateGroup{
+			Fingerprint:  fingerprint,
+			Certificates: make([]*DuplicateCertificate, 0),
+		}
+
+		tdr.fingerprints[fingerprint] = group
+		tdr.copies = append(tdr.copies, group)
 	}
 
+	group.Certificates = append(group.Certificates, dc)
+}
+
+// append will add the discovered certificates to the results of the tenant, a certificate of the wildcard tenant
+// discovery is added to the results of its own tenant
+func (tdr *tenantDiscoveryResults) append(tenant string, dcc []*discoveredCertificateAndURL) {
 	for _, dc := range dcc {
+		tdr.Warnings = append(tdr.Warnings, dc.warnings...)
+
+		key := tenant
+		if len(dc.Tenant) > 0 {
+			key = dc.Tenant
+		}
+
+		existing, ok := tdr.TenantMap[key]
+		if !ok {
+			tdr.tenants = append(tdr.tenants, key)
+		}
+
 		tdr.Discovered++
-		existing = append(existing, dc)
+		tdr.TenantMap[key] = append(existing, dc)
 	}
-
-	tdr.TenantMap[tenant] = existing
 }
 
 func (tdr *tenantDiscoveryResults) collapse() []*DiscoveredCertificate {
 	collapsed := make([]*DiscoveredCertificate, 0, tdr.Discovered)
 
-	for _, results := range tdr.TenantMap {
-		for _, dc := range results {
+	for _, tenant := range tdr.tenants {
+		for _, dc := range tdr.TenantMap[tenant] {
 			collapsed = append(collapsed, dc.Result)
 		}
 	}
 
 	return collapsed
 }
+
+// release will return the collected certificates in the order they are collapsed and remove them from the results.
+// The count of discovered certificates and the copies of the certificates are kept, so a streamed discovery still
+// stops at maxResults and groups the duplicates.
+func (tdr *tenantDiscoveryResults) release() []*DiscoveredCertificate {
+	released := tdr.collapse()
+	tdr.recordCopies()
+
+	tdr.TenantMap = map[string][]*discoveredCertificateAndURL{}
+	tdr.tenants = make([]string, 0)
+
+	return released
+}
+
+// recordCopies will group the collected certificates with the same fingerprint with the copies of the discovery, in
+// the order the results are collapsed. A certificate that cannot be parsed has no fingerprint and is not grouped.
+func (tdr *tenantDiscoveryResults) recordCopies() {
+	for _, tenant := range tdr.tenants {
+		for _, dc := range tdr.TenantMap[tenant] {
+			if dc.Result.Metadata == nil || len(dc.Result.Metadata.Fingerprint) == 0 {
+				continue
+			}
+
+			usage := make([]*domain.Binding, 0, len(dc.Result.MachineIdentities))
+			for _, mi := range dc.Result.MachineIdentities {
+				usage = append(usage, mi.Binding)
+			}
+
+			tdr.addCopy(dc.Result.Metadata.Fingerprint, &DuplicateCertificate{
+				Tenant:          tenant,
+				CertificateName: dc.Name,
+				Usage:           usage,
+				Unused:          len(usage) == 0 && len(dc.warnings) == 0,
+				uuid:            dc.UUID,
+			})
+		}
+	}
+}
+
+// duplicates will return the groups of the recorded copies with more than one copy
+func (tdr *tenantDiscoveryResults) duplicates() []*DuplicateCertificateGroup {
+	duplicates := make([]*DuplicateCertificateGroup, 0)
+	for _, group := range tdr.copies {
+		if len(group.Certificates) > 1 {
+			duplicates = append(duplicates, group)
+		}
+	}
+
+	return duplicates
+}
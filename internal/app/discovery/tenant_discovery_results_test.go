@@ -1,9 +1,18 @@
 package discovery
 
 import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"go.uber.org/mock/gomock"
 )
 
 func TestTenantDiscoveryResults(t *testing.T) {
@@ -73,5 +82,231 @@ func TestTenantDiscoveryResults(t *testing.T) {
 		collapsed := tdr.collapse()
 		require.NotNil(t, collapsed)
 		require.Equal(t, 5, len(collapsed))
+
+		// the results are collapsed in the order the tenants were first appended
+		certificates := make([]string, 0, len(collapsed))
+		for _, dc := range collapsed {
+			certificates = append(certificates, dc.Certificate)
+		}
+		require.Equal(t, []string{"c-31", "c-32", "c-2-a", "c-2-b", "c-1"}, certificates)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		shared := generateTestCertificate(t, &x509.Certificate{
+			Subject: pkix.Name{CommonName: "shared.example.com"},
+		}, nil, nil)
+		single := generateTestCertificate(t, &x509.Certificate{
+			Subject: pkix.Name{CommonName: "single.example.com"},
+		}, nil, nil)
+
+		newResult := func(name string, certificate *testCertificate, virtualServices ...string) *discoveredCertificateAndURL {
+			dc := &discoveredCertificateAndURL{
+				Name: name,
+				UUID: "uuid-" + name,
+				Result: &DiscoveredCertificate{
+					Certificate:       certificate.pem,
+					MachineIdentities: make([]*MachineIdentity, 0),
+					Metadata:          analyzeCertificate(certificate.certificate, time.Now()),
+				},
+			}
+
+			for _, vs := range virtualServices {
+				dc.Result.MachineIdentities = append(dc.Result.MachineIdentities, &MachineIdentity{
+					Binding: &domain.Binding{VirtualServiceName: vs, UsageType: domain.UsageTypeVirtualService},
+				})
+			}
+
+			return dc
+		}
+
+		tdr := newTenantDiscoveryResults()
+		tdr.append("Blue", []*discoveredCertificateAndURL{
+			newResult("shared-blue", shared, "blue-vs"),
+			newResult("single", single),
+		})
+		tdr.append("green", []*discoveredCertificateAndURL{
+			newResult("shared-green", shared),
+			{
+				Name: "unparsable",
+				Result: &DiscoveredCertificate{
+					Certificate: "-----BEGIN CERTIFICATE-----\nunparsable\n-----END CERTIFICATE-----\n",
+				},
+			},
+		})
+		tdr.append("admin", []*discoveredCertificateAndURL{
+			newResult("shared-admin", shared, "admin-vs", "green-vs"),
+		})
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		svc := NewDiscoveryService(mockClientServices)
+
+		req := &DiscoverCertificatesRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+		}
+
+		// the copies of the shared certificate are grouped across tenants, and the unused copy is marked
+		response := svc.buildResponse(req, nil, tdr)
+		require.Equal(t, []*DuplicateCertificateGroup{
+			{
+				Fingerprint: getFingerprint(shared.certificate),
+				Certificates: []*DuplicateCertificate{
+					{
+						Tenant:          "Blue",
+						CertificateName: "shared-blue",
+						Usage:           []*domain.Binding{{VirtualServiceName: "blue-vs", UsageType: domain.UsageTypeVirtualService}},
+						uuid:            "uuid-shared-blue",
+					},
+					{
+						Tenant:          "green",
+						CertificateName: "shared-green",
+						Usage:           []*domain.Binding{},
+						Unused:          true,
+						uuid:            "uuid-shared-green",
+					},
+					{
+						Tenant:          "admin",
+						CertificateName: "shared-admin",
+						Usage: []*domain.Binding{
+							{VirtualServiceName: "admin-vs", UsageType: domain.UsageTypeVirtualService},
+							{VirtualServiceName: "green-vs", UsageType: domain.UsageTypeVirtualService},
+						},
+						uuid: "uuid-shared-admin",
+					},
+				},
+			},
+		}, response.Duplicates)
+
+		// the copies of a continuing discovery are carried by the discovery page and grouped when it completes
+		tenant := "green"
+		first := newTenantDiscoveryResults()
+		first.append("Blue", []*discoveredCertificateAndURL{
+			newResult("shared-blue", shared, "blue-vs"),
+			newResult("single", single),
+		})
+
+		response = svc.buildResponse(req, &DiscoveryPage{Tenant: &tenant}, first)
+		require.Empty(t, response.Duplicates)
+
+		// the page only identifies each copy, without its usage
+		data, err := json.Marshal(response.Page.Copies)
+		require.NoError(t, err)
+		require.JSONEq(t, `[
+			{"fingerprint":"`+getFingerprint(shared.certificate)+`","tenant":"Blue","certificateName":"shared-blue","uuid":"uuid-shared-blue"},
+			{"fingerprint":"`+getFingerprint(single.certificate)+`","tenant":"Blue","certificateName":"single","uuid":"uuid-single"}
+		]`, string(data))
+
+		data, err = json.Marshal(response.Page)
+		require.NoError(t, err)
+
+		page := &DiscoveryPage{}
+		require.NoError(t, json.Unmarshal(data, page))
+
+		second := newTenantDiscoveryResults()
+		second.carry(page)
+		second.append("green", []*discoveredCertificateAndURL{
+			newResult("shared-green", shared),
+		})
+
+		// the usage of the carried copy is read again with a session of its tenant
+		setupExpectClientUsage(t, mockClientServices, 1)
+		setupExpectNoCertificateUsage(mockClientServices)
+		setupExpectNoVirtualServiceInventory(mockClientServices)
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return([]*models.VirtualService{
+				{
+					Name:                     toPointer("blue-vs"),
+					SslKeyAndCertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/uuid-shared-blue"},
+				},
+			}, nil).
+			Times(1)
+
+		response = svc.buildResponse(req, nil, second)
+		require.Empty(t, response.Warnings)
+		require.Equal(t, []*DuplicateCertificateGroup{
+			{
+				Fingerprint: getFingerprint(shared.certificate),
+				Certificates: []*DuplicateCertificate{
+					{
+						Tenant:          "Blue",
+						CertificateName: "shared-blue",
+						Usage:           []*domain.Binding{{VirtualServiceName: "blue-vs", UsageType: domain.UsageTypeVirtualService}},
+						uuid:            "uuid-shared-blue",
+						carried:         true,
+					},
+					{
+						Tenant:          "green",
+						CertificateName: "shared-green",
+						Usage:           []*domain.Binding{},
+						Unused:          true,
+						uuid:            "uuid-shared-green",
+					},
+				},
+			},
+		}, response.Duplicates)
+
+		// a copy whose usage could not be read completely is not marked unused
+		third := newTenantDiscoveryResults()
+		incomplete := newResult("shared-incomplete", shared)
+		incomplete.warn("green", DiscoveryIssueCertificate, incomplete.Name, "incomplete certificate usage: failed")
+		third.append("green", []*discoveredCertificateAndURL{
+			newResult("shared-unused", shared),
+			incomplete,
+		})
+
+		response = svc.buildResponse(req, nil, third)
+		require.Len(t, response.Duplicates, 1)
+		require.Len(t, response.Duplicates[0].Certificates, 2)
+		require.True(t, response.Duplicates[0].Certificates[0].Unused)
+		require.False(t, response.Duplicates[0].Certificates[1].Unused)
+	})
+
+	t.Run("carried_copies_limit", func(t *testing.T) {
+		tdr := newTenantDiscoveryResults()
+		for i := 0; i < DefaultMaxCarriedCopies; i++ {
+			tdr.addCopy(fmt.Sprintf("fingerprint-%04d", i), &DuplicateCertificate{Tenant: "Blue", CertificateName: fmt.Sprintf("single-%04d", i)})
+		}
+
+		carried := tdr.carried()
+		require.Len(t, carried, DefaultMaxCarriedCopies)
+		require.False(t, tdr.truncated)
+
+		// the copies of a duplicated fingerprint are carried before the single copies
+		tdr.addCopy("fingerprint-0500", &DuplicateCertificate{Tenant: "green", CertificateName: "copy-0500"})
+
+		carried = tdr.carried()
+		require.Len(t, carried, DefaultMaxCarriedCopies)
+		require.True(t, tdr.truncated)
+		require.Equal(t, "single-0500", carried[0].CertificateName)
+		require.Equal(t, "copy-0500", carried[1].CertificateName)
+		require.Equal(t, "single-0000", carried[2].CertificateName)
+		require.Equal(t, "single-0998", carried[DefaultMaxCarriedCopies-1].CertificateName)
+
+		// the truncation is carried by the discovery page and reported when the discovery completes
+		tenant := "green"
+		page := &DiscoveryPage{Tenant: &tenant, Copies: carried, CopiesTruncated: tdr.truncated}
+		data, err := json.Marshal(page)
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"copiesTruncated":true`)
+
+		next := newTenantDiscoveryResults()
+		next.carry(page)
+		require.True(t, next.truncated)
+
+		svc := NewDiscoveryService(nil)
+		response := svc.buildResponse(&DiscoverCertificatesRequest{}, nil, newTenantDiscoveryResults())
+		require.Empty(t, response.Warnings)
+
+		response = svc.buildResponse(&DiscoverCertificatesRequest{}, nil, &tenantDiscoveryResults{TenantMap: map[string][]*discoveredCertificateAndURL{}, fingerprints: map[string]*DuplicateCertificateGroup{}, truncated: true})
+		require.Equal(t, []*DiscoveryIssue{
+			{Reason: fmt.Sprintf("incomplete duplicates: more than %d certificate copies were carried by the discovery", DefaultMaxCarriedCopies)},
+		}, response.Warnings)
 	})
 }
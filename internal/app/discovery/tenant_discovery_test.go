@@ -0,0 +1,464 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+// setupTenantCertificates will return counts[i] certificates for each tenant, with certificate names of <tenant>-<n>
+func setupTenantCertificates(tenants []string, counts []int) map[string][]*models.SSLKeyAndCertificate {
+	certificates := make(map[string][]*models.SSLKeyAndCertificate)
+	for idx, tenant := range tenants {
+		collection := make([]*models.SSLKeyAndCertificate, 0, counts[idx])
+		for n := 0; n < counts[idx]; n++ {
+			name := fmt.Sprintf("%s-%02d", tenant, n)
+			collection = append(collection, &models.SSLKeyAndCertificate{
+				Certificate: &models.SSLCertificate{
+					Certificate: toPointer("-----BEGIN CERTIFICATE-----\n" + name + "\n-----END CERTIFICATE-----\n"),
+				},
+				Name: toPointer(name),
+				UUID: toPointer("uuid-" + name),
+				URL:  toPointer("https://localhost/api/sslkeyandcertificate/uuid-" + name),
+			})
+		}
+		certificates[tenant] = collection
+	}
+
+	return certificates
+}
+
+// setupPagedDiscovery will page the tenant certificates as VMware does and track the number of tenants discovered
+// at the same time
+func setupPagedDiscovery(clientServices *mocks.MockClientServices, certificates map[string][]*models.SSLKeyAndCertificate, failing string) func() int {
+	var mutex sync.Mutex
+	active := map[string]int{}
+	highest := 0
+
+	clientServices.EXPECT().
+		GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+			mutex.Lock()
+			active[client.Tenant]++
+			highest = max(highest, len(active))
+			mutex.Unlock()
+
+			defer func() {
+				mutex.Lock()
+				active[client.Tenant]--
+				if active[client.Tenant] == 0 {
+					delete(active, client.Tenant)
+				}
+				mutex.Unlock()
+			}()
+
+			// finish the tenants in a random order
+			time.Sleep(time.Duration(rand.IntN(3)) * time.Millisecond)
+
+			if strings.EqualFold(client.Tenant, failing) {
+				return nil, fmt.Errorf("tenant %s is unavailable", client.Tenant)
+			}
+
+			return getCertificateCursorPage(certificates[client.Tenant], options...), nil
+		}).
+		AnyTimes()
+
+	setupExpectNoCertificateUsage(clientServices)
+	setupExpectNoVirtualServiceInventory(clientServices)
+
+	clientServices.EXPECT().
+		GetAllVirtualServices(gomock.Any(), gomock.Any()).
+		Return([]*models.VirtualService{}, nil).
+		AnyTimes()
+
+	return func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return highest
+	}
+}
+
+// getTenantDiscoveries will return the number of tenant discoveries of the requests discovering every certificate of
+// the tenants, in tenant order. The minimum is the number of discoveries of the tenants that are collected, and the
+// maximum includes the tenants of the same batch discovered by parallel workers before the collected tenants reached
+// the results of a request.
+func getTenantDiscoveries(counts []int, maxResults int, concurrency int) (minTimes int, maxTimes int) {
+	tenant, offset := 0, 0
+	for tenant < len(counts) {
+		remaining := maxResults
+		next, nextOffset := len(counts), 0
+		stopped := false
+
+		for first := tenant; first < len(counts) && !stopped; first += concurrency {
+			last := min(first+concurrency, len(counts))
+
+			// every tenant of the batch discovers up to the results remaining when the batch starts, and is not
+			// finished when it discovers all of them
+			limit := remaining
+			for idx := first; idx < last && !stopped; idx++ {
+				minTimes++
+				maxTimes++
+
+				available := counts[idx]
+				if idx == tenant {
+					available -= offset
+				}
+
+				discovered := min(available, limit)
+				switch {
+				case discovered > remaining:
+					next, nextOffset, stopped = idx, counts[idx]-available+remaining, true
+				case available >= limit:
+					next, nextOffset, stopped = idx, counts[idx]-available+discovered, true
+				case discovered == remaining:
+					next, stopped = idx+1, true
+				}
+				remaining -= min(discovered, remaining)
+
+				if stopped {
+					maxTimes += last - idx - 1
+				}
+			}
+		}
+
+		tenant, offset = next, nextOffset
+	}
+
+	return minTimes, maxTimes
+}
+
+func runTenantDiscovery(t *testing.T, e *echo.Echo, discoveryServices *DiscoveryService, request *DiscoverCertificatesRequest) (*DiscoverCertificatesResponse, int) {
+	raw, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	recorder, ctx := setupPost(e, "/v1/discovercertificates", bytes.NewReader(raw))
+
+	err = discoveryServices.DiscoverCertificates(ctx)
+	require.NoError(t, err)
+
+	if recorder.Code != http.StatusOK {
+		return nil, recorder.Code
+	}
+
+	response := &DiscoverCertificatesResponse{}
+	err = json.Unmarshal(recorder.Body.Bytes(), response)
+	require.NoError(t, err)
+
+	return response, recorder.Code
+}
+
+func TestParallelTenantDiscovery(t *testing.T) {
+	e := echo.New()
+
+	tenants := []string{"admin", "Blue", "green", "Orange", "red", "Violet", "yellow"}
+	counts := []int{3, 0, 12, 1, 7, 25, 2}
+
+	expected := make([]string, 0)
+	for idx, tenant := range tenants {
+		for n := 0; n < counts[idx]; n++ {
+			expected = append(expected, fmt.Sprintf("-----BEGIN CERTIFICATE-----\n%s-%02d\n-----END CERTIFICATE-----\n", tenant, n))
+		}
+	}
+
+	for _, maxResults := range []int{1, 4, 10, 50} {
+		for _, concurrency := range []int{1, 3, 16} {
+			t.Run(fmt.Sprintf("deterministic_%d_results_%d_workers", maxResults, concurrency), func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+
+				mockClientServices := mocks.NewMockClientServices(ctrl)
+				minTimes, maxTimes := getTenantDiscoveries(counts, maxResults, concurrency)
+				setupExpectBoundedClientUsage(t, mockClientServices, minTimes, maxTimes)
+				highest := setupPagedDiscovery(mockClientServices, setupTenantCertificates(tenants, counts), "")
+
+				discoveryServices := NewDiscoveryService(mockClientServices)
+
+				request := &DiscoverCertificatesRequest{
+					Configuration: DiscoverCertificatesConfiguration{
+						TenantConcurrency: concurrency,
+						Tenants:           "yellow,Violet,red,Orange,green,Blue,admin",
+					},
+					Connection: &domain.Connection{
+						HostnameOrAddress: "localhost",
+						Password:          "password",
+						Username:          "user",
+					},
+					Control: DiscoveryControl{
+						MaxResults: maxResults,
+					},
+				}
+
+				discovered := make([]string, 0)
+				for requests := 0; ; requests++ {
+					require.Less(t, requests, len(expected)+len(tenants))
+
+					response, code := runTenantDiscovery(t, e, discoveryServices, request)
+					require.Equal(t, http.StatusOK, code)
+					require.LessOrEqual(t, len(response.Messages), maxResults)
+
+					for _, dc := range response.Messages {
+						discovered = append(discovered, dc.Certificate)
+					}
+
+					if response.Page == nil {
+						break
+					}
+
+					request.Page = response.Page
+				}
+
+				require.Equal(t, expected, discovered)
+				require.LessOrEqual(t, highest(), concurrency)
+			})
+		}
+	}
+
+	t.Run("tenant_failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		// every tenant, then the failing tenant and the next tenant completing the results, and the tenant after it in
+		// the same batch when it starts before they finished
+		setupExpectBoundedClientUsage(t, mockClientServices, 9, 10)
+		setupPagedDiscovery(mockClientServices, setupTenantCertificates(tenants, counts), "red")
+
+		discoveryServices := NewDiscoveryService(mockClientServices)
+
+		request := &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				TenantConcurrency: 3,
+				Tenants:           strings.Join(tenants, ","),
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 100,
+			},
+		}
+
+		// the failing tenant is reported and the other tenants are discovered
+		response, code := runTenantDiscovery(t, e, discoveryServices, request)
+		require.Equal(t, http.StatusOK, code)
+		require.Nil(t, response.Page)
+		require.Len(t, response.Messages, 43)
+		require.Empty(t, response.Warnings)
+		require.Len(t, response.Errors, 1)
+		require.Equal(t, "red", response.Errors[0].Tenant)
+		require.Empty(t, response.Errors[0].Object)
+		require.Contains(t, response.Errors[0].Reason, `failed to read VMware NSX-ALB certificates for the tenant "red"`)
+
+		for _, dc := range response.Messages {
+			require.NotContains(t, dc.Certificate, "red-")
+		}
+
+		// a failing tenant continued from a discovery page is reported and the discovery moves to the next tenant
+		request.Control.MaxResults = 1
+		request.Page = &DiscoveryPage{
+			Tenant:    toPointer("red"),
+			Paginator: `{"version":2,"after":"uuid-red-03"}`,
+		}
+
+		response, code = runTenantDiscovery(t, e, discoveryServices, request)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 1)
+		require.Equal(t, "-----BEGIN CERTIFICATE-----\nViolet-00\n-----END CERTIFICATE-----\n", response.Messages[0].Certificate)
+		require.Len(t, response.Errors, 1)
+		require.Equal(t, "red", response.Errors[0].Tenant)
+	})
+
+	t.Run("certificate_warning", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		certificates := setupTenantCertificates([]string{"Venafi"}, []int{3})
+		certificates["Venafi"][1].CaCerts = []*models.CertificateAuthority{
+			{CaRef: toPointer("https://localhost/api/sslkeyandcertificate/uuid-ca#ca")},
+		}
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 2)
+		setupPagedDiscovery(mockClientServices, certificates, "")
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByID(gomock.Any(), gomock.Eq("uuid-ca")).
+			Return(nil, fmt.Errorf("permission denied")).
+			Times(2)
+
+		discoveryServices := NewDiscoveryService(mockClientServices)
+
+		for _, strict := range []bool{false, true} {
+			response, code := runTenantDiscovery(t, e, discoveryServices, &DiscoverCertificatesRequest{
+				Configuration: DiscoverCertificatesConfiguration{
+					Strict:  strict,
+					Tenants: "Venafi",
+				},
+				Connection: &domain.Connection{
+					HostnameOrAddress: "localhost",
+					Password:          "password",
+					Username:          "user",
+				},
+				Control: DiscoveryControl{
+					MaxResults: 100,
+				},
+			})
+
+			// a certificate level failure is reported without stopping the discovery, even in strict mode
+			require.Equal(t, http.StatusOK, code)
+			require.Len(t, response.Messages, 3)
+			require.Empty(t, response.Messages[1].CertificateChain)
+			require.Empty(t, response.Errors)
+			require.Equal(t, []*DiscoveryIssue{
+				{
+					Tenant:     "Venafi",
+					ObjectType: DiscoveryIssueCertificate,
+					Object:     "Venafi-01",
+					Reason:     `incomplete certificate chain: failed to retrieve the CA certificate "https://localhost/api/sslkeyandcertificate/uuid-ca#ca": permission denied`,
+				},
+			}, response.Warnings)
+		}
+	})
+
+	t.Run("tenant_failure_strict", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		// every tenant, then the tenants completing the results, and the tenants after them when they start before the
+		// results are completed
+		setupExpectBoundedClientUsage(t, mockClientServices, 10, 14)
+		setupPagedDiscovery(mockClientServices, setupTenantCertificates(tenants, counts), "red")
+
+		discoveryServices := NewDiscoveryService(mockClientServices)
+
+		request := &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				Strict:            true,
+				TenantConcurrency: len(tenants),
+				Tenants:           strings.Join(tenants, ","),
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 100,
+			},
+		}
+
+		_, code := runTenantDiscovery(t, e, discoveryServices, request)
+		require.Equal(t, http.StatusBadRequest, code)
+
+		// a failing tenant beyond the results of the request is not reported
+		request.Control.MaxResults = 4
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, request)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 4)
+		require.NotNil(t, response.Page)
+		require.NotNil(t, response.Page.Tenant)
+		require.Equal(t, "green", *response.Page.Tenant)
+		require.NotEmpty(t, response.Page.Paginator)
+	})
+
+	t.Run("every_page_of_tenants", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 1)
+
+		names := make([]string, 150)
+		for idx := range names {
+			names[idx] = fmt.Sprintf("tenant-%03d", idx)
+		}
+
+		// the controller returns a page of tenants for each request
+		mockClientServices.EXPECT().
+			GetAllTenants(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Tenant, error) {
+				page, err := getParameterOptionsValue("page", options...)
+				require.NoError(t, err)
+				pageSize, err := getParameterOptionsValue("page_size", options...)
+				require.NoError(t, err)
+
+				number, err := strconv.Atoi(page)
+				require.NoError(t, err)
+				size, err := strconv.Atoi(pageSize)
+				require.NoError(t, err)
+
+				collection := make([]*models.Tenant, 0, size)
+				for idx := (number - 1) * size; idx < min(number*size, len(names)); idx++ {
+					collection = append(collection, &models.Tenant{Name: &names[idx]})
+				}
+
+				return collection, nil
+			}).
+			MinTimes(2)
+
+		discoveryServices := NewDiscoveryService(mockClientServices)
+
+		discovered, err := discoveryServices.getAllTenants(&domain.Connection{
+			HostnameOrAddress: "localhost",
+			Password:          "password",
+			Username:          "user",
+		})
+		require.NoError(t, err)
+		require.Equal(t, TenantNames(names), discovered)
+	})
+
+	t.Run("untrimmed_tenants", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 2)
+		setupPagedDiscovery(mockClientServices, setupTenantCertificates([]string{"admin", "Venafi"}, []int{2, 3}), "")
+
+		request := &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				Tenants: " admin, Venafi ,, admin",
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 100,
+			},
+		}
+
+		// the configured tenants are trimmed, and the empty and repeated names are dropped
+		response, code := runTenantDiscovery(t, e, NewDiscoveryService(mockClientServices), request)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 5)
+		require.Empty(t, response.Errors)
+		require.Nil(t, response.Page)
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		require.Equal(t, DefaultTenantConcurrency, getTenantConcurrency(&DiscoverCertificatesConfiguration{}))
+		require.Equal(t, 1, getTenantConcurrency(&DiscoverCertificatesConfiguration{TenantConcurrency: 1}))
+		require.Equal(t, MaxTenantConcurrency, getTenantConcurrency(&DiscoverCertificatesConfiguration{TenantConcurrency: MaxTenantConcurrency + 1}))
+	})
+}
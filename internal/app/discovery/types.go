@@ -5,7 +5,24 @@ import "github.com/venafi/vmware-avi-connector/internal/app/domain"
 type discoveredCertificateAndURL struct {
 	Name   string
 	Result *DiscoveredCertificate
+	Tenant string
 	UUID   string
+
+	// paginator is the position in the tenant certificates following the certificate, used to continue a discovery
+	// that stops at the certificate
+	paginator certificateDiscoveryPaginator
+	// warnings are the failures to read the details of the certificate, reported with the certificate
+	warnings []*DiscoveryIssue
+}
+
+// warn will record a failure to read a detail of the certificate
+func (dcr *discoveredCertificateAndURL) warn(tenant, objectType, object, reason string) {
+	dcr.warnings = append(dcr.warnings, &DiscoveryIssue{
+		Tenant:     tenant,
+		ObjectType: objectType,
+		Object:     object,
+		Reason:     reason,
+	})
 }
 
 // DiscoveryControl represents the Venafi defined definitions for discovery result processing
@@ -15,10 +32,22 @@ type DiscoveryControl struct {
 
 // DiscoverCertificatesConfiguration represents the discovery configuration settings defined by the manifest.json
 type DiscoverCertificatesConfiguration struct {
-	ExcludeExpiredCertificates  bool   `json:"excludeExpiredCertificates"`
-	ExcludeInactiveCertificates bool   `json:"excludeInactiveCertificates"`
-	Tenants                     string `json:"tenants"`
+	CertificateNameExclude      string   `json:"certificateNameExclude"`
+	CertificateNameInclude      string   `json:"certificateNameInclude"`
+	CertificateTypes            []string `json:"certificateTypes"`
+	ExcludeExpiredCertificates  bool     `json:"excludeExpiredCertificates"`
+	ExcludeInactiveCertificates bool     `json:"excludeInactiveCertificates"`
+	ExcludeTenants              string   `json:"excludeTenants"`
+	ExpiringWithinDays          int      `json:"expiringWithinDays"`
+	Incremental                 bool     `json:"incremental"`
+	Strict                      bool     `json:"strict"`
+	TenantConcurrency           int      `json:"tenantConcurrency"`
+	Tenants                     string   `json:"tenants"`
+	VirtualServiceNameExclude   string   `json:"virtualServiceNameExclude"`
+	VirtualServiceNameInclude   string   `json:"virtualServiceNameInclude"`
+	WildcardTenant              bool     `json:"wildcardTenant"`
 
+	filters *discoveryFilters
 	tenants TenantNames
 }
 
@@ -37,6 +66,15 @@ type DiscoveryPage struct {
 	Tenant *string `json:"discoveryType,omitempty"`
 	// The value for using when continuing the next discovery request.  This value is defined by the connector.
 	Paginator string `json:"paginator"`
+	// The high-water marks of an incremental discovery by tenant. A tenant not yet discovered has the mark of the
+	// previous discovery, and a discovered tenant has the mark to start the next incremental discovery from.
+	Watermarks map[string]*TenantWatermark `json:"watermarks,omitempty"`
+	// The certificates discovered by the previous requests of the discovery by fingerprint, so the duplicates of the
+	// whole discovery are returned when it completes.
+	Copies []*CertificateCopy `json:"copies,omitempty"`
+	// Copies were left out of the copies carried by the discovery page, the duplicates of the discovery may be
+	// incomplete
+	CopiesTruncated bool `json:"copiesTruncated,omitempty"`
 }
 
 // DiscoverCertificatesResponse represents the response to a discovery request
@@ -46,6 +84,99 @@ type DiscoverCertificatesResponse struct {
 	Page *DiscoveryPage `json:"discoveryPage"`
 	// The certificates and usage discovered during the current discovery request.
 	Messages []*DiscoveredCertificate `json:"messages"`
+	// The tenants of an incremental discovery whose certificates are all discovered, since a certificate or a usage
+	// may have been removed since the previous discovery.
+	Resynchronized []string `json:"resynchronized,omitempty"`
+	// The high-water marks to start the next incremental discovery from, set when an incremental discovery completes.
+	Watermarks map[string]*TenantWatermark `json:"watermarks,omitempty"`
+	// The certificate level failures of the current discovery request, the certificates are returned without the
+	// failed details.
+	Warnings []*DiscoveryIssue `json:"warnings,omitempty"`
+	// The tenant level failures of the current discovery request, the certificates of a failed tenant are skipped.
+	Errors []*DiscoveryIssue `json:"errors,omitempty"`
+	// The certificates of the discovery uploaded more than once grouped by fingerprint, returned when the discovery
+	// completes.
+	Duplicates []*DuplicateCertificateGroup `json:"duplicates,omitempty"`
+}
+
+// DiscoveryStreamTrailer is the last record of a streamed discovery, written once every discovered certificate has
+// been written
+type DiscoveryStreamTrailer struct {
+	// The current pagination state for the current discovery request.
+	// If the discovery is completed in the current discovery request then this value should be nil.
+	Page *DiscoveryPage `json:"discoveryPage"`
+	// The tenants of an incremental discovery whose certificates are all discovered, since a certificate or a usage
+	// may have been removed since the previous discovery.
+	Resynchronized []string `json:"resynchronized,omitempty"`
+	// The high-water marks to start the next incremental discovery from, set when an incremental discovery completes.
+	Watermarks map[string]*TenantWatermark `json:"watermarks,omitempty"`
+	// The certificate level failures of the current discovery request.
+	Warnings []*DiscoveryIssue `json:"warnings,omitempty"`
+	// The tenant level failures of the current discovery request.
+	Errors []*DiscoveryIssue `json:"errors,omitempty"`
+	// The certificates of the discovery uploaded more than once grouped by fingerprint, returned when the discovery
+	// completes.
+	Duplicates []*DuplicateCertificateGroup `json:"duplicates,omitempty"`
+	// The failure that stopped the discovery after certificates were written, the discovery page is not set and the
+	// discovery must be started again.
+	Failure string `json:"failure,omitempty"`
+}
+
+// CertificateCopy identifies a certificate discovered by a previous request of the discovery, its usage is read again
+// when it is reported as a duplicate
+type CertificateCopy struct {
+	// The SHA-256 fingerprint of the certificate
+	Fingerprint string `json:"fingerprint"`
+	// The tenant of the copy
+	Tenant string `json:"tenant"`
+	// The name of the copy
+	CertificateName string `json:"certificateName"`
+	// The UUID of the copy
+	UUID string `json:"uuid"`
+}
+
+// DuplicateCertificateGroup is the copies of a certificate found by a discovery
+type DuplicateCertificateGroup struct {
+	// The SHA-256 fingerprint shared by the copies
+	Fingerprint string `json:"fingerprint"`
+	// The copies of the certificate, in the order they were discovered
+	Certificates []*DuplicateCertificate `json:"certificates"`
+}
+
+// DuplicateCertificate is a copy of a certificate uploaded to a tenant
+type DuplicateCertificate struct {
+	// The tenant of the copy
+	Tenant string `json:"tenant"`
+	// The name of the copy
+	CertificateName string `json:"certificateName"`
+	// The usage of the copy, as reported by the bindings of its machine identities
+	Usage []*domain.Binding `json:"usage"`
+	// The copy has no usage and can be removed when consolidating the copies
+	Unused bool `json:"unused"`
+
+	// uuid identifies the copy, and carried is set for a copy discovered by a previous request of the discovery, whose
+	// usage is read again when it is reported
+	uuid    string
+	carried bool
+}
+
+const (
+	// DiscoveryIssueCertificate is the object type of a failure reading a certificate
+	DiscoveryIssueCertificate = "certificate"
+	// DiscoveryIssueVirtualService is the object type of a failure reading a virtual service
+	DiscoveryIssueVirtualService = "virtualService"
+)
+
+// DiscoveryIssue is a failure of the discovery that did not stop the discovery
+type DiscoveryIssue struct {
+	// The tenant being discovered, not set for a failure of the whole discovery
+	Tenant string `json:"tenant"`
+	// The type of the failed object, not set for a failure of the tenant
+	ObjectType string `json:"objectType,omitempty"`
+	// The name of the failed object, not set for a failure of the tenant
+	Object string `json:"object,omitempty"`
+	// The description of the failure
+	Reason string `json:"reason"`
 }
 
 // DiscoveredCertificate is a Venafi defined struct that represents a single certificate and it's usage found during a discovery
@@ -54,10 +185,14 @@ type DiscoveredCertificate struct {
 	Certificate string `json:"certificate"`
 	// The issuing chain of certificates ordered from the last intermediate issuing certificate to the root CA certificate
 	CertificateChain []string `json:"certificateChain"`
+	// The issuing chain ends with a self-signed root CA certificate
+	ChainComplete bool `json:"chainComplete"`
 	// The collection of virtual services using the certificate
 	Installations []*CertificateInstallation `json:"installations"`
 	// The collection of machine identities for the certificate
 	MachineIdentities []*MachineIdentity `json:"machineIdentities"`
+	// The analysis of the certificate, not set when the certificate cannot be parsed
+	Metadata *CertificateMetadata `json:"metadata,omitempty"`
 }
 
 // CertificateInstallation is a Venafi defined struct that represents usage of a discovered certificate
@@ -68,6 +203,22 @@ type CertificateInstallation struct {
 	IPAddress string `json:"ipAddress"`
 	// The port number
 	Port int `json:"port"`
+	// The runtime state of the virtual service serving the installation
+	VirtualService *VirtualServiceState `json:"virtualService,omitempty"`
+}
+
+// VirtualServiceState is the runtime state of a virtual service using a discovered certificate
+type VirtualServiceState struct {
+	// The virtual service is enabled
+	Enabled bool `json:"enabled"`
+	// The virtual service is enabled to receive traffic
+	TrafficEnabled bool `json:"trafficEnabled"`
+	// The operational state of the virtual service, such as OPER_UP, not set when the state could not be read
+	OperStatus string `json:"operStatus,omitempty"`
+	// The name of the cloud of the virtual service
+	Cloud string `json:"cloud,omitempty"`
+	// The name of the service engine group of the virtual service
+	ServiceEngineGroup string `json:"serviceEngineGroup,omitempty"`
 }
 
 // MachineIdentity is a Venafi defined struct that represents a certificate usage as found during a discovery
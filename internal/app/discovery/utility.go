@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
-	"time"
 	"unicode"
 	"unicode/utf8"
 
@@ -47,6 +46,22 @@ func getCertificateName(certificate *models.SSLKeyAndCertificate) string {
 	return "missing name"
 }
 
+// getCertificateUUID will return the UUID of the certificate, or an empty string when the certificate has no UUID
+func getCertificateUUID(certificate *models.SSLKeyAndCertificate) string {
+	if certificate.UUID != nil {
+		return *certificate.UUID
+	}
+
+	if certificate.URL != nil {
+		id, err := getUUIDFromURL(*certificate.URL)
+		if err == nil {
+			return id
+		}
+	}
+
+	return ""
+}
+
 func getUUIDFromURL(rawURL string) (string, error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
@@ -96,20 +111,6 @@ func getVirtualServiceName(virtualService *models.VirtualService) string {
 	return "missing name"
 }
 
-func isExpired(certificate *models.SSLCertificate) (expired bool, err error) {
-	notAfter := getValue(certificate.NotAfter)
-	if len(notAfter) == 0 {
-		return false, fmt.Errorf("no certificate expiration value")
-	}
-
-	t, err := time.Parse("2006-01-02 15:04:05", notAfter)
-	if err != nil {
-		return false, fmt.Errorf(`unable to parse certificate expiration value of "%s": %w`, notAfter, err)
-	}
-
-	return time.Now().After(t), nil
-}
-
 func lessLower(sa, sb string) bool {
 	for {
 		if len(sb) == 0 {
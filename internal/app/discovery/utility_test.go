@@ -3,7 +3,6 @@ package discovery
 import (
 	"sort"
 	"testing"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -75,24 +74,6 @@ func TestUtility(t *testing.T) {
 		require.Equal(t, value, getVirtualServiceName(vs))
 	})
 
-	t.Run("isExpired", func(t *testing.T) {
-		certificate := &models.SSLCertificate{}
-
-		notAfter := time.Now().Add(time.Hour * -24).Format(CertificateValidityDateFormat)
-		certificate.NotAfter = &notAfter
-
-		expired, err := isExpired(certificate)
-		require.NoError(t, err)
-		require.True(t, expired)
-
-		notAfter = time.Now().Add(time.Hour * 24).Format(CertificateValidityDateFormat)
-		certificate.NotAfter = &notAfter
-
-		expired, err = isExpired(certificate)
-		require.NoError(t, err)
-		require.False(t, expired)
-	})
-
 	t.Run("lessLower", func(t *testing.T) {
 		tenants := TenantNames{
 			"charlie",
@@ -1,7 +1,7 @@
 package discovery
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/venafi/vmware-avi-connector/internal/app/domain"
 	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
@@ -10,19 +10,8 @@ import (
 	"go.uber.org/zap"
 )
 
-func processVirtualServices(client *domain.Client, clientServices vmwareavi.ClientServices, dcr *discoveredCertificateAndURL) error {
-	var err error
-	var virtualServices []*models.VirtualService
-
-	virtualServices, err = clientServices.GetAllVirtualServices(client, session.SetParams(map[string]string{
-		"refers_to": fmt.Sprintf("sslkeyandcertificate:%s", dcr.UUID),
-	}))
-	if err != nil {
-		zap.L().Info("failed to read virtual services for certificate", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("certificateName", dcr.Name))
-		return err
-	}
-
-	for _, vs := range virtualServices {
+func processVirtualServices(client *domain.Client, clientServices vmwareavi.ClientServices, index *virtualServiceIndex, filters *discoveryFilters, dcr *discoveredCertificateAndURL) {
+	for _, vs := range index.lookup(dcr.UUID) {
 		zap.L().Info("discovered virtual service for tenant and certificate", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("certificateName", dcr.Name), zap.String("virtualService", getVirtualServiceName(vs)))
 
 		if vs.Name == nil {
@@ -30,6 +19,11 @@ func processVirtualServices(client *domain.Client, clientServices vmwareavi.Clie
 			continue
 		}
 
+		if !filters.includesVirtualService(*vs.Name) {
+			zap.L().Info("skipping virtual service excluded by the discovery filters", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("certificateName", dcr.Name), zap.String("virtualService", *vs.Name))
+			continue
+		}
+
 		mi := &MachineIdentity{
 			Keystore: &domain.Keystore{
 				CertificateName: dcr.Name,
@@ -37,11 +31,150 @@ func processVirtualServices(client *domain.Client, clientServices vmwareavi.Clie
 			},
 			Binding: &domain.Binding{
 				VirtualServiceName: *vs.Name,
+				UsageType:          domain.UsageTypeVirtualService,
 			},
 		}
 
+		setVirtualHostDetails(mi.Binding, vs)
+
+		var vsOptions []session.ApiOptionsParams
+		if tenant := vmwareavi.GetNameFromRef(vs.TenantRef); len(tenant) > 0 && !strings.EqualFold(tenant, client.Tenant) {
+			zap.L().Info("discovered shared certificate usage by virtual service of another tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("certificateName", dcr.Name), zap.String("virtualService", *vs.Name), zap.String("virtualServiceTenant", tenant))
+			mi.Binding.Tenant = tenant
+			vsOptions = append(vsOptions, session.SetOptTenant(tenant))
+		}
+
+		vsvip, err := index.getVsVip(client, clientServices, vs, vsOptions...)
+		if err != nil {
+			zap.L().Info("failed to read virtual service VIP", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("virtualService", *vs.Name), zap.Error(err))
+			dcr.warn(client.Tenant, DiscoveryIssueVirtualService, *vs.Name, err.Error())
+		}
+
+		mi.Binding.HostnameMismatches = getHostnameMismatches(client, dcr, vs, vmwareavi.GetHostnames(vs, vsvip))
+		dcr.Result.Installations = appendInstallations(dcr.Result.Installations, vs, vsvip, index.getState(vs))
+
 		dcr.Result.MachineIdentities = append(dcr.Result.MachineIdentities, mi)
 	}
+}
+
+// setVirtualHostDetails will set the type of the virtual service on the binding, with the parent and the virtual host
+// domain names of an SNI child virtual service
+func setVirtualHostDetails(binding *domain.Binding, vs *models.VirtualService) {
+	if vs.Type != nil {
+		binding.VirtualServiceType = *vs.Type
+	}
+
+	if binding.VirtualServiceType != vmwareavi.VirtualServiceTypeChild {
+		return
+	}
+
+	binding.ParentVirtualServiceName = vmwareavi.GetNameFromRef(vs.VhParentVsRef)
+	binding.VirtualHostDomainNames = vs.VhDomainName
+}
+
+// getHostnameMismatches will return the hostnames of the virtual service that are not covered by the discovered certificate.
+// A failure to read the hostnames or the certificate is logged and does not fail the discovery.
+func getHostnameMismatches(client *domain.Client, dcr *discoveredCertificateAndURL, vs *models.VirtualService, hostnames []string) []string {
+	if len(hostnames) == 0 {
+		return nil
+	}
+
+	unmatched, err := vmwareavi.GetUnmatchedHostnames(dcr.Result.Certificate, hostnames)
+	if err != nil {
+		zap.L().Info("failed to compare certificate names with virtual service hostnames", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("certificateName", dcr.Name), zap.Error(err))
+		return nil
+	}
+
+	if len(unmatched) > 0 {
+		zap.L().Info("discovered certificate does not cover the virtual service hostnames", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.String("certificateName", dcr.Name), zap.String("virtualService", getVirtualServiceName(vs)), zap.Strings("hostnames", unmatched))
+		return unmatched
+	}
 
 	return nil
 }
+
+// appendInstallations will add an installation for each VIP address and SSL enabled port of the virtual service, using
+// the VIP FQDN as the hostname when the VIP has DNS information. Each installation reports the state of the virtual service.
+func appendInstallations(installations []*CertificateInstallation, vs *models.VirtualService, vsvip *models.VsVip, state *VirtualServiceState) []*CertificateInstallation {
+	ports := vmwareavi.GetSslPorts(vs)
+	if len(ports) == 0 {
+		return installations
+	}
+
+	vips := vs.Vip
+	fqdn := ""
+	if vsvip != nil {
+		if len(vips) == 0 {
+			vips = vsvip.Vip
+		}
+
+		for _, dnsInfo := range vsvip.DNSInfo {
+			if dnsInfo != nil && dnsInfo.Fqdn != nil && len(*dnsInfo.Fqdn) > 0 {
+				fqdn = *dnsInfo.Fqdn
+				break
+			}
+		}
+	}
+
+	addresses := vmwareavi.GetVipAddresses(vips)
+	if len(addresses) == 0 && len(fqdn) > 0 {
+		addresses = []string{""}
+	}
+
+	for _, address := range addresses {
+		hostname := fqdn
+		if len(hostname) == 0 {
+			hostname = address
+		}
+
+		for _, port := range ports {
+			installation := &CertificateInstallation{
+				Hostname:       hostname,
+				IPAddress:      address,
+				Port:           port,
+				VirtualService: state,
+			}
+
+			if !containsInstallation(installations, installation) {
+				installations = append(installations, installation)
+			}
+		}
+	}
+
+	return installations
+}
+
+// containsInstallation will compare the installations and the states of their virtual services by value
+func containsInstallation(installations []*CertificateInstallation, installation *CertificateInstallation) bool {
+	for _, existing := range installations {
+		if existing.Hostname != installation.Hostname || existing.IPAddress != installation.IPAddress || existing.Port != installation.Port {
+			continue
+		}
+
+		if existing.VirtualService == nil || installation.VirtualService == nil {
+			if existing.VirtualService == installation.VirtualService {
+				return true
+			}
+
+			continue
+		}
+
+		if *existing.VirtualService == *installation.VirtualService {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getVirtualServiceUsageName will return the name of the first virtual service using the certificate, empty when the
+// certificate is not used by a virtual service
+func getVirtualServiceUsageName(dcr *discoveredCertificateAndURL) string {
+	for _, mi := range dcr.Result.MachineIdentities {
+		if mi.Binding != nil && mi.Binding.UsageType == domain.UsageTypeVirtualService {
+			return mi.Binding.VirtualServiceName
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,274 @@
+package discovery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestProcessVirtualServices(t *testing.T) {
+	t.Parallel()
+
+	t.Run("installations", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		enabled := true
+		disabled := false
+		https := uint32(443)
+		alternate := uint32(8443)
+		http := uint32(80)
+		services := []*models.Service{
+			{EnableSsl: &enabled, Port: &https},
+			{EnableSsl: &enabled, Port: &alternate},
+			{EnableSsl: &disabled, Port: &http},
+		}
+
+		vsvipRef := "https://localhost/api/vsvip/vsvip-web#vsvip-web"
+		certificateRef := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-web#web"
+
+		index := &virtualServiceIndex{
+			byCertificate: make(map[string][]*models.VirtualService),
+		}
+		for _, name := range []string{"vs-web-1", "vs-web-2"} {
+			index.add(&models.VirtualService{
+				Name:                     toPointer(name),
+				Services:                 services,
+				SslKeyAndCertificateRefs: []string{certificateRef},
+				VsvipRef:                 &vsvipRef,
+			})
+		}
+
+		mockClientServices.EXPECT().
+			GetVsVipByID(gomock.Any(), gomock.Eq("vsvip-web")).
+			Return(&models.VsVip{
+				DNSInfo: []*models.DNSInfo{{Fqdn: toPointer("www.example.com")}},
+				Vip: []*models.Vip{
+					{
+						FloatingIP: &models.IPAddr{Addr: toPointer("192.0.2.10")},
+						IPAddress:  &models.IPAddr{Addr: toPointer("10.0.0.10")},
+						Ip6Address: &models.IPAddr{Addr: toPointer("fd00::10")},
+					},
+				},
+			}, nil).
+			Times(1)
+
+		dcr := &discoveredCertificateAndURL{
+			Name: "web",
+			Result: &DiscoveredCertificate{
+				Certificate:       "-----BEGIN CERTIFICATE-----\nweb\n-----END CERTIFICATE-----\n",
+				Installations:     make([]*CertificateInstallation, 0),
+				MachineIdentities: make([]*MachineIdentity, 0),
+			},
+			UUID: "sslkeyandcertificate-web",
+		}
+
+		client := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+			Tenant:     "Venafi",
+		}
+
+		processVirtualServices(client, mockClientServices, index, nil, dcr)
+
+		require.Len(t, dcr.Result.MachineIdentities, 2)
+
+		// the virtual services sharing the VIP have the same state, so their installations are not repeated
+		state := &VirtualServiceState{Enabled: true, TrafficEnabled: true}
+		require.Equal(t, []*CertificateInstallation{
+			{Hostname: "www.example.com", IPAddress: "10.0.0.10", Port: 443, VirtualService: state},
+			{Hostname: "www.example.com", IPAddress: "10.0.0.10", Port: 8443, VirtualService: state},
+			{Hostname: "www.example.com", IPAddress: "fd00::10", Port: 443, VirtualService: state},
+			{Hostname: "www.example.com", IPAddress: "fd00::10", Port: 8443, VirtualService: state},
+			{Hostname: "www.example.com", IPAddress: "192.0.2.10", Port: 443, VirtualService: state},
+			{Hostname: "www.example.com", IPAddress: "192.0.2.10", Port: 8443, VirtualService: state},
+		}, dcr.Result.Installations)
+	})
+
+	t.Run("vip_failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		vsvipRef := "https://localhost/api/vsvip/vsvip-web#vsvip-web"
+
+		index := &virtualServiceIndex{
+			byCertificate: make(map[string][]*models.VirtualService),
+		}
+		index.add(&models.VirtualService{
+			Name:                     toPointer("vs-web"),
+			SslKeyAndCertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-web#web"},
+			VsvipRef:                 &vsvipRef,
+		})
+
+		mockClientServices.EXPECT().
+			GetVsVipByID(gomock.Any(), gomock.Eq("vsvip-web")).
+			Return(nil, fmt.Errorf("permission denied")).
+			Times(1)
+
+		dcr := &discoveredCertificateAndURL{
+			Name: "web",
+			Result: &DiscoveredCertificate{
+				Certificate:       "-----BEGIN CERTIFICATE-----\nweb\n-----END CERTIFICATE-----\n",
+				Installations:     make([]*CertificateInstallation, 0),
+				MachineIdentities: make([]*MachineIdentity, 0),
+			},
+			UUID: "sslkeyandcertificate-web",
+		}
+
+		client := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+			Tenant:     "Venafi",
+		}
+
+		processVirtualServices(client, mockClientServices, index, nil, dcr)
+
+		// the machine identity is reported without the VIP details
+		require.Len(t, dcr.Result.MachineIdentities, 1)
+		require.Equal(t, []*DiscoveryIssue{
+			{
+				Tenant:     "Venafi",
+				ObjectType: DiscoveryIssueVirtualService,
+				Object:     "vs-web",
+				Reason:     "failed to read virtual service VIP: permission denied",
+			},
+		}, dcr.warnings)
+	})
+
+	t.Run("state_failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		enabled := true
+		https := uint32(443)
+
+		index := &virtualServiceIndex{
+			byCertificate: make(map[string][]*models.VirtualService),
+			runtimeErr:    fmt.Errorf("failed to read the virtual service runtime: permission denied"),
+		}
+		index.add(&models.VirtualService{
+			Name:                     toPointer("vs-web"),
+			Services:                 []*models.Service{{EnableSsl: &enabled, Port: &https}},
+			SslKeyAndCertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-web#web"},
+			Vip:                      []*models.Vip{{IPAddress: &models.IPAddr{Addr: toPointer("10.0.0.10")}}},
+		})
+
+		dcr := &discoveredCertificateAndURL{
+			Name: "web",
+			Result: &DiscoveredCertificate{
+				Certificate:       "-----BEGIN CERTIFICATE-----\nweb\n-----END CERTIFICATE-----\n",
+				Installations:     make([]*CertificateInstallation, 0),
+				MachineIdentities: make([]*MachineIdentity, 0),
+			},
+			UUID: "sslkeyandcertificate-web",
+		}
+
+		client := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+			Tenant:     "Venafi",
+		}
+
+		processVirtualServices(client, mocks.NewMockClientServices(ctrl), index, nil, dcr)
+
+		// the installation is reported with the configured state, without the operational state
+		require.Equal(t, []*CertificateInstallation{
+			{Hostname: "10.0.0.10", IPAddress: "10.0.0.10", Port: 443, VirtualService: &VirtualServiceState{Enabled: true, TrafficEnabled: true}},
+		}, dcr.Result.Installations)
+
+		// the failure is reported once for the tenant by the discovery, not for every virtual service
+		require.Empty(t, dcr.warnings)
+	})
+
+	t.Run("sni", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		certificateRef := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-web#web"
+
+		index := &virtualServiceIndex{
+			byCertificate: make(map[string][]*models.VirtualService),
+		}
+		index.add(&models.VirtualService{
+			Name:                     toPointer("vs-parent"),
+			SslKeyAndCertificateRefs: []string{certificateRef},
+			Type:                     toPointer(vmwareavi.VirtualServiceTypeParent),
+		})
+		index.add(&models.VirtualService{
+			Name:                     toPointer("vs-child"),
+			SslKeyAndCertificateRefs: []string{certificateRef},
+			Type:                     toPointer(vmwareavi.VirtualServiceTypeChild),
+			VhDomainName:             []string{"www.example.com", "example.com"},
+			VhParentVsRef:            toPointer("https://localhost/api/virtualservice/virtualservice-parent#vs-parent"),
+		})
+		index.add(&models.VirtualService{
+			Name:                     toPointer("vs-plain"),
+			SslKeyAndCertificateRefs: []string{certificateRef},
+		})
+
+		dcr := &discoveredCertificateAndURL{
+			Name: "web",
+			Result: &DiscoveredCertificate{
+				Certificate:       "-----BEGIN CERTIFICATE-----\nweb\n-----END CERTIFICATE-----\n",
+				Installations:     make([]*CertificateInstallation, 0),
+				MachineIdentities: make([]*MachineIdentity, 0),
+			},
+			UUID: "sslkeyandcertificate-web",
+		}
+
+		client := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+			Tenant:     "Venafi",
+		}
+
+		processVirtualServices(client, mocks.NewMockClientServices(ctrl), index, nil, dcr)
+
+		bindings := make([]domain.Binding, 0)
+		for _, mi := range dcr.Result.MachineIdentities {
+			bindings = append(bindings, *mi.Binding)
+		}
+
+		// the child reports its parent and the domain names it is selected for
+		require.Equal(t, []domain.Binding{
+			{
+				VirtualServiceName: "vs-parent",
+				UsageType:          domain.UsageTypeVirtualService,
+				VirtualServiceType: vmwareavi.VirtualServiceTypeParent,
+			},
+			{
+				VirtualServiceName:       "vs-child",
+				UsageType:                domain.UsageTypeVirtualService,
+				VirtualServiceType:       vmwareavi.VirtualServiceTypeChild,
+				ParentVirtualServiceName: "vs-parent",
+				VirtualHostDomainNames:   []string{"www.example.com", "example.com"},
+			},
+			{
+				VirtualServiceName: "vs-plain",
+				UsageType:          domain.UsageTypeVirtualService,
+			},
+		}, bindings)
+	})
+
+	t.Run("installations_without_dns", func(t *testing.T) {
+		enabled := true
+		https := uint32(443)
+
+		installations := appendInstallations(nil, &models.VirtualService{
+			Services: []*models.Service{{EnableSsl: &enabled, Port: &https}},
+			Vip: []*models.Vip{
+				{FloatingIp6: &models.IPAddr{Addr: toPointer("2001:db8::1")}},
+			},
+		}, nil, nil)
+
+		require.Equal(t, []*CertificateInstallation{
+			{Hostname: "2001:db8::1", IPAddress: "2001:db8::1", Port: 443},
+		}, installations)
+	})
+}
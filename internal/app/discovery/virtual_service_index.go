@@ -0,0 +1,226 @@
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultVirtualServicePageSize is the number of virtual services per paged request when indexing the virtual services of a tenant
+	DefaultVirtualServicePageSize = 100
+	// DefaultVirtualServiceIndexTTL is the time an index of the virtual services of a tenant is reused by continuing discovery requests
+	DefaultVirtualServiceIndexTTL = 10 * time.Minute
+)
+
+// virtualServiceIndex maps the UUID of a certificate to the virtual services referencing the certificate
+type virtualServiceIndex struct {
+	tenant        string
+	built         time.Time
+	byCertificate map[string][]*models.VirtualService
+
+	// operStatus is the operational state of each virtual service by UUID, read from the virtual service inventory.
+	// runtimeErr is the failure to read the inventory, the virtual services are then reported without the state.
+	operStatus map[string]string
+	runtimeErr error
+
+	// vsvips caches the VIP of each virtual service by reference, since a VIP can be shared by virtual services
+	mutex  sync.Mutex
+	vsvips map[string]*models.VsVip
+}
+
+// virtualServiceIndexCache holds the virtual service index of each controller tenant and user so continuing discovery
+// requests reuse the index built when the discovery of the tenant started. An index is only reused with the
+// credentials it was read with, so a user never sees the virtual services read by the session of another user.
+type virtualServiceIndexCache struct {
+	mutex   sync.Mutex
+	indexes map[string]*virtualServiceIndex
+	ttl     time.Duration
+}
+
+func newVirtualServiceIndexCache() *virtualServiceIndexCache {
+	return &virtualServiceIndexCache{
+		indexes: make(map[string]*virtualServiceIndex),
+		ttl:     DefaultVirtualServiceIndexTTL,
+	}
+}
+
+// getVirtualServiceIndexKey will return the cache key of the index of the client tenant, including a hash of the
+// connection credentials rather than the credentials themselves
+func getVirtualServiceIndexKey(client *domain.Client) string {
+	credentials := sha256.Sum256([]byte(client.Connection.Username + "\x00" + client.Connection.Password))
+
+	return strings.ToLower(fmt.Sprintf("%s:%d/%s", client.Connection.HostnameOrAddress, client.Connection.Port, client.Tenant)) + "#" + hex.EncodeToString(credentials[:])
+}
+
+// get will return the cached index of the client tenant when reuse is allowed and the index has not expired,
+// otherwise the index is rebuilt and cached
+func (cache *virtualServiceIndexCache) get(client *domain.Client, clientServices vmwareavi.ClientServices, reuse bool) (*virtualServiceIndex, error) {
+	key := getVirtualServiceIndexKey(client)
+	now := time.Now()
+
+	cache.mutex.Lock()
+	for k, index := range cache.indexes {
+		if now.Sub(index.built) > cache.ttl {
+			delete(cache.indexes, k)
+		}
+	}
+
+	index, ok := cache.indexes[key]
+	cache.mutex.Unlock()
+
+	if ok && reuse {
+		return index, nil
+	}
+
+	index, err := buildVirtualServiceIndex(client, clientServices)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mutex.Lock()
+	cache.indexes[key] = index
+	cache.mutex.Unlock()
+
+	return index, nil
+}
+
+// buildVirtualServiceIndex will read every virtual service of the tenant once and index them by the certificates they reference.
+// Certificates of the admin tenant can be shared with, and used by, the virtual services of other tenants.
+func buildVirtualServiceIndex(client *domain.Client, clientServices vmwareavi.ClientServices) (*virtualServiceIndex, error) {
+	index := &virtualServiceIndex{
+		tenant:        client.Tenant,
+		built:         time.Now(),
+		byCertificate: make(map[string][]*models.VirtualService),
+		operStatus:    make(map[string]string),
+		vsvips:        make(map[string]*models.VsVip),
+	}
+
+	params := getTenantScopeParams(client, DefaultVirtualServicePageSize)
+	params["include_name"] = "true"
+
+	virtualServices, err := vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+		return clientServices.GetAllVirtualServices(client, options...)
+	}, params, getTenantScope(client)...)
+	if err != nil {
+		zap.L().Info("failed to read virtual services for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+		return nil, err
+	}
+
+	for _, vs := range virtualServices {
+		index.add(vs)
+	}
+
+	index.readOperStatus(client, clientServices)
+
+	zap.L().Info("indexed virtual services for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Int("certificates", len(index.byCertificate)))
+	return index, nil
+}
+
+// readOperStatus will read the operational state of every virtual service of the tenant from the virtual service
+// inventory, in bulk rather than from the runtime of each virtual service. The inventory is read in the same tenant
+// scope as the virtual services.
+func (index *virtualServiceIndex) readOperStatus(client *domain.Client, clientServices vmwareavi.ClientServices) {
+	inventories, err := vmwareavi.GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.VsInventory, error) {
+		return clientServices.GetAllVirtualServiceInventories(client, options...)
+	}, getTenantScopeParams(client, DefaultVirtualServicePageSize), getTenantScope(client)...)
+	if err != nil {
+		zap.L().Info("failed to read virtual service inventory for tenant", zap.String("hostname", client.Connection.HostnameOrAddress), zap.Int("port", client.Connection.Port), zap.String("tenant", client.Tenant), zap.Error(err))
+		index.runtimeErr = fmt.Errorf("failed to read the virtual service runtime: %w", err)
+		return
+	}
+
+	for _, inventory := range inventories {
+		if inventory.Runtime == nil || inventory.Runtime.OperStatus == nil || inventory.Runtime.OperStatus.State == nil {
+			continue
+		}
+
+		id := ""
+		if inventory.Config != nil && inventory.Config.UUID != nil {
+			id = *inventory.Config.UUID
+		} else if inventory.UUID != nil {
+			id = *inventory.UUID
+		}
+
+		if len(id) > 0 {
+			index.operStatus[id] = *inventory.Runtime.OperStatus.State
+		}
+	}
+}
+
+func (index *virtualServiceIndex) add(vs *models.VirtualService) {
+	seen := map[string]bool{}
+	for _, ref := range vs.SslKeyAndCertificateRefs {
+		id, err := getUUIDFromURL(ref)
+		if err != nil || len(id) == 0 || seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		index.byCertificate[id] = append(index.byCertificate[id], vs)
+	}
+}
+
+// getVsVip will return the VIP of the virtual service, or nil when the virtual service does not reference a VIP
+func (index *virtualServiceIndex) getVsVip(client *domain.Client, clientServices vmwareavi.ClientServices, vs *models.VirtualService, options ...session.ApiOptionsParams) (*models.VsVip, error) {
+	if vs.VsvipRef == nil || len(*vs.VsvipRef) == 0 {
+		return nil, nil
+	}
+
+	index.mutex.Lock()
+	vsvip, ok := index.vsvips[*vs.VsvipRef]
+	index.mutex.Unlock()
+	if ok {
+		return vsvip, nil
+	}
+
+	id, err := getUUIDFromURL(*vs.VsvipRef)
+	if err != nil {
+		return nil, err
+	}
+
+	vsvip, err = clientServices.GetVsVipByID(client, id, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read virtual service VIP: %w", err)
+	}
+
+	index.mutex.Lock()
+	if index.vsvips == nil {
+		index.vsvips = make(map[string]*models.VsVip)
+	}
+	index.vsvips[*vs.VsvipRef] = vsvip
+	index.mutex.Unlock()
+
+	return vsvip, nil
+}
+
+// lookup will return the virtual services referencing the certificate
+func (index *virtualServiceIndex) lookup(certificateUUID string) []*models.VirtualService {
+	return index.byCertificate[certificateUUID]
+}
+
+// getState will return the runtime state of the virtual service, a virtual service and its traffic are enabled unless
+// they are disabled by the virtual service
+func (index *virtualServiceIndex) getState(vs *models.VirtualService) *VirtualServiceState {
+	state := &VirtualServiceState{
+		Enabled:            vs.Enabled == nil || *vs.Enabled,
+		TrafficEnabled:     vs.TrafficEnabled == nil || *vs.TrafficEnabled,
+		Cloud:              vmwareavi.GetNameFromRef(vs.CloudRef),
+		ServiceEngineGroup: vmwareavi.GetNameFromRef(vs.SeGroupRef),
+	}
+
+	if vs.UUID != nil {
+		state.OperStatus = index.operStatus[*vs.UUID]
+	}
+
+	return state
+}
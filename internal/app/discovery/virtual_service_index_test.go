@@ -0,0 +1,533 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+// setupExpectNoVirtualServiceInventory will expect the virtual service inventory reads of a discovery, returning no
+// operational state
+func setupExpectNoVirtualServiceInventory(clientServices *mocks.MockClientServices) {
+	clientServices.EXPECT().
+		GetAllVirtualServiceInventories(gomock.Any(), gomock.Any()).
+		Return([]*models.VsInventory{}, nil).
+		AnyTimes()
+}
+
+func TestVirtualServiceIndex(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lookup", func(t *testing.T) {
+		index := &virtualServiceIndex{
+			byCertificate: make(map[string][]*models.VirtualService),
+		}
+
+		index.add(&models.VirtualService{
+			Name: toPointer("vs1"),
+			SslKeyAndCertificateRefs: []string{
+				"https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-a#a",
+				"https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-b#b",
+			},
+		})
+		index.add(&models.VirtualService{
+			Name: toPointer("vs2"),
+			SslKeyAndCertificateRefs: []string{
+				"https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-a#a",
+				"https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-a#a",
+			},
+		})
+
+		require.Len(t, index.lookup("sslkeyandcertificate-a"), 2)
+		require.Len(t, index.lookup("sslkeyandcertificate-b"), 1)
+		require.Empty(t, index.lookup("sslkeyandcertificate-c"))
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectNoVirtualServiceInventory(mockClientServices)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return([]*models.VirtualService{}, nil).
+			Times(4)
+
+		client := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+			Tenant:     "Venafi",
+		}
+
+		cache := newVirtualServiceIndexCache()
+
+		first, err := cache.get(client, mockClientServices, false)
+		require.NoError(t, err)
+
+		reused, err := cache.get(client, mockClientServices, true)
+		require.NoError(t, err)
+		require.Same(t, first, reused)
+
+		rebuilt, err := cache.get(client, mockClientServices, false)
+		require.NoError(t, err)
+		require.NotSame(t, first, rebuilt)
+
+		cache.ttl = 0
+		time.Sleep(time.Millisecond)
+
+		expired, err := cache.get(client, mockClientServices, true)
+		require.NoError(t, err)
+		require.NotSame(t, rebuilt, expired)
+
+		// the index read with the credentials of another user is not reused
+		cache.ttl = DefaultVirtualServiceIndexTTL
+
+		other := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443, Username: "other", Password: "password"},
+			Tenant:     "Venafi",
+		}
+
+		separate, err := cache.get(other, mockClientServices, true)
+		require.NoError(t, err)
+		require.NotSame(t, expired, separate)
+		require.NotContains(t, getVirtualServiceIndexKey(other), "password")
+	})
+
+	t.Run("state", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		disabled := false
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+				includeName, _ := getParameterOptionsValue("include_name", options...)
+				require.Equal(t, "true", includeName)
+
+				return []*models.VirtualService{
+					{
+						CloudRef:   toPointer("https://localhost/api/cloud/cloud-default#Default-Cloud"),
+						Name:       toPointer("vs-live"),
+						SeGroupRef: toPointer("https://localhost/api/serviceenginegroup/seg-default#Default-Group"),
+						UUID:       toPointer("virtualservice-live"),
+					},
+					{
+						Enabled:        &disabled,
+						Name:           toPointer("vs-disabled"),
+						TrafficEnabled: &disabled,
+						UUID:           toPointer("virtualservice-disabled"),
+					},
+				}, nil
+			}).
+			Times(1)
+
+		// the operational state of every virtual service of the tenant is read in one request
+		mockClientServices.EXPECT().
+			GetAllVirtualServiceInventories(gomock.Any(), gomock.Any()).
+			Return([]*models.VsInventory{
+				{
+					Config:  &models.VsInventoryConfig{UUID: toPointer("virtualservice-live")},
+					Runtime: &models.VsRuntimeSummary{OperStatus: &models.OperationalStatus{State: toPointer("OPER_UP")}},
+				},
+				{
+					Config:  &models.VsInventoryConfig{UUID: toPointer("virtualservice-disabled")},
+					Runtime: &models.VsRuntimeSummary{OperStatus: &models.OperationalStatus{State: toPointer("OPER_DISABLED")}},
+				},
+			}, nil).
+			Times(1)
+
+		client := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+			Tenant:     "Venafi",
+		}
+
+		index, err := buildVirtualServiceIndex(client, mockClientServices)
+		require.NoError(t, err)
+		require.NoError(t, index.runtimeErr)
+
+		require.Equal(t, &VirtualServiceState{
+			Enabled:            true,
+			TrafficEnabled:     true,
+			OperStatus:         "OPER_UP",
+			Cloud:              "Default-Cloud",
+			ServiceEngineGroup: "Default-Group",
+		}, index.getState(&models.VirtualService{
+			CloudRef:   toPointer("https://localhost/api/cloud/cloud-default#Default-Cloud"),
+			SeGroupRef: toPointer("https://localhost/api/serviceenginegroup/seg-default#Default-Group"),
+			UUID:       toPointer("virtualservice-live"),
+		}))
+
+		require.Equal(t, &VirtualServiceState{
+			OperStatus: "OPER_DISABLED",
+		}, index.getState(&models.VirtualService{
+			Enabled:        &disabled,
+			TrafficEnabled: &disabled,
+			UUID:           toPointer("virtualservice-disabled"),
+		}))
+	})
+
+	t.Run("state_failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return([]*models.VirtualService{}, nil).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetAllVirtualServiceInventories(gomock.Any(), gomock.Any()).
+			Return(nil, fmt.Errorf("permission denied")).
+			Times(1)
+
+		client := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+			Tenant:     "Venafi",
+		}
+
+		// the virtual services are indexed without their operational state
+		index, err := buildVirtualServiceIndex(client, mockClientServices)
+		require.NoError(t, err)
+		require.EqualError(t, index.runtimeErr, "failed to read the virtual service runtime: permission denied")
+	})
+
+	t.Run("admin_scope", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		// the inventory is read in the same tenant scope as the virtual services
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+				require.Equal(t, vmwareavi.WildcardTenantName, getTenantOptionValue(options...))
+
+				return []*models.VirtualService{}, nil
+			}).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetAllVirtualServiceInventories(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VsInventory, error) {
+				require.Equal(t, vmwareavi.WildcardTenantName, getTenantOptionValue(options...))
+
+				return []*models.VsInventory{}, nil
+			}).
+			Times(1)
+
+		client := &domain.Client{
+			Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+			Tenant:     vmwareavi.DefaultTenantName,
+		}
+
+		_, err := buildVirtualServiceIndex(client, mockClientServices)
+		require.NoError(t, err)
+	})
+
+	t.Run("state_failure_discovery", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 1)
+
+		certificates := setupTenantCertificates([]string{"Venafi"}, []int{2})
+		setupExpectGetAllSSLKeysAndCertificates(mockClientServices, certificates, 1, 1)
+		setupExpectNoCertificateUsage(mockClientServices)
+
+		virtualServices := make([]*models.VirtualService, 0)
+		for _, certificate := range certificates["Venafi"] {
+			for n := 0; n < 2; n++ {
+				virtualServices = append(virtualServices, &models.VirtualService{
+					Name:                     toPointer(fmt.Sprintf("%s-vs-%d", *certificate.Name, n)),
+					SslKeyAndCertificateRefs: []string{*certificate.URL},
+				})
+			}
+		}
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return(virtualServices, nil).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetAllVirtualServiceInventories(gomock.Any(), gomock.Any()).
+			Return(nil, fmt.Errorf("permission denied")).
+			Times(1)
+
+		request := &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				Tenants: "Venafi",
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 100,
+			},
+		}
+
+		// the failure is reported once for the tenant, not for each virtual service of each certificate
+		response, code := runTenantDiscovery(t, echo.New(), NewDiscoveryService(mockClientServices), request)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 2)
+		require.Equal(t, []*DiscoveryIssue{
+			{Tenant: "Venafi", ObjectType: DiscoveryIssueVirtualService, Object: "Venafi-00-vs-0", Reason: "failed to read the virtual service runtime: permission denied"},
+		}, response.Warnings)
+	})
+
+	t.Run("state_failure_continuation", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(t, mockClientServices, 2)
+
+		certificates := setupTenantCertificates([]string{"Venafi"}, []int{2})
+		setupExpectGetAllSSLKeysAndCertificates(mockClientServices, certificates, 2, 2)
+		setupExpectNoCertificateUsage(mockClientServices)
+
+		virtualServices := make([]*models.VirtualService, 0)
+		for _, certificate := range certificates["Venafi"] {
+			for n := 0; n < 2; n++ {
+				virtualServices = append(virtualServices, &models.VirtualService{
+					Name:                     toPointer(fmt.Sprintf("%s-vs-%d", *certificate.Name, n)),
+					SslKeyAndCertificateRefs: []string{*certificate.URL},
+				})
+			}
+		}
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return(virtualServices, nil).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetAllVirtualServiceInventories(gomock.Any(), gomock.Any()).
+			Return(nil, fmt.Errorf("permission denied")).
+			Times(1)
+
+		request := &DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				Tenants: "Venafi",
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: 1,
+			},
+		}
+
+		// a continuing request reuses the index, and reports the failure again with its own certificates
+		discoveryServices := NewDiscoveryService(mockClientServices)
+		for _, name := range []string{"Venafi-00", "Venafi-01"} {
+			response, code := runTenantDiscovery(t, echo.New(), discoveryServices, request)
+			require.Equal(t, http.StatusOK, code)
+			require.Len(t, response.Messages, 1)
+			require.Equal(t, []*DiscoveryIssue{
+				{Tenant: "Venafi", ObjectType: DiscoveryIssueVirtualService, Object: name + "-vs-0", Reason: "failed to read the virtual service runtime: permission denied"},
+			}, response.Warnings)
+
+			request.Page = response.Page
+		}
+	})
+
+	for _, tc := range []struct {
+		name          string
+		configuration DiscoverCertificatesConfiguration
+	}{
+		{name: "index_failure_discovery", configuration: DiscoverCertificatesConfiguration{Tenants: "Venafi"}},
+		{name: "index_failure_inactive_discovery", configuration: DiscoverCertificatesConfiguration{Tenants: "Venafi", ExcludeInactiveCertificates: true}},
+		{name: "index_failure_strict_discovery", configuration: DiscoverCertificatesConfiguration{Tenants: "Venafi", Strict: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClientServices := mocks.NewMockClientServices(ctrl)
+			setupExpectClientUsage(t, mockClientServices, 1)
+
+			certificates := setupTenantCertificates([]string{"Venafi"}, []int{2})
+			setupExpectGetAllSSLKeysAndCertificates(mockClientServices, certificates, 1, 1)
+			setupExpectNoCertificateUsage(mockClientServices)
+
+			// the index is not built again for each certificate
+			mockClientServices.EXPECT().
+				GetAllVirtualServices(gomock.Any(), gomock.Any()).
+				Return(nil, fmt.Errorf("permission denied")).
+				Times(1)
+
+			request := &DiscoverCertificatesRequest{
+				Configuration: tc.configuration,
+				Connection: &domain.Connection{
+					HostnameOrAddress: "localhost",
+					Password:          "password",
+					Username:          "user",
+				},
+				Control: DiscoveryControl{
+					MaxResults: 100,
+				},
+			}
+
+			response, code := runTenantDiscovery(t, echo.New(), NewDiscoveryService(mockClientServices), request)
+
+			// a strict discovery fails the tenant, and the request
+			if tc.configuration.Strict {
+				require.Equal(t, http.StatusBadRequest, code)
+				return
+			}
+
+			// the certificates are discovered without their virtual service usage, and the tenant is not failed. The
+			// certificates are kept when inactive certificates are excluded, since their usage is unknown.
+			require.Equal(t, http.StatusOK, code)
+			require.Len(t, response.Messages, 2)
+			require.Empty(t, response.Errors)
+			require.Len(t, response.Warnings, 2)
+			for idx, warning := range response.Warnings {
+				require.Equal(t, DiscoveryIssueCertificate, warning.ObjectType)
+				require.Equal(t, fmt.Sprintf("Venafi-%02d", idx), warning.Object)
+				require.Contains(t, warning.Reason, "incomplete certificate usage")
+				require.Contains(t, warning.Reason, "permission denied")
+			}
+
+			for _, dc := range response.Messages {
+				require.Empty(t, dc.MachineIdentities)
+			}
+		})
+	}
+}
+
+// BenchmarkDiscoveryVirtualServiceRequests reports the number of virtual service requests made to discover the usage
+// of every certificate of a tenant, which was one request per certificate before the virtual services were indexed
+func BenchmarkDiscoveryVirtualServiceRequests(b *testing.B) {
+	const certificateCount = 2000
+
+	e := echo.New()
+
+	certificates := make([]*models.SSLKeyAndCertificate, 0, certificateCount)
+	virtualServices := make([]*models.VirtualService, 0, certificateCount)
+	for i := 0; i < certificateCount; i++ {
+		id := fmt.Sprintf("sslkeyandcertificate-%d", i)
+		certificates = append(certificates, &models.SSLKeyAndCertificate{
+			Certificate: &models.SSLCertificate{
+				Certificate: toPointer(fmt.Sprintf("-----BEGIN CERTIFICATE-----\n%d\n-----END CERTIFICATE-----\n", i)),
+			},
+			Name: toPointer(fmt.Sprintf("certificate-%d", i)),
+			UUID: toPointer(id),
+			URL:  toPointer("https://localhost/api/sslkeyandcertificate/" + id),
+		})
+		virtualServices = append(virtualServices, &models.VirtualService{
+			Name:                     toPointer(fmt.Sprintf("vs-%d", i)),
+			SslKeyAndCertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/" + id},
+		})
+	}
+
+	getPage := func(options []session.ApiOptionsParams, total int) (int, int) {
+		page, _ := getParameterOptionsValue("page", options...)
+		pageSize, _ := getParameterOptionsValue("page_size", options...)
+
+		p, _ := strconv.Atoi(page)
+		size, _ := strconv.Atoi(pageSize)
+
+		start := (p - 1) * size
+		if start > total {
+			start = total
+		}
+
+		end := start + size
+		if end > total {
+			end = total
+		}
+
+		return start, end
+	}
+
+	requests := 0
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+
+		ctrl := gomock.NewController(b)
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		setupExpectClientUsage(b, mockClientServices, 1)
+
+		mockClientServices.EXPECT().
+			GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+				return getCertificateCursorPage(certificates, options...), nil
+			}).
+			AnyTimes()
+
+		setupExpectNoCertificateUsage(mockClientServices)
+		setupExpectNoVirtualServiceInventory(mockClientServices)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+				requests++
+
+				start, end := getPage(options, len(virtualServices))
+				return virtualServices[start:end], nil
+			}).
+			AnyTimes()
+
+		raw, err := json.Marshal(&DiscoverCertificatesRequest{
+			Configuration: DiscoverCertificatesConfiguration{
+				Tenants: "Venafi",
+			},
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Username:          "user",
+			},
+			Control: DiscoveryControl{
+				MaxResults: certificateCount + 1,
+			},
+		})
+		require.NoError(b, err)
+
+		recorder, ctx := setupPost(e, "/v1/discovercertificates", bytes.NewReader(raw))
+
+		b.StartTimer()
+
+		err = NewDiscoveryService(mockClientServices).DiscoverCertificates(ctx)
+		require.NoError(b, err)
+
+		b.StopTimer()
+
+		response := DiscoverCertificatesResponse{}
+		err = json.Unmarshal(recorder.Body.Bytes(), &response)
+		require.NoError(b, err)
+		require.Len(b, response.Messages, certificateCount)
+
+		ctrl.Finish()
+
+		b.StartTimer()
+	}
+
+	b.ReportMetric(float64(requests)/float64(b.N), "vs-requests/op")
+	b.ReportMetric(certificateCount, "certificates/op")
+}
@@ -0,0 +1,112 @@
+package discovery
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+)
+
+// tenantScopedClientServices sends the requests of a client to the tenant of the client, so a client sharing the
+// session of the wildcard tenant discovery reads the objects of its own tenant. A tenant set by the options of a
+// request is kept.
+type tenantScopedClientServices struct {
+	vmwareavi.ClientServices
+}
+
+func scopeToTenant(client *domain.Client, options []session.ApiOptionsParams) []session.ApiOptionsParams {
+	return append([]session.ApiOptionsParams{session.SetOptTenant(client.Tenant)}, options...)
+}
+
+// GetAllSSLKeysAndCertificates will return the SSLKeyAndCertificate objects of the client tenant
+func (s *tenantScopedClientServices) GetAllSSLKeysAndCertificates(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+	return s.ClientServices.GetAllSSLKeysAndCertificates(client, scopeToTenant(client, options)...)
+}
+
+// GetAllVirtualServices will return the VirtualService objects of the client tenant
+func (s *tenantScopedClientServices) GetAllVirtualServices(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+	return s.ClientServices.GetAllVirtualServices(client, scopeToTenant(client, options)...)
+}
+
+// GetSSLKeyAndCertificateByID will return a SSLKeyAndCertificate object of the client tenant by UUID
+func (s *tenantScopedClientServices) GetSSLKeyAndCertificateByID(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.SSLKeyAndCertificate, error) {
+	return s.ClientServices.GetSSLKeyAndCertificateByID(client, uuid, scopeToTenant(client, options)...)
+}
+
+// GetSSLKeyAndCertificateByName will return a SSLKeyAndCertificate object of the client tenant by name
+func (s *tenantScopedClientServices) GetSSLKeyAndCertificateByName(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.SSLKeyAndCertificate, error) {
+	return s.ClientServices.GetSSLKeyAndCertificateByName(client, name, scopeToTenant(client, options)...)
+}
+
+// GetVsVipByID will return a VsVip object of the client tenant by UUID
+func (s *tenantScopedClientServices) GetVsVipByID(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.VsVip, error) {
+	return s.ClientServices.GetVsVipByID(client, uuid, scopeToTenant(client, options)...)
+}
+
+// discoverWildcardTenant will discover up to maxResults certificates of every tenant using a single session of the
+// admin tenant, reading the certificates of all tenants with the wildcard tenant. The certificates are grouped by the
+// tenant of their tenant_ref, and the discovery page identifies the wildcard tenant. The certificates of a streamed
+// discovery are received by the outcome as they are built.
+func (svc *DiscoveryService) discoverWildcardTenant(outcome *tenantDiscovery, connection *domain.Connection, configuration *DiscoverCertificatesConfiguration, maxResults int, paginator string) {
+	client := svc.ClientServices.NewClient(connection, vmwareavi.DefaultTenantName)
+	err := svc.ClientServices.Connect(client)
+	defer func() {
+		svc.ClientServices.Close(client)
+	}()
+	if err != nil {
+		outcome.err = err
+		return
+	}
+
+	csp := newCertificateDiscovery(&tenantScopedClientServices{ClientServices: svc.ClientServices}, connection, configuration, &DiscoveryControl{MaxResults: maxResults})
+	csp.virtualServiceIndexes = svc.virtualServiceIndexes
+	csp.wildcard = true
+
+	if outcome.certificates != nil {
+		csp.discovered = func(dcr *discoveredCertificateAndURL) {
+			outcome.certificates <- dcr
+		}
+	}
+
+	page := &DiscoveryPage{
+		Tenant:    &outcome.tenant,
+		Paginator: paginator,
+	}
+
+	outcome.finished, outcome.discovered, outcome.err = csp.discover(client, page)
+	outcome.paginator = page.Paginator
+}
+
+// includesTenant will check a tenant of the wildcard tenant discovery against the configured tenants and the tenant
+// filters, every tenant is included when no tenants are configured
+func (p *certificateDiscoveryProcessor) includesTenant(tenant string) bool {
+	if len(p.configuration.tenants) > 0 && !slices.ContainsFunc(p.configuration.tenants, func(name string) bool { return strings.EqualFold(name, tenant) }) {
+		return false
+	}
+
+	return p.configuration.filters.includesTenant(tenant)
+}
+
+// getTenantClient will return a client of the tenant sharing the session of the wildcard tenant discovery
+func (p *certificateDiscoveryProcessor) getTenantClient(client *domain.Client, tenant string) *domain.Client {
+	if len(tenant) == 0 || strings.EqualFold(tenant, client.Tenant) {
+		return client
+	}
+
+	key := strings.ToLower(tenant)
+	if tenantClient, ok := p.tenantClients[key]; ok {
+		return tenantClient
+	}
+
+	tenantClient := &domain.Client{
+		Connection: client.Connection,
+		Session:    client.Session,
+		Tenant:     tenant,
+	}
+
+	p.tenantClients[key] = tenantClient
+	return tenantClient
+}
@@ -0,0 +1,243 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	vmwareavi "github.com/venafi/vmware-avi-connector/internal/app/vmware-avi"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+// setupWildcardController will expect sessions of the admin tenant, and return the certificates of every tenant for
+// the wildcard tenant. The returned function is the number of sessions opened.
+func setupWildcardController(t *testing.T, certificates map[string][]*models.SSLKeyAndCertificate, virtualServices []*models.VirtualService) (*DiscoveryService, func() int) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	mockClientServices := mocks.NewMockClientServices(ctrl)
+
+	var sessions, open atomic.Int32
+
+	mockClientServices.EXPECT().
+		NewClient(gomock.Any(), gomock.Eq(vmwareavi.DefaultTenantName)).
+		DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+			sessions.Add(1)
+			open.Add(1)
+			return &domain.Client{
+				Connection: connection,
+				Tenant:     tenant,
+			}
+		}).
+		AnyTimes()
+	mockClientServices.EXPECT().
+		Connect(gomock.Any()).
+		Return(nil).
+		AnyTimes()
+	mockClientServices.EXPECT().
+		Close(gomock.Any()).
+		Do(func(client *domain.Client) {
+			open.Add(-1)
+		}).
+		AnyTimes()
+
+	t.Cleanup(func() {
+		require.Zero(t, open.Load())
+	})
+
+	all := make([]*models.SSLKeyAndCertificate, 0)
+	for tenant, collection := range certificates {
+		for _, certificate := range collection {
+			certificate.TenantRef = toPointer("https://localhost/api/tenant/tenant-" + tenant + "#" + tenant)
+			all = append(all, certificate)
+		}
+	}
+
+	mockClientServices.EXPECT().
+		GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
+			tenant := getTenantOptionValue(options...)
+			if tenant == vmwareavi.WildcardTenantName {
+				includeName, _ := getParameterOptionsValue("include_name", options...)
+				require.Equal(t, "true", includeName)
+
+				return getCertificateCursorPage(all, options...), nil
+			}
+
+			return getCertificateCursorPage(certificates[tenant], options...), nil
+		}).
+		AnyTimes()
+
+	setupExpectNoCertificateUsage(mockClientServices)
+	setupExpectNoVirtualServiceInventory(mockClientServices)
+
+	mockClientServices.EXPECT().
+		GetAllVirtualServices(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+			require.Equal(t, vmwareavi.WildcardTenantName, getTenantOptionValue(options...))
+
+			page, _ := getParameterOptionsValue("page", options...)
+			if page != "1" {
+				return nil, session.AviError{AviResult: session.AviResult{Message: toPointer("That page contains no results")}}
+			}
+
+			return virtualServices, nil
+		}).
+		AnyTimes()
+
+	return NewDiscoveryService(mockClientServices), func() int { return int(sessions.Load()) }
+}
+
+func newWildcardRequest(configuration DiscoverCertificatesConfiguration, maxResults int) *DiscoverCertificatesRequest {
+	configuration.WildcardTenant = true
+
+	return &DiscoverCertificatesRequest{
+		Configuration: configuration,
+		Connection: &domain.Connection{
+			HostnameOrAddress: "localhost",
+			Password:          "password",
+			Username:          "user",
+		},
+		Control: DiscoveryControl{
+			MaxResults: maxResults,
+		},
+	}
+}
+
+func TestWildcardTenantDiscovery(t *testing.T) {
+	e := echo.New()
+
+	tenants := []string{"admin", "Blue", "green"}
+
+	virtualServices := []*models.VirtualService{
+		{
+			Name:                     toPointer("blue-vs"),
+			SslKeyAndCertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/uuid-Blue-01"},
+			TenantRef:                toPointer("https://localhost/api/tenant/tenant-Blue#Blue"),
+		},
+		{
+			Name:                     toPointer("green-vs"),
+			SslKeyAndCertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/uuid-admin-00"},
+			TenantRef:                toPointer("https://localhost/api/tenant/tenant-green#green"),
+		},
+	}
+
+	// runWildcardDiscovery will run every request of the discovery, returning the discovered machine identities
+	runWildcardDiscovery := func(t *testing.T, discoveryServices *DiscoveryService, sessions func() int, request *DiscoverCertificatesRequest) []*MachineIdentity {
+		identities := make([]*MachineIdentity, 0)
+
+		for requests := 1; ; requests++ {
+			require.Less(t, requests, 20)
+
+			response, code := runTenantDiscovery(t, e, discoveryServices, request)
+			require.Equal(t, http.StatusOK, code)
+			require.LessOrEqual(t, len(response.Messages), request.Control.MaxResults)
+			require.Empty(t, response.Errors)
+
+			// each request uses a single session for every tenant
+			require.Equal(t, requests, sessions())
+
+			for _, dc := range response.Messages {
+				require.Len(t, dc.MachineIdentities, 1)
+				identities = append(identities, dc.MachineIdentities[0])
+			}
+
+			if response.Page == nil {
+				return identities
+			}
+
+			require.NotNil(t, response.Page.Tenant)
+			require.Equal(t, vmwareavi.WildcardTenantName, *response.Page.Tenant)
+			request.Page = response.Page
+		}
+	}
+
+	for _, maxResults := range []int{1, 2, 100} {
+		t.Run(fmt.Sprintf("all_tenants_%d_results", maxResults), func(t *testing.T) {
+			certificates := setupTenantCertificates(tenants, []int{2, 3, 2})
+			discoveryServices, sessions := setupWildcardController(t, certificates, virtualServices)
+
+			// a certificate without a virtual service is skipped, so each certificate has one machine identity
+			identities := runWildcardDiscovery(t, discoveryServices, sessions, newWildcardRequest(DiscoverCertificatesConfiguration{
+				ExcludeInactiveCertificates: true,
+			}, maxResults))
+
+			require.Len(t, identities, 2)
+
+			idx := slices.IndexFunc(identities, func(mi *MachineIdentity) bool { return mi.Binding.VirtualServiceName == "blue-vs" })
+			require.GreaterOrEqual(t, idx, 0)
+			require.Equal(t, "Blue-01", identities[idx].Keystore.CertificateName)
+			require.Equal(t, "Blue", identities[idx].Keystore.Tenant)
+			require.Empty(t, identities[idx].Binding.Tenant)
+
+			// a certificate of the admin tenant used by a virtual service of another tenant
+			idx = slices.IndexFunc(identities, func(mi *MachineIdentity) bool { return mi.Binding.VirtualServiceName == "green-vs" })
+			require.GreaterOrEqual(t, idx, 0)
+			require.Equal(t, "admin-00", identities[idx].Keystore.CertificateName)
+			require.Equal(t, "admin", identities[idx].Keystore.Tenant)
+			require.Equal(t, "green", identities[idx].Binding.Tenant)
+		})
+	}
+
+	t.Run("grouped_by_tenant", func(t *testing.T) {
+		certificates := setupTenantCertificates(tenants, []int{2, 3, 2})
+		discoveryServices, _ := setupWildcardController(t, certificates, virtualServices)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, newWildcardRequest(DiscoverCertificatesConfiguration{}, 100))
+		require.Equal(t, http.StatusOK, code)
+		require.Nil(t, response.Page)
+		require.Len(t, response.Messages, 7)
+
+		// the certificates of a tenant are returned together, in the order the tenants were first discovered
+		discovered := make([]string, 0)
+		for _, dc := range response.Messages {
+			discovered = append(discovered, dc.Certificate)
+		}
+
+		require.Equal(t, []string{
+			"-----BEGIN CERTIFICATE-----\nBlue-00\n-----END CERTIFICATE-----\n",
+			"-----BEGIN CERTIFICATE-----\nBlue-01\n-----END CERTIFICATE-----\n",
+			"-----BEGIN CERTIFICATE-----\nBlue-02\n-----END CERTIFICATE-----\n",
+			"-----BEGIN CERTIFICATE-----\nadmin-00\n-----END CERTIFICATE-----\n",
+			"-----BEGIN CERTIFICATE-----\nadmin-01\n-----END CERTIFICATE-----\n",
+			"-----BEGIN CERTIFICATE-----\ngreen-00\n-----END CERTIFICATE-----\n",
+			"-----BEGIN CERTIFICATE-----\ngreen-01\n-----END CERTIFICATE-----\n",
+		}, discovered)
+	})
+
+	t.Run("configured_tenants", func(t *testing.T) {
+		certificates := setupTenantCertificates(tenants, []int{2, 3, 2})
+		discoveryServices, _ := setupWildcardController(t, certificates, virtualServices)
+
+		response, code := runTenantDiscovery(t, e, discoveryServices, newWildcardRequest(DiscoverCertificatesConfiguration{
+			ExcludeTenants: "gr*",
+			Tenants:        "blue,green",
+		}, 100))
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Messages, 3)
+
+		for _, dc := range response.Messages {
+			require.Contains(t, dc.Certificate, "Blue-")
+		}
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		discoveryServices := NewDiscoveryService(mocks.NewMockClientServices(ctrl))
+
+		_, code := runTenantDiscovery(t, e, discoveryServices, newWildcardRequest(DiscoverCertificatesConfiguration{
+			Incremental: true,
+		}, 100))
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+}
@@ -1,7 +1,62 @@
 // Package domain contains shared definitions.
 package domain
 
+const (
+	// UsageTypeVirtualService is the usage of a certificate by a virtual service, the only usage that can be configured
+	UsageTypeVirtualService = "virtualService"
+	// UsageTypePool is the usage of a certificate as the client certificate of a pool to its servers
+	UsageTypePool = "pool"
+	// UsageTypePortal is the usage of a certificate by the controller portal
+	UsageTypePortal = "portal"
+	// UsageTypeSecureChannel is the usage of a certificate by the secure channel between the controller and the service engines
+	UsageTypeSecureChannel = "secureChannel"
+	// UsageTypeGslb is the usage of a CA certificate by a GSLB service, through the PKI profile of the GSLB service
+	UsageTypeGslb = "gslb"
+	// UsageTypePkiProfile is the usage of a CA certificate by a PKI profile
+	UsageTypePkiProfile = "pkiProfile"
+)
+
 // Binding represents the properties defined in the binding definition in the manifest.json file
 type Binding struct {
 	VirtualServiceName string `json:"virtualServiceName"`
+	// UsageType is the kind of object using the certificate, no value is interpreted as UsageTypeVirtualService
+	UsageType string `json:"usageType,omitempty"`
+	// ObjectName is the name of the object using the certificate when the usage is not by a virtual service
+	ObjectName string `json:"objectName,omitempty"`
+	// Tenant is the tenant of the virtual service when it differs from the tenant of the keystore certificate
+	Tenant string `json:"tenant,omitempty"`
+	// VirtualServiceNames is an optional collection of virtual service names to bind in bulk, in place of VirtualServiceName
+	VirtualServiceNames []string `json:"virtualServiceNames,omitempty"`
+	// VirtualServiceNamePattern is an optional glob pattern matched against the names of the virtual services in the tenant
+	// to bind in bulk, in place of VirtualServiceName
+	VirtualServiceNamePattern string `json:"virtualServiceNamePattern,omitempty"`
+	// FallbackCertificateName is an optional certificate to bind in place of the keystore certificate when an installation is removed
+	FallbackCertificateName string `json:"fallbackCertificateName,omitempty"`
+	// SslProfileName is an optional SSL profile to assign to the virtual service
+	SslProfileName string `json:"sslProfileName,omitempty"`
+	// ServicePort is an optional virtual service port that will be SSL enabled
+	ServicePort int `json:"servicePort,omitempty"`
+	// VerifyOperStatus will poll the virtual service runtime after binding and revert the binding if the virtual service is not up,
+	// the virtual service must also present the bound certificate in a TLS handshake
+	VerifyOperStatus bool `json:"verifyOperStatus,omitempty"`
+	// VerifyHandshake will perform a TLS handshake with the virtual service after binding and revert the binding if the
+	// virtual service does not present the bound certificate
+	VerifyHandshake bool `json:"verifyHandshake,omitempty"`
+	// HealthCheckTimeout is the number of seconds allowed for the post binding verification to succeed
+	HealthCheckTimeout int `json:"healthCheckTimeout,omitempty"`
+	// HostnameMismatches is reported by a discovery with the virtual service hostnames not covered by the certificate names
+	HostnameMismatches []string `json:"hostnameMismatches,omitempty"`
+	// VirtualServiceType is reported by a discovery with the type of the virtual service, VS_TYPE_VH_PARENT for the
+	// parent and VS_TYPE_VH_CHILD for a child of an SNI virtual service
+	VirtualServiceType string `json:"virtualServiceType,omitempty"`
+	// ParentVirtualServiceName is reported by a discovery with the parent of an SNI child virtual service, the
+	// certificate of the child is only presented for its virtual host domain names
+	ParentVirtualServiceName string `json:"parentVirtualServiceName,omitempty"`
+	// VirtualHostDomainNames is reported by a discovery with the virtual host domain names of an SNI child virtual service
+	VirtualHostDomainNames []string `json:"virtualHostDomainNames,omitempty"`
+}
+
+// IsVirtualService will return true when the binding is the usage of the certificate by a virtual service
+func (binding *Binding) IsVirtualService() bool {
+	return len(binding.UsageType) == 0 || binding.UsageType == UsageTypeVirtualService
 }
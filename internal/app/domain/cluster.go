@@ -0,0 +1,10 @@
+package domain
+
+import "github.com/vmware/alb-sdk/go/models"
+
+// Cluster represents the subset of the VMware AVI controller cluster configuration used by the connector
+type Cluster struct {
+	Name  *string               `json:"name,omitempty"`
+	Nodes []*models.ClusterNode `json:"nodes,omitempty"`
+	UUID  *string               `json:"uuid,omitempty"`
+}
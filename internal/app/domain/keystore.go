@@ -1,7 +1,19 @@
 package domain
 
+const (
+	// HostnameValidationNone skips comparing the certificate names with the virtual service hostnames
+	HostnameValidationNone = "none"
+	// HostnameValidationWarn logs a warning when the certificate names do not cover the virtual service hostnames
+	HostnameValidationWarn = "warn"
+	// HostnameValidationEnforce fails the binding when the certificate names do not cover the virtual service hostnames
+	HostnameValidationEnforce = "enforce"
+)
+
 // Keystore represents the properties defined in the keystore definition in the manifest.json file
 type Keystore struct {
 	CertificateName string `json:"certificateName"`
 	Tenant          string `json:"tenant"`
+	// HostnameValidation is the policy applied when the certificate names do not cover the virtual service hostnames,
+	// no value is interpreted as HostnameValidationWarn
+	HostnameValidation string `json:"hostnameValidation,omitempty"`
 }
@@ -0,0 +1,13 @@
+package domain
+
+import "github.com/vmware/alb-sdk/go/models"
+
+const (
+	// OperStateUp is the operational state of a virtual service that is serving traffic
+	OperStateUp = "OPER_UP"
+)
+
+// VirtualServiceRuntime represents the subset of the VMware AVI virtual service runtime details used by the connector
+type VirtualServiceRuntime struct {
+	OperStatus *models.OperationalStatus `json:"oper_status,omitempty"`
+}
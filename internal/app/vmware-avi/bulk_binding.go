@@ -0,0 +1,207 @@
+package vmwareavi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+)
+
+// ConfigureInstallationEndpointResponse contains the response for a ConfigureInstallationEndpointRequest binding more than one virtual service
+type ConfigureInstallationEndpointResponse struct {
+	Results []*VirtualServiceBindingResult `json:"results,omitempty"`
+	// Warnings are the issues of a binding of a single virtual service that did not fail the binding
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// VirtualServiceBindingResult contains the outcome of binding a certificate to a single virtual service
+type VirtualServiceBindingResult struct {
+	VirtualServiceName string   `json:"virtualServiceName"`
+	Success            bool     `json:"success"`
+	Error              string   `json:"error,omitempty"`
+	Warnings           []string `json:"warnings,omitempty"`
+}
+
+func isBulkBinding(binding *domain.Binding) bool {
+	return len(binding.VirtualServiceNames) > 0 || len(binding.VirtualServiceNamePattern) > 0
+}
+
+// validateBindingTargets will verify that the binding targets either a single virtual service by name or the virtual
+// services of a bulk binding
+func validateBindingTargets(binding *domain.Binding) error {
+	single := len(strings.TrimSpace(binding.VirtualServiceName)) > 0
+
+	if single && isBulkBinding(binding) {
+		return errors.New("a virtual service name cannot be combined with the virtual service names or pattern of a bulk binding")
+	}
+
+	if !single && !isBulkBinding(binding) {
+		return errors.New("a virtual service name, or the virtual service names or pattern of a bulk binding, is required")
+	}
+
+	return nil
+}
+
+// resolveVirtualServiceNames will return the unique, sorted collection of virtual service names targeted by the binding
+func (svc *WebhookServiceImpl) resolveVirtualServiceNames(client *domain.Client, binding *domain.Binding) ([]string, error) {
+	unique := map[string]bool{}
+
+	add := func(name string) {
+		name = strings.TrimSpace(name)
+		if len(name) > 0 {
+			unique[name] = true
+		}
+	}
+
+	add(binding.VirtualServiceName)
+	for _, name := range binding.VirtualServiceNames {
+		add(name)
+	}
+
+	if len(binding.VirtualServiceNamePattern) > 0 {
+		if _, err := path.Match(binding.VirtualServiceNamePattern, ""); err != nil {
+			return nil, fmt.Errorf(`invalid virtual service name pattern "%s": %w`, binding.VirtualServiceNamePattern, err)
+		}
+
+		tenant := binding.Tenant
+		if len(tenant) == 0 {
+			tenant = client.Tenant
+		}
+
+		virtualServices, err := getAllVirtualServices(svc.ClientServices, client, nil, getTenantOptions(client, tenant)...)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to read virtual services for the tenant "%s": %w`, tenant, err)
+		}
+
+		for _, vs := range virtualServices {
+			if vs == nil || vs.Name == nil {
+				continue
+			}
+
+			if matched, _ := path.Match(binding.VirtualServiceNamePattern, *vs.Name); matched {
+				add(*vs.Name)
+			}
+		}
+	}
+
+	if len(unique) == 0 {
+		return nil, errors.New("no virtual services matched the binding")
+	}
+
+	names := make([]string, 0, len(unique))
+	for name := range unique {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// configureInstallationEndpoints will bind the keystore certificate to each of the named virtual services using bounded parallelism.
+// A failure to bind one virtual service does not prevent binding the remaining virtual services. A client session is
+// not safe for concurrent use, so each worker binds its virtual services with a session of its own.
+func (svc *WebhookServiceImpl) configureInstallationEndpoints(ctx context.Context, client *domain.Client, names []string, binding *domain.Binding, keystore *domain.Keystore) *ConfigureInstallationEndpointResponse {
+	res := &ConfigureInstallationEndpointResponse{
+		Results: make([]*VirtualServiceBindingResult, len(names)),
+	}
+
+	workers := min(DefaultBindingConcurrency, len(names))
+
+	// the clients are created before the workers start, since creating a client can default the connection port
+	workerClients := make([]*domain.Client, workers)
+	for idx := range workerClients {
+		workerClients[idx] = svc.ClientServices.NewClient(client.Connection, client.Tenant)
+	}
+
+	var wg sync.WaitGroup
+	work := make(chan int)
+
+	for _, workerClient := range workerClients {
+		wg.Add(1)
+
+		go func(workerClient *domain.Client) {
+			defer wg.Done()
+
+			err := svc.ClientServices.Connect(workerClient)
+			defer func() {
+				svc.ClientServices.Close(workerClient)
+			}()
+
+			for idx := range work {
+				if err != nil {
+					zap.L().Error("failed to connect to bind certificate to virtual service", zap.String("tenant", client.Tenant), zap.String("virtualService", names[idx]), zap.Error(err))
+
+					res.Results[idx] = &VirtualServiceBindingResult{
+						VirtualServiceName: names[idx],
+						Success:            false,
+						Error:              err.Error(),
+					}
+					continue
+				}
+
+				res.Results[idx] = svc.configureBulkInstallationEndpoint(ctx, workerClient, names[idx], binding, keystore)
+			}
+		}(workerClient)
+	}
+
+	for idx := range names {
+		work <- idx
+	}
+	close(work)
+
+	wg.Wait()
+
+	return res
+}
+
+// configureBulkInstallationEndpoint will bind the keystore certificate to a single virtual service of a bulk binding
+func (svc *WebhookServiceImpl) configureBulkInstallationEndpoint(ctx context.Context, client *domain.Client, name string, binding *domain.Binding, keystore *domain.Keystore) *VirtualServiceBindingResult {
+	result := &VirtualServiceBindingResult{
+		VirtualServiceName: name,
+		Success:            true,
+	}
+
+	single := *binding
+	single.VirtualServiceName = name
+	single.VirtualServiceNames = nil
+	single.VirtualServiceNamePattern = ""
+
+	var err error
+	result.Warnings, err = svc.configureInstallationEndpoint(ctx, client, &single, keystore)
+	if err != nil {
+		zap.L().Error("failed to bind certificate to virtual service", zap.String("tenant", client.Tenant), zap.String("virtualService", name), zap.String("certificateName", keystore.CertificateName), zap.Error(err))
+
+		result.Success = false
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// getStatusCode will return the status of the response to a bulk binding. A binding of some of the virtual services
+// is a partial success and returns multi-status, so it is not mistaken for a bad request that bound none of them.
+func (res *ConfigureInstallationEndpointResponse) getStatusCode() int {
+	failed := 0
+	for _, result := range res.Results {
+		if !result.Success {
+			failed++
+		}
+	}
+
+	switch failed {
+	case 0:
+		return http.StatusOK
+	case len(res.Results):
+		return http.StatusBadRequest
+	default:
+		return http.StatusMultiStatus
+	}
+}
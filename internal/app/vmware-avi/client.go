@@ -24,18 +24,49 @@ type ClientServices interface {
 	Connect(client *domain.Client) error
 	// CreateSSLKeyAndCertificate will create a new SSLKeyAndCertificate object
 	CreateSSLKeyAndCertificate(client *domain.Client, obj *models.SSLKeyAndCertificate, options ...session.ApiOptionsParams) (*models.SSLKeyAndCertificate, error)
+	// DeleteSSLKeyAndCertificate will delete an existing SSLKeyAndCertificate object by UUID
+	DeleteSSLKeyAndCertificate(client *domain.Client, uuid string, options ...session.ApiOptionsParams) error
+	// GetAllClouds will return a collection of Cloud objects
+	GetAllClouds(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Cloud, error)
+	// GetAllGslbServices will return a collection of GslbService objects
+	GetAllGslbServices(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.GslbService, error)
+	// GetAllPKIProfiles will return a collection of PKIprofile objects
+	GetAllPKIProfiles(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.PKIprofile, error)
+	// GetAllPools will return a collection of Pool objects
+	GetAllPools(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Pool, error)
+	// GetAllServiceEngineGroups will return a collection of ServiceEngineGroup objects
+	GetAllServiceEngineGroups(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.ServiceEngineGroup, error)
 	// GetAllSSLKeysAndCertificates will return a collection of SSLKeyAndCertificate objects
 	GetAllSSLKeysAndCertificates(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error)
 	// GetAllTenants will return a collection of Tenant objects
 	GetAllTenants(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Tenant, error)
+	// GetAllVirtualServiceInventories will return a collection of VsInventory objects
+	GetAllVirtualServiceInventories(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VsInventory, error)
 	// GetAllVirtualServices will return a collection of VirtualService objects
 	GetAllVirtualServices(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error)
+	// GetCluster will return the controller cluster configuration
+	GetCluster(client *domain.Client, options ...session.ApiOptionsParams) (*domain.Cluster, error)
+	// GetControllerVersion will return the controller version
+	GetControllerVersion(client *domain.Client) (string, error)
+	// GetObjectCount will return the number of objects of the object type, such as virtualservice, matching the query
+	// parameters. The query parameters are not set by the options, since a later SetParams option replaces them.
+	GetObjectCount(client *domain.Client, objectType string, params map[string]string, options ...session.ApiOptionsParams) (int, error)
+	// GetSSLProfile will return an existing SSLProfile by UUID
+	GetSSLProfile(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.SSLProfile, error)
+	// GetSSLProfileByName will return an existing SSLProfile by name
+	GetSSLProfileByName(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.SSLProfile, error)
 	// GetSSLKeyAndCertificateById will return an existing SSLKeyAndCertificate by name
 	GetSSLKeyAndCertificateByID(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.SSLKeyAndCertificate, error)
 	// GetSSLKeyAndCertificateByName will return an existing SSLKeyAndCertificate by name
 	GetSSLKeyAndCertificateByName(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.SSLKeyAndCertificate, error)
+	// GetSystemConfiguration will return the controller system configuration
+	GetSystemConfiguration(client *domain.Client, options ...session.ApiOptionsParams) (*models.SystemConfiguration, error)
 	// GetVirtualServiceByName will return an existing VirtualService by name
 	GetVirtualServiceByName(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error)
+	// GetVirtualServiceRuntime will return the runtime details of an existing VirtualService by UUID
+	GetVirtualServiceRuntime(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*domain.VirtualServiceRuntime, error)
+	// GetVsVipByID will return an existing VsVip by UUID
+	GetVsVipByID(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.VsVip, error)
 	// NewClient will create a new client instance
 	NewClient(connection *domain.Connection, tenant string) *domain.Client
 	// UpdateVirtualService will update an existing VirtualService object
@@ -176,6 +207,66 @@ func (c *VMwareAviClientsImpl) CreateSSLKeyAndCertificate(client *domain.Client,
 	return unwrapped.SSLKeyAndCertificate.Create(obj, options...)
 }
 
+// DeleteSSLKeyAndCertificate will delete an existing SSLKeyAndCertificate object by UUID
+func (c *VMwareAviClientsImpl) DeleteSSLKeyAndCertificate(client *domain.Client, uuid string, options ...session.ApiOptionsParams) error {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return errors.New("invalid session")
+	}
+
+	return unwrapped.SSLKeyAndCertificate.Delete(uuid, options...)
+}
+
+// GetAllClouds will return a collection of Cloud objects
+func (c *VMwareAviClientsImpl) GetAllClouds(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Cloud, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.Cloud.GetAll(options...)
+}
+
+// GetAllGslbServices will return a collection of GslbService objects
+func (c *VMwareAviClientsImpl) GetAllGslbServices(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.GslbService, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.GslbService.GetAll(options...)
+}
+
+// GetAllPKIProfiles will return a collection of PKIprofile objects
+func (c *VMwareAviClientsImpl) GetAllPKIProfiles(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.PKIprofile, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.PKIprofile.GetAll(options...)
+}
+
+// GetAllPools will return a collection of Pool objects
+func (c *VMwareAviClientsImpl) GetAllPools(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Pool, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.Pool.GetAll(options...)
+}
+
+// GetAllServiceEngineGroups will return a collection of ServiceEngineGroup objects
+func (c *VMwareAviClientsImpl) GetAllServiceEngineGroups(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.ServiceEngineGroup, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.ServiceEngineGroup.GetAll(options...)
+}
+
 // GetAllSSLKeysAndCertificates will return a collection of SSLKeyAndCertificate objects
 func (c *VMwareAviClientsImpl) GetAllSSLKeysAndCertificates(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
 	unwrapped, ok := client.Session.(*clients.AviClient)
@@ -196,6 +287,19 @@ func (c *VMwareAviClientsImpl) GetAllTenants(client *domain.Client, options ...s
 	return unwrapped.Tenant.GetAll(options...)
 }
 
+// GetAllVirtualServiceInventories will return a collection of VsInventory objects, the configuration and runtime
+// summary of each VirtualService
+func (c *VMwareAviClientsImpl) GetAllVirtualServiceInventories(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VsInventory, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	var inventories []*models.VsInventory
+	err := unwrapped.AviSession.GetCollection("api/virtualservice-inventory", &inventories, options...)
+	return inventories, err
+}
+
 // GetAllVirtualServices will return a collection of VirtualService objects
 func (c *VMwareAviClientsImpl) GetAllVirtualServices(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
 	unwrapped, ok := client.Session.(*clients.AviClient)
@@ -226,6 +330,84 @@ func (c *VMwareAviClientsImpl) GetSSLKeyAndCertificateByName(client *domain.Clie
 	return unwrapped.SSLKeyAndCertificate.GetByName(name, options...)
 }
 
+// GetCluster will return the controller cluster configuration
+func (c *VMwareAviClientsImpl) GetCluster(client *domain.Client, options ...session.ApiOptionsParams) (*domain.Cluster, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	var cluster *domain.Cluster
+	err := unwrapped.AviSession.Get("api/cluster", &cluster, options...)
+	return cluster, err
+}
+
+// GetControllerVersion will return the controller version
+func (c *VMwareAviClientsImpl) GetControllerVersion(client *domain.Client) (string, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return "", errors.New("invalid session")
+	}
+
+	return unwrapped.AviSession.GetControllerVersion()
+}
+
+// GetObjectCount will return the number of objects of the object type, such as virtualservice, matching the query
+// parameters
+func (c *VMwareAviClientsImpl) GetObjectCount(client *domain.Client, objectType string, params map[string]string, options ...session.ApiOptionsParams) (int, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return 0, errors.New("invalid session")
+	}
+
+	// only the count is required, so limit the results to a single object. The SDK replaces the query parameters of an
+	// earlier SetParams option, so the page size is merged with the query parameters into a single option.
+	query := map[string]string{}
+	for key, value := range params {
+		query[key] = value
+	}
+	query["page_size"] = "1"
+
+	options = append([]session.ApiOptionsParams{session.SetParams(query)}, options...)
+
+	result, err := unwrapped.AviSession.GetCollectionRaw("api/"+objectType, options...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Count, nil
+}
+
+// GetSSLProfile will return an existing SSLProfile by UUID
+func (c *VMwareAviClientsImpl) GetSSLProfile(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.SSLProfile, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.SSLProfile.Get(uuid, options...)
+}
+
+// GetSSLProfileByName will return an existing SSLProfile by name
+func (c *VMwareAviClientsImpl) GetSSLProfileByName(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.SSLProfile, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.SSLProfile.GetByName(name, options...)
+}
+
+// GetSystemConfiguration will return the controller system configuration
+func (c *VMwareAviClientsImpl) GetSystemConfiguration(client *domain.Client, options ...session.ApiOptionsParams) (*models.SystemConfiguration, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.SystemConfiguration.Get("", options...)
+}
+
 // GetVirtualServiceByName will return an existing VirtualService by name
 func (c *VMwareAviClientsImpl) GetVirtualServiceByName(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
 	unwrapped, ok := client.Session.(*clients.AviClient)
@@ -236,6 +418,28 @@ func (c *VMwareAviClientsImpl) GetVirtualServiceByName(client *domain.Client, na
 	return unwrapped.VirtualService.GetByName(name, options...)
 }
 
+// GetVirtualServiceRuntime will return the runtime details of an existing VirtualService by UUID
+func (c *VMwareAviClientsImpl) GetVirtualServiceRuntime(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*domain.VirtualServiceRuntime, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	var runtime *domain.VirtualServiceRuntime
+	err := unwrapped.AviSession.Get(fmt.Sprintf("api/virtualservice/%s/runtime", uuid), &runtime, options...)
+	return runtime, err
+}
+
+// GetVsVipByID will return an existing VsVip by UUID
+func (c *VMwareAviClientsImpl) GetVsVipByID(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.VsVip, error) {
+	unwrapped, ok := client.Session.(*clients.AviClient)
+	if !ok {
+		return nil, errors.New("invalid session")
+	}
+
+	return unwrapped.VsVip.Get(uuid, options...)
+}
+
 // NewClient will create a new client instance
 func (c *VMwareAviClientsImpl) NewClient(connection *domain.Connection, tenant string) *domain.Client {
 	if connection.Port == 0 {
@@ -0,0 +1,54 @@
+package vmwareavi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/vmware/alb-sdk/go/clients"
+	"github.com/vmware/alb-sdk/go/session"
+)
+
+func TestGetObjectCount(t *testing.T) {
+	var query url.Values
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/api/virtualservice" {
+			query = r.URL.Query()
+			_, _ = w.Write([]byte(`{"count": 3, "results": [{"name": "vstest"}]}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	aviClient, err := clients.NewAviClient(strings.TrimPrefix(server.URL, "https://"), "user",
+		session.SetPassword("password"),
+		session.SetInsecure)
+	require.NoError(t, err)
+
+	client := &domain.Client{
+		Connection: &domain.Connection{},
+		Session:    aviClient,
+		Tenant:     DefaultTenantName,
+	}
+
+	// the query parameters of the count are merged with the page size into the query
+	count, err := NewVMwareAviClients().GetObjectCount(client, "virtualservice", map[string]string{
+		"refers_to": "sslkeyandcertificate:sslkeyandcertificate-test",
+	}, session.SetOptTenant(WildcardTenantName))
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	require.Equal(t, url.Values{
+		"page_size": []string{"1"},
+		"refers_to": []string{"sslkeyandcertificate:sslkeyandcertificate-test"},
+	}, query)
+}
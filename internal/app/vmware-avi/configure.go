@@ -1,6 +1,7 @@
 package vmwareavi
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -19,16 +20,6 @@ type ConfigureInstallationEndpointRequest struct {
 	Binding    domain.Binding     `json:"binding"`
 }
 
-// GetTargetConfigurationRequest contains the request details for retrieving VMware AVI host configuration information
-type GetTargetConfigurationRequest struct {
-	Connection *domain.Connection `json:"connection"`
-}
-
-// GetTargetConfigurationResponse contains the response for a GetTargetConfigurationRequest
-type GetTargetConfigurationResponse struct {
-	TargetConfiguration TargetConfiguration `json:"targetConfiguration"`
-}
-
 // HandleConfigureInstallationEndpoint will attempt to configure usage of a keystore
 func (svc *WebhookServiceImpl) HandleConfigureInstallationEndpoint(c echo.Context) error {
 	req := ConfigureInstallationEndpointRequest{}
@@ -37,6 +28,16 @@ func (svc *WebhookServiceImpl) HandleConfigureInstallationEndpoint(c echo.Contex
 		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall json: %s", err.Error()))
 	}
 
+	if !req.Binding.IsVirtualService() {
+		zap.L().Info("invalid request, only the usage of a certificate by a virtual service can be configured", zap.String("usageType", req.Binding.UsageType))
+		return c.String(http.StatusBadRequest, fmt.Sprintf(`the "%s" usage of a certificate cannot be configured`, req.Binding.UsageType))
+	}
+
+	if err := validateBindingTargets(&req.Binding); err != nil {
+		zap.L().Info("invalid request, the binding has no single target", zap.Error(err))
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
 	var err error
 
 	client := svc.ClientServices.NewClient(req.Connection, req.Keystore.Tenant)
@@ -50,35 +51,60 @@ func (svc *WebhookServiceImpl) HandleConfigureInstallationEndpoint(c echo.Contex
 
 	zap.L().Info("configuring installation endpoint on VMware NSX-ALB", zap.String("address", req.Connection.HostnameOrAddress), zap.Int("port", req.Connection.Port))
 
-	err = svc.configureInstallationEndpoint(client, &req.Binding, &req.Keystore)
+	if isBulkBinding(&req.Binding) {
+		var names []string
+		names, err = svc.resolveVirtualServiceNames(client, &req.Binding)
+		if err != nil {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("failed to configure VMware NSX-ALB: %s", err.Error()))
+		}
+
+		res := svc.configureInstallationEndpoints(c.Request().Context(), client, names, &req.Binding, &req.Keystore)
+		return c.JSON(res.getStatusCode(), res)
+	}
+
+	var warnings []string
+	warnings, err = svc.configureInstallationEndpoint(c.Request().Context(), client, &req.Binding, &req.Keystore)
 	if err != nil {
 		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to configure VMware NSX-ALB: %s", err.Error()))
 	}
 
+	if len(warnings) > 0 {
+		return c.JSON(http.StatusOK, &ConfigureInstallationEndpointResponse{Warnings: warnings})
+	}
+
 	return c.NoContent(http.StatusOK)
 }
 
-// HandleGetTargetConfiguration will attempt to retrieve the configuration information of the VMware AVI host
-func (svc *WebhookServiceImpl) HandleGetTargetConfiguration(c echo.Context) error {
-	req := GetTargetConfigurationRequest{}
-	if err := c.Bind(&req); err != nil {
-		zap.L().Error("invalid request, failed to unmarshall json", zap.Error(err))
-		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall json: %s", err.Error()))
+// configureInstallationEndpoint will bind the keystore certificate to the virtual service, returning the warnings of a
+// successful binding
+func (svc *WebhookServiceImpl) configureInstallationEndpoint(ctx context.Context, client *domain.Client, binding *domain.Binding, keystore *domain.Keystore) ([]string, error) {
+	unlock, err := svc.lockVirtualService(client, binding.Tenant, binding.VirtualServiceName)
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
-	res := GetTargetConfigurationResponse{}
+	err = svc.bindVirtualService(ctx, client, binding, keystore)
+	if err != nil {
+		return nil, err
+	}
 
-	// Future ...
+	if svc.snapshots == nil {
+		return []string{fmt.Sprintf(`no snapshot of the previous certificates of virtual service "%s" was taken, %s is not set`, binding.VirtualServiceName, SnapshotDirectoryEnvironmentVariable)}, nil
+	}
 
-	return c.JSON(http.StatusOK, res)
+	return nil, nil
 }
 
-func (svc *WebhookServiceImpl) configureInstallationEndpoint(client *domain.Client, binding *domain.Binding, keystore *domain.Keystore) error {
+// bindVirtualService will associate the keystore certificate with the virtual service, the caller must hold the virtual service lock
+func (svc *WebhookServiceImpl) bindVirtualService(ctx context.Context, client *domain.Client, binding *domain.Binding, keystore *domain.Keystore) error {
 	var err error
 
+	options := getTenantOptions(client, binding.Tenant)
+
 	// Get the virtual service UUID
 	var vs *models.VirtualService
-	vs, err = svc.ClientServices.GetVirtualServiceByName(client, binding.VirtualServiceName)
+	vs, err = svc.ClientServices.GetVirtualServiceByName(client, binding.VirtualServiceName, options...)
 	if err != nil {
 		return fmt.Errorf(`failed to retrieve virtual service "%s": %w`, binding.VirtualServiceName, err)
 	}
@@ -87,6 +113,14 @@ func (svc *WebhookServiceImpl) configureInstallationEndpoint(client *domain.Clie
 		return fmt.Errorf(`failed to retrieve virtual service "%s": empty response`, binding.VirtualServiceName)
 	}
 
+	// the SSL configuration is captured before it is changed, so a failed health check restores all of it
+	state := captureVirtualServiceSSL(vs)
+
+	err = svc.configureVirtualServiceSSL(client, binding, vs, options...)
+	if err != nil {
+		return fmt.Errorf(`invalid virtual service "%s": %w`, binding.VirtualServiceName, err)
+	}
+
 	// Get the certificate UUID
 	var kac *models.SSLKeyAndCertificate
 	kac, err = svc.ClientServices.GetSSLKeyAndCertificateByName(client, keystore.CertificateName, session.SetParams(map[string]string{
@@ -104,13 +138,51 @@ func (svc *WebhookServiceImpl) configureInstallationEndpoint(client *domain.Clie
 		return fmt.Errorf(`invalid certificate "%s": no assigned UUID`, keystore.CertificateName)
 	}
 
+	if len(options) > 0 {
+		// the certificate is owned by the keystore tenant and must be shared with the virtual service tenant
+		_, err = svc.ClientServices.GetSSLKeyAndCertificateByID(client, getUUIDFromRef(*kac.URL), append(options, session.SetParams(map[string]string{
+			"export_key": "false",
+		}))...)
+		if err != nil {
+			return fmt.Errorf(`certificate "%s" of tenant "%s" is not visible to the tenant "%s": %w`, keystore.CertificateName, client.Tenant, binding.Tenant, err)
+		}
+	}
+
+	err = svc.validateHostnames(client, keystore, vs, kac, options...)
+	if err != nil {
+		return fmt.Errorf(`invalid binding for virtual service "%s": %w`, binding.VirtualServiceName, err)
+	}
+
+	previous := vs.SslKeyAndCertificateRefs
+
+	err = svc.snapshotVirtualService(client, binding.Tenant, binding.VirtualServiceName, vs.UUID, previous)
+	if err != nil {
+		return err
+	}
+
 	// Associate the certificate with the virtual service
 	vs.SslKeyAndCertificateRefs = []string{*kac.URL}
 
-	_, err = svc.ClientServices.UpdateVirtualService(client, vs)
+	_, err = svc.ClientServices.UpdateVirtualService(client, vs, options...)
 	if err != nil {
 		return fmt.Errorf(`failed to update the virtual service "%s": %w`, binding.VirtualServiceName, err)
 	}
 
-	return nil
+	if !requiresHealthCheck(binding) {
+		return nil
+	}
+
+	err = svc.verifyVirtualService(ctx, client, binding, vs, kac)
+	if err == nil {
+		return nil
+	}
+
+	zap.L().Error("virtual service failed health check, restoring previous certificates", zap.String("tenant", client.Tenant), zap.String("virtualService", binding.VirtualServiceName), zap.Error(err))
+
+	revertErr := svc.revertVirtualService(client, binding.VirtualServiceName, state, options...)
+	if revertErr != nil {
+		return fmt.Errorf(`virtual service "%s" failed health check: %s, and the previous certificates could not be restored: %w`, binding.VirtualServiceName, err.Error(), revertErr)
+	}
+
+	return fmt.Errorf(`virtual service "%s" failed health check and the previous certificates were restored: %w`, binding.VirtualServiceName, err)
 }
@@ -3,8 +3,12 @@ package vmwareavi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -79,6 +83,7 @@ func TestConfigure(t *testing.T) {
 				vs := &models.VirtualService{
 					Name:                     &vsn,
 					SslKeyAndCertificateRefs: []string{"sslkeyandcertificate:old"},
+					Services:                 sslServices(),
 					UUID:                     &vsUUID,
 				}
 				return vs, nil
@@ -116,8 +121,1055 @@ func TestConfigure(t *testing.T) {
 		require.NotNil(t, response)
 		require.Equal(t, response.StatusCode, http.StatusOK)
 
-		body := recorder.Body.String()
-		require.NotNil(t, body)
-		require.True(t, len(body) == 0)
+		// the binding succeeds without a snapshot of the previous certificates, and warns about it
+		require.JSONEq(t, `{"warnings":["no snapshot of the previous certificates of virtual service \"vstest\" was taken, VMWARE_AVI_SNAPSHOT_DIRECTORY is not set"]}`, recorder.Body.String())
 	})
+	t.Run("success_bulk", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "wildcard.test.io",
+				Tenant:          "test",
+			},
+			Binding: domain.Binding{
+				VirtualServiceNames:       []string{"vs-a", "vs-b"},
+				VirtualServiceNamePattern: "web-*",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		// the request session resolves the virtual services, and each of the 4 workers binds with a session of its own
+		var sessions atomic.Int32
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			}).
+			Times(5)
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			DoAndReturn(func(client *domain.Client) error {
+				client.Session = sessions.Add(1)
+				return nil
+			}).
+			Times(5)
+		mockClientServices.EXPECT().
+			Close(gomock.Any()).
+			Times(5)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+				names := []string{"web-1", "web-2", "vs-a", "api-1"}
+				virtualServices := make([]*models.VirtualService, 0, len(names))
+				for idx := range names {
+					virtualServices = append(virtualServices, &models.VirtualService{Name: &names[idx]})
+				}
+				return virtualServices, nil
+			})
+
+		mockClientServices.EXPECT().
+			GetVirtualServiceByName(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				require.NotEqual(t, int32(1), client.Session)
+
+				vsn := name
+				vsUUID := "virtualservice:" + uuid.New().String()
+
+				vs := &models.VirtualService{
+					Name:                     &vsn,
+					SslKeyAndCertificateRefs: []string{"sslkeyandcertificate:old"},
+					Services:                 sslServices(),
+					UUID:                     &vsUUID,
+				}
+				return vs, nil
+			}).
+			Times(4)
+
+		kacn := "wildcard.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/" + kacn
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.SSLKeyAndCertificate, error) {
+				kac := &models.SSLKeyAndCertificate{
+					Name: &kacn,
+					URL:  &kacURL,
+				}
+				return kac, nil
+			}).
+			Times(4)
+
+		mockClientServices.EXPECT().
+			UpdateVirtualService(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				require.NotNil(t, obj)
+				require.NotEqual(t, int32(1), client.Session)
+				if *obj.Name == "vs-b" {
+					return nil, errors.New("virtual service is locked")
+				}
+
+				require.True(t, len(obj.SslKeyAndCertificateRefs) == 1)
+				require.Equal(t, kacURL, obj.SslKeyAndCertificateRefs[0])
+				return obj, nil
+			}).
+			Times(4)
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusMultiStatus, response.StatusCode)
+
+		res := &ConfigureInstallationEndpointResponse{}
+		err = json.Unmarshal(recorder.Body.Bytes(), res)
+		require.NoError(t, err)
+		require.Equal(t, 4, len(res.Results))
+
+		for idx, expected := range []string{"vs-a", "vs-b", "web-1", "web-2"} {
+			require.Equal(t, expected, res.Results[idx].VirtualServiceName)
+			require.Equal(t, expected != "vs-b", res.Results[idx].Success)
+			// each bound virtual service warns that no snapshot was taken
+			require.Equal(t, expected != "vs-b", len(res.Results[idx].Warnings) == 1)
+		}
+		require.Contains(t, res.Results[1].Error, "virtual service is locked")
+		require.Empty(t, res.Warnings)
+	})
+	t.Run("health_check_revert", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+		whService.healthCheckInterval = 10 * time.Millisecond
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+			Binding: domain.Binding{
+				VirtualServiceName: "vstest",
+				VerifyOperStatus:   true,
+				HealthCheckTimeout: 1,
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		oldURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-old"
+
+		mockClientServices.EXPECT().
+			GetVirtualServiceByName(gomock.Any(), gomock.Eq("vstest")).
+			DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				vsn := name
+				vsUUID := "virtualservice-test"
+
+				vs := &models.VirtualService{
+					Name:                     &vsn,
+					SslKeyAndCertificateRefs: []string{oldURL},
+					Services:                 sslServices(),
+					UUID:                     &vsUUID,
+				}
+				return vs, nil
+			}).
+			Times(2)
+
+		kacn := "installation.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-new"
+
+		content := certificatePem
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{
+				Certificate: &models.SSLCertificate{Certificate: &content},
+				Name:        &kacn,
+				URL:         &kacURL,
+			}, nil)
+
+		state := "OPER_DOWN"
+		mockClientServices.EXPECT().
+			GetVirtualServiceRuntime(gomock.Any(), gomock.Eq("virtualservice-test")).
+			Return(&domain.VirtualServiceRuntime{
+				OperStatus: &models.OperationalStatus{
+					State: &state,
+				},
+			}, nil).
+			MinTimes(1)
+
+		gomock.InOrder(
+			mockClientServices.EXPECT().
+				UpdateVirtualService(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+					require.Equal(t, []string{kacURL}, obj.SslKeyAndCertificateRefs)
+					return obj, nil
+				}),
+			mockClientServices.EXPECT().
+				UpdateVirtualService(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+					require.Equal(t, []string{oldURL}, obj.SslKeyAndCertificateRefs)
+					return obj, nil
+				}),
+		)
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, recorder.Body.String(), "previous certificates were restored")
+		require.Contains(t, recorder.Body.String(), "OPER_DOWN")
+	})
+	t.Run("health_check_revert_ssl_configuration", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+		whService.healthCheckInterval = 10 * time.Millisecond
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+			Binding: domain.Binding{
+				VirtualServiceName: "vstest",
+				SslProfileName:     "modern",
+				ServicePort:        8443,
+				VerifyOperStatus:   true,
+				HealthCheckTimeout: 1,
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		oldURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-old"
+		oldProfileURL := "https://localhost/api/sslprofile/sslprofile-legacy#legacy"
+		vsn := "vstest"
+		vsUUID := "virtualservice-test"
+		httpPort := uint32(80)
+		httpsPort := uint32(8443)
+		disabled := false
+
+		// the same virtual service is read again by the revert, with the changes of the first update
+		vs := &models.VirtualService{
+			Name:                     &vsn,
+			SslKeyAndCertificateRefs: []string{oldURL},
+			SslProfileRef:            &oldProfileURL,
+			Services: []*models.Service{
+				{Port: &httpPort},
+				{Port: &httpsPort, EnableSsl: &disabled},
+			},
+			UUID: &vsUUID,
+		}
+
+		mockClientServices.EXPECT().
+			GetVirtualServiceByName(gomock.Any(), gomock.Eq("vstest")).
+			Return(vs, nil).
+			Times(2)
+
+		profileName := "modern"
+		profileType := SslProfileTypeApplication
+		profileURL := "https://localhost/api/sslprofile/sslprofile-modern#modern"
+
+		mockClientServices.EXPECT().
+			GetSSLProfileByName(gomock.Any(), gomock.Eq(profileName)).
+			Return(&models.SSLProfile{
+				Name: &profileName,
+				Type: &profileType,
+				URL:  &profileURL,
+			}, nil)
+
+		kacn := "installation.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-new"
+
+		content := certificatePem
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{
+				Certificate: &models.SSLCertificate{Certificate: &content},
+				Name:        &kacn,
+				URL:         &kacURL,
+			}, nil)
+
+		state := "OPER_DOWN"
+		mockClientServices.EXPECT().
+			GetVirtualServiceRuntime(gomock.Any(), gomock.Eq("virtualservice-test")).
+			Return(&domain.VirtualServiceRuntime{
+				OperStatus: &models.OperationalStatus{
+					State: &state,
+				},
+			}, nil).
+			MinTimes(1)
+
+		gomock.InOrder(
+			mockClientServices.EXPECT().
+				UpdateVirtualService(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+					require.Equal(t, []string{kacURL}, obj.SslKeyAndCertificateRefs)
+					require.Equal(t, profileURL, *obj.SslProfileRef)
+					require.Nil(t, obj.Services[0].EnableSsl)
+					require.True(t, *obj.Services[1].EnableSsl)
+					return obj, nil
+				}),
+			mockClientServices.EXPECT().
+				UpdateVirtualService(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+					require.Equal(t, []string{oldURL}, obj.SslKeyAndCertificateRefs)
+					require.Equal(t, oldProfileURL, *obj.SslProfileRef)
+					require.Nil(t, obj.Services[0].EnableSsl)
+					require.False(t, *obj.Services[1].EnableSsl)
+					return obj, nil
+				}),
+		)
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, recorder.Body.String(), "previous certificates were restored")
+	})
+
+	t.Run("success_cross_tenant", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "wildcard.test.io",
+				Tenant:          "admin",
+			},
+			Binding: domain.Binding{
+				VirtualServiceName: "vstest",
+				Tenant:             "web",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Eq("admin")).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		mockClientServices.EXPECT().
+			GetVirtualServiceByName(gomock.Any(), gomock.Eq("vstest"), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				require.Equal(t, 1, len(options))
+
+				vsn := name
+				vsUUID := "virtualservice-test"
+
+				vs := &models.VirtualService{
+					Name:     &vsn,
+					Services: sslServices(),
+					UUID:     &vsUUID,
+				}
+				return vs, nil
+			})
+
+		kacn := "wildcard.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-shared#" + kacn
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{
+				Name: &kacn,
+				URL:  &kacURL,
+			}, nil)
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByID(gomock.Any(), gomock.Eq("sslkeyandcertificate-shared"), gomock.Any(), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{
+				Name: &kacn,
+				URL:  &kacURL,
+			}, nil)
+
+		mockClientServices.EXPECT().
+			UpdateVirtualService(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				require.Equal(t, 1, len(options))
+				require.Equal(t, []string{kacURL}, obj.SslKeyAndCertificateRefs)
+				return obj, nil
+			})
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	})
+	t.Run("success_ssl_profile_and_port", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+		whService.snapshots = newSnapshotStore(t.TempDir())
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+			Binding: domain.Binding{
+				VirtualServiceName: "vstest",
+				SslProfileName:     "modern",
+				ServicePort:        8443,
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		mockClientServices.EXPECT().
+			GetVirtualServiceByName(gomock.Any(), gomock.Eq("vstest")).
+			DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				vsn := name
+				vsUUID := "virtualservice-test"
+				httpPort := uint32(80)
+				httpsPort := uint32(8443)
+
+				vs := &models.VirtualService{
+					Name: &vsn,
+					Services: []*models.Service{
+						{Port: &httpPort},
+						{Port: &httpsPort},
+					},
+					UUID: &vsUUID,
+				}
+				return vs, nil
+			})
+
+		profileName := "modern"
+		profileType := SslProfileTypeApplication
+		profileURL := "https://localhost/api/sslprofile/sslprofile-modern#modern"
+
+		mockClientServices.EXPECT().
+			GetSSLProfileByName(gomock.Any(), gomock.Eq(profileName)).
+			Return(&models.SSLProfile{
+				Name: &profileName,
+				Type: &profileType,
+				URL:  &profileURL,
+			}, nil)
+
+		kacn := "installation.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/" + kacn
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{
+				Name: &kacn,
+				URL:  &kacURL,
+			}, nil)
+
+		mockClientServices.EXPECT().
+			UpdateVirtualService(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				require.Equal(t, profileURL, *obj.SslProfileRef)
+				require.Nil(t, obj.Services[0].EnableSsl)
+				require.True(t, *obj.Services[1].EnableSsl)
+				require.Equal(t, []string{kacURL}, obj.SslKeyAndCertificateRefs)
+				return obj, nil
+			})
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		// the previous certificates were saved, so there is nothing to warn about
+		require.Empty(t, recorder.Body.String())
+
+		snapshots, err := whService.snapshots.list("localhost:443", "test", "vstest")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+	})
+
+	t.Run("no_ssl_service_port", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+			Binding: domain.Binding{
+				VirtualServiceName: "vstest",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		mockClientServices.EXPECT().
+			GetVirtualServiceByName(gomock.Any(), gomock.Eq("vstest")).
+			DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				vsn := name
+				vsUUID := "virtualservice-test"
+				port := uint32(80)
+
+				vs := &models.VirtualService{
+					Name: &vsn,
+					Services: []*models.Service{
+						{Port: &port},
+					},
+					UUID: &vsUUID,
+				}
+				return vs, nil
+			})
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, recorder.Body.String(), "no SSL enabled service port")
+	})
+
+	for _, replaced := range []bool{false, true} {
+		t.Run(fmt.Sprintf("legacy_ssl_profile_replaced_%t", replaced), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockClientServices := mocks.NewMockClientServices(ctrl)
+
+			whService := NewWebhookService(mockClientServices, nil)
+			require.NotNil(t, whService)
+
+			binding := domain.Binding{
+				VirtualServiceName: "vstest",
+			}
+			if replaced {
+				binding.SslProfileName = "legacy"
+			}
+
+			raw, err := json.Marshal(&ConfigureInstallationEndpointRequest{
+				Connection: &domain.Connection{
+					HostnameOrAddress: "localhost",
+					Password:          "password",
+					Port:              443,
+					Username:          "user",
+				},
+				Keystore: domain.Keystore{
+					CertificateName: "installation.test.io",
+					Tenant:          "test",
+				},
+				Binding: binding,
+			})
+			require.NoError(t, err)
+
+			recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+			require.NotNil(t, ctx)
+			require.NotNil(t, recorder)
+
+			mockClientServices.EXPECT().
+				NewClient(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+					return &domain.Client{
+						Connection: connection,
+						Tenant:     tenant,
+					}
+				})
+			mockClientServices.EXPECT().
+				Connect(gomock.Any()).
+				Return(nil)
+			mockClientServices.EXPECT().
+				Close(gomock.Any())
+
+			profileName := "legacy"
+			profileType := SslProfileTypeApplication
+			profileURL := "https://localhost/api/sslprofile/sslprofile-legacy#legacy"
+			tls1 := "SSL_VERSION_TLS1"
+			tls12 := "SSL_VERSION_TLS1_2"
+			profile := &models.SSLProfile{
+				AcceptedVersions: []*models.SSLVersion{{Type: &tls12}, {Type: &tls1}},
+				Name:             &profileName,
+				Type:             &profileType,
+				URL:              &profileURL,
+			}
+
+			mockClientServices.EXPECT().
+				GetVirtualServiceByName(gomock.Any(), gomock.Eq("vstest")).
+				DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+					vsn := name
+					vsUUID := "virtualservice-test"
+					port := uint32(443)
+					enabled := true
+
+					vs := &models.VirtualService{
+						Name: &vsn,
+						Services: []*models.Service{
+							{Port: &port, EnableSsl: &enabled},
+						},
+						SslProfileRef: &profileURL,
+						UUID:          &vsUUID,
+					}
+					return vs, nil
+				})
+
+			// the SSL profile of the virtual service is only read when the binding does not replace it
+			expected := `SSL profile "legacy" accepts the legacy protocol version SSL_VERSION_TLS1`
+			if replaced {
+				mockClientServices.EXPECT().
+					GetSSLProfileByName(gomock.Any(), gomock.Eq(profileName)).
+					Return(profile, nil)
+			} else {
+				mockClientServices.EXPECT().
+					GetSSLProfile(gomock.Any(), gomock.Eq("sslprofile-legacy")).
+					Return(profile, nil)
+				expected += ", set sslProfileName to replace it"
+			}
+
+			err = whService.HandleConfigureInstallationEndpoint(ctx)
+			require.NoError(t, err)
+
+			response := recorder.Result() // nolint:bodyclose
+			defer func() {
+				_ = response.Body.Close()
+			}()
+			require.NotNil(t, response)
+			require.Equal(t, http.StatusBadRequest, response.StatusCode)
+			require.Contains(t, recorder.Body.String(), expected)
+		})
+	}
+
+	t.Run("hostname_mismatch_enforce", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName:    "installation.test.io",
+				Tenant:             "test",
+				HostnameValidation: domain.HostnameValidationEnforce,
+			},
+			Binding: domain.Binding{
+				VirtualServiceName: "vstest",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		vsvipRef := "https://localhost/api/vsvip/vsvip-test#vsvip-test"
+		mockClientServices.EXPECT().
+			GetVirtualServiceByName(gomock.Any(), gomock.Eq("vstest")).
+			DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				vsn := name
+				vsUUID := "virtualservice-test"
+
+				vs := &models.VirtualService{
+					Name:         &vsn,
+					Services:     sslServices(),
+					UUID:         &vsUUID,
+					VhDomainName: []string{"Delay.Daytona.QA.Venafi.io."},
+					VsvipRef:     &vsvipRef,
+				}
+				return vs, nil
+			})
+
+		fqdn := "other.venafi.io"
+		mockClientServices.EXPECT().
+			GetVsVipByID(gomock.Any(), gomock.Eq("vsvip-test")).
+			Return(&models.VsVip{
+				DNSInfo: []*models.DNSInfo{{Fqdn: &fqdn}},
+			}, nil)
+
+		kacn := "installation.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-test#" + kacn
+		kacPem := certificatePem
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{
+				Certificate: &models.SSLCertificate{
+					Certificate: &kacPem,
+				},
+				Name: &kacn,
+				URL:  &kacURL,
+			}, nil)
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, recorder.Body.String(), "does not cover the virtual service hostnames: other.venafi.io")
+	})
+
+	t.Run("pool_usage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// a discovered usage other than by a virtual service is reported only, and no session is opened
+		whService := NewWebhookService(mocks.NewMockClientServices(ctrl), nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+			Binding: domain.Binding{
+				UsageType:  domain.UsageTypePool,
+				ObjectName: "pool-test",
+			},
+		})
+		require.NoError(t, err)
+
+		recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+		require.Equal(t, `the "pool" usage of a certificate cannot be configured`, recorder.Body.String())
+	})
+
+	t.Run("invalid_targets", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// a binding with no target, or with both a single and a bulk target, is rejected before a session is opened
+		whService := NewWebhookService(mocks.NewMockClientServices(ctrl), nil)
+		require.NotNil(t, whService)
+
+		for _, tc := range []struct {
+			binding  domain.Binding
+			expected string
+		}{
+			{
+				binding:  domain.Binding{},
+				expected: "a virtual service name, or the virtual service names or pattern of a bulk binding, is required",
+			},
+			{
+				binding:  domain.Binding{VirtualServiceName: " "},
+				expected: "a virtual service name, or the virtual service names or pattern of a bulk binding, is required",
+			},
+			{
+				binding:  domain.Binding{VirtualServiceName: "vs-test", VirtualServiceNames: []string{"vs-a"}},
+				expected: "a virtual service name cannot be combined with the virtual service names or pattern of a bulk binding",
+			},
+			{
+				binding:  domain.Binding{VirtualServiceName: "vs-test", VirtualServiceNamePattern: "web-*"},
+				expected: "a virtual service name cannot be combined with the virtual service names or pattern of a bulk binding",
+			},
+		} {
+			var raw []byte
+
+			raw, err = json.Marshal(&ConfigureInstallationEndpointRequest{
+				Connection: &domain.Connection{
+					HostnameOrAddress: "localhost",
+					Password:          "password",
+					Port:              443,
+					Username:          "user",
+				},
+				Keystore: domain.Keystore{
+					CertificateName: "installation.test.io",
+					Tenant:          "test",
+				},
+				Binding: tc.binding,
+			})
+			require.NoError(t, err)
+
+			recorder, ctx := setupPost(e, "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+
+			err = whService.HandleConfigureInstallationEndpoint(ctx)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+			require.Equal(t, tc.expected, recorder.Body.String())
+		}
+	})
+}
+
+func sslServices() []*models.Service {
+	enabled := true
+	port := uint32(443)
+
+	return []*models.Service{
+		{
+			EnableSsl: &enabled,
+			Port:      &port,
+		},
+	}
+}
+
+func TestBulkBindingStatusCode(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		success  []bool
+		expected int
+	}{
+		{name: "all_bound", success: []bool{true, true}, expected: http.StatusOK},
+		{name: "partially_bound", success: []bool{true, false}, expected: http.StatusMultiStatus},
+		{name: "none_bound", success: []bool{false, false}, expected: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := &ConfigureInstallationEndpointResponse{}
+			for _, success := range tc.success {
+				res.Results = append(res.Results, &VirtualServiceBindingResult{Success: success})
+			}
+
+			require.Equal(t, tc.expected, res.getStatusCode())
+		})
+	}
+}
+
+func TestResolveVirtualServiceNames(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClientServices := mocks.NewMockClientServices(ctrl)
+	mockClientServices.EXPECT().
+		GetAllVirtualServices(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("permission denied"))
+
+	whService := NewWebhookService(mockClientServices, nil)
+
+	client := &domain.Client{
+		Connection: &domain.Connection{HostnameOrAddress: "localhost", Port: 443},
+		Tenant:     "admin",
+	}
+
+	// the failure reports the tenant of the binding, where the virtual services are read
+	_, err := whService.resolveVirtualServiceNames(client, &domain.Binding{
+		Tenant:                    "shared",
+		VirtualServiceNamePattern: "web-*",
+	})
+	require.EqualError(t, err, `failed to read virtual services for the tenant "shared": permission denied`)
 }
@@ -8,3 +8,9 @@ import (
 func (svc *WebhookServiceImpl) HandleDiscoverCertificates(c echo.Context) error {
 	return svc.Discovery.DiscoverCertificates(c)
 }
+
+// HandleDiscoverCertificatesStream will attempt to perform a discovery of the VMware AVI certificates and usage, writing
+// the discovered certificates as newline delimited JSON records
+func (svc *WebhookServiceImpl) HandleDiscoverCertificatesStream(c echo.Context) error {
+	return svc.Discovery.DiscoverCertificatesStream(c)
+}
@@ -0,0 +1,265 @@
+package vmwareavi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+func requiresHealthCheck(binding *domain.Binding) bool {
+	return binding.VerifyOperStatus || binding.VerifyHandshake
+}
+
+// verifyVirtualService will poll the virtual service until the requested health checks succeed, or the timeout elapses
+// or the request is canceled. The virtual service must present the bound certificate before it is reported up.
+func (svc *WebhookServiceImpl) verifyVirtualService(ctx context.Context, client *domain.Client, binding *domain.Binding, vs *models.VirtualService, kac *models.SSLKeyAndCertificate) error {
+	if vs.Enabled != nil && !*vs.Enabled {
+		zap.L().Info("skipping health check of disabled virtual service", zap.String("tenant", client.Tenant), zap.String("virtualService", binding.VirtualServiceName))
+		return nil
+	}
+
+	timeout := time.Duration(binding.HealthCheckTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+
+	if kac.Certificate == nil || kac.Certificate.Certificate == nil {
+		return fmt.Errorf(`certificate "%s" has no content to verify`, getValue(kac.Name))
+	}
+
+	certificate, err := parseCertificatePEM([]byte(*kac.Certificate.Certificate))
+	if err != nil || certificate == nil {
+		return fmt.Errorf(`certificate "%s" content could not be parsed for verification`, getValue(kac.Name))
+	}
+
+	deadline := time.Now().Add(timeout)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	ticker := time.NewTicker(svc.healthCheckInterval)
+	defer ticker.Stop()
+
+	var last error
+	for {
+		err = svc.checkVirtualService(ctx, client, binding, vs, certificate.Raw)
+		if err == nil {
+			zap.L().Info("virtual service health check succeeded", zap.String("tenant", client.Tenant), zap.String("virtualService", binding.VirtualServiceName))
+			return nil
+		}
+
+		// a check interrupted by the end of the health check does not replace the failure of the previous check
+		if last == nil || (ctx.Err() == nil && time.Now().Before(deadline)) {
+			last = err
+		}
+
+		zap.L().Info("virtual service health check pending", zap.String("tenant", client.Tenant), zap.String("virtualService", binding.VirtualServiceName), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("health check did not succeed within %s: %w", timeout, last)
+			}
+
+			return fmt.Errorf("health check was canceled: %w", last)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkVirtualService will check the operational state of the virtual service when requested, and that the virtual
+// service presents the expected leaf certificate
+func (svc *WebhookServiceImpl) checkVirtualService(ctx context.Context, client *domain.Client, binding *domain.Binding, vs *models.VirtualService, expected []byte) error {
+	options := getTenantOptions(client, binding.Tenant)
+
+	if binding.VerifyOperStatus {
+		runtime, err := svc.ClientServices.GetVirtualServiceRuntime(client, *vs.UUID, options...)
+		if err != nil {
+			return fmt.Errorf("failed to read virtual service runtime: %w", err)
+		}
+
+		if runtime == nil || runtime.OperStatus == nil || runtime.OperStatus.State == nil {
+			return errors.New("virtual service runtime has no operational status")
+		}
+
+		if *runtime.OperStatus.State != domain.OperStateUp {
+			return fmt.Errorf("virtual service operational state is %s", *runtime.OperStatus.State)
+		}
+	}
+
+	address, err := svc.getVirtualServiceAddress(client, vs, options...)
+	if err != nil {
+		return err
+	}
+
+	var presented []byte
+	presented, err = probeCertificate(ctx, address, getServerName(vs), svc.healthCheckInterval)
+	if err != nil {
+		return err
+	}
+
+	if string(presented) != string(expected) {
+		return fmt.Errorf("virtual service at %s is not presenting the bound certificate", address)
+	}
+
+	return nil
+}
+
+// getVirtualServiceAddress will return the first VIP address and SSL enabled port of the virtual service
+func (svc *WebhookServiceImpl) getVirtualServiceAddress(client *domain.Client, vs *models.VirtualService, options ...session.ApiOptionsParams) (string, error) {
+	port := 0
+	for _, service := range vs.Services {
+		if service != nil && service.EnableSsl != nil && *service.EnableSsl && service.Port != nil {
+			port = int(*service.Port)
+			break
+		}
+	}
+
+	if port == 0 {
+		return "", errors.New("virtual service has no SSL enabled service port")
+	}
+
+	vips := vs.Vip
+	if len(vips) == 0 && vs.VsvipRef != nil {
+		vsvip, err := svc.ClientServices.GetVsVipByID(client, getUUIDFromRef(*vs.VsvipRef), options...)
+		if err != nil {
+			return "", fmt.Errorf("failed to read virtual service VIP: %w", err)
+		}
+
+		if vsvip != nil {
+			vips = vsvip.Vip
+		}
+	}
+
+	addresses := GetVipAddresses(vips)
+	if len(addresses) == 0 {
+		return "", errors.New("virtual service has no VIP address")
+	}
+
+	return net.JoinHostPort(addresses[0], strconv.Itoa(port)), nil
+}
+
+func getServerName(vs *models.VirtualService) string {
+	if len(vs.VhDomainName) > 0 {
+		return vs.VhDomainName[0]
+	}
+
+	if vs.Fqdn != nil {
+		return *vs.Fqdn
+	}
+
+	return ""
+}
+
+// probeCertificate will perform a TLS handshake and return the DER encoded leaf certificate presented by the peer
+func probeCertificate(ctx context.Context, address, serverName string, timeout time.Duration) ([]byte, error) {
+	// the handshake only inspects the presented certificate, the peer is not trusted with any data
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config: &tls.Config{
+			InsecureSkipVerify: true, // nolint:gosec
+			ServerName:         serverName,
+		},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s failed: %w", address, err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	peers := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no certificate presented by %s", address)
+	}
+
+	return peers[0].Raw, nil
+}
+
+// virtualServiceSSL is the SSL configuration of a virtual service changed by a binding
+type virtualServiceSSL struct {
+	sslKeyAndCertificateRefs []string
+	sslProfileRef            *string
+	// enableSsl is the SSL flag of each service, by service port
+	enableSsl map[uint32]*bool
+}
+
+// captureVirtualServiceSSL will copy the SSL configuration of the virtual service
+func captureVirtualServiceSSL(vs *models.VirtualService) *virtualServiceSSL {
+	state := &virtualServiceSSL{
+		sslKeyAndCertificateRefs: slices.Clone(vs.SslKeyAndCertificateRefs),
+		enableSsl:                map[uint32]*bool{},
+	}
+
+	if vs.SslProfileRef != nil {
+		ref := *vs.SslProfileRef
+		state.sslProfileRef = &ref
+	}
+
+	for _, service := range vs.Services {
+		if service == nil || service.Port == nil {
+			continue
+		}
+
+		var enabled *bool
+		if service.EnableSsl != nil {
+			value := *service.EnableSsl
+			enabled = &value
+		}
+
+		state.enableSsl[*service.Port] = enabled
+	}
+
+	return state
+}
+
+// restore will set the captured SSL configuration on the virtual service
+func (state *virtualServiceSSL) restore(vs *models.VirtualService) {
+	vs.SslKeyAndCertificateRefs = state.sslKeyAndCertificateRefs
+	vs.SslProfileRef = state.sslProfileRef
+
+	for _, service := range vs.Services {
+		if service == nil || service.Port == nil {
+			continue
+		}
+
+		if enabled, ok := state.enableSsl[*service.Port]; ok {
+			service.EnableSsl = enabled
+		}
+	}
+}
+
+// revertVirtualService will restore the previous certificate references, SSL profile and service port SSL flags of
+// the virtual service
+func (svc *WebhookServiceImpl) revertVirtualService(client *domain.Client, name string, state *virtualServiceSSL, options ...session.ApiOptionsParams) error {
+	vs, err := svc.ClientServices.GetVirtualServiceByName(client, name, options...)
+	if err != nil {
+		return fmt.Errorf(`failed to retrieve virtual service "%s": %w`, name, err)
+	}
+
+	if vs == nil {
+		return fmt.Errorf(`failed to retrieve virtual service "%s": empty response`, name)
+	}
+
+	state.restore(vs)
+
+	_, err = svc.ClientServices.UpdateVirtualService(client, vs, options...)
+	if err != nil {
+		return fmt.Errorf(`failed to restore the virtual service "%s": %w`, name, err)
+	}
+
+	zap.L().Info("restored previous SSL configuration of virtual service", zap.String("tenant", client.Tenant), zap.String("virtualService", name))
+	return nil
+}
@@ -0,0 +1,127 @@
+package vmwareavi
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestVerifyVirtualService(t *testing.T) {
+	bound, err := tls.X509KeyPair([]byte(certificatePem), []byte(privateKeyPem))
+	require.NoError(t, err)
+
+	// other is the certificate of a test server, presented instead of the bound certificate
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	other := server.TLS.Certificates[0]
+	server.Close()
+
+	newServer := func(t *testing.T, certificate tls.Certificate) int {
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = listener.Close()
+		})
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+
+				go func() {
+					_ = conn.(*tls.Conn).Handshake()
+					_ = conn.Close()
+				}()
+			}
+		}()
+
+		return listener.Addr().(*net.TCPAddr).Port
+	}
+
+	newVirtualService := func(port int) *models.VirtualService {
+		name := "vstest"
+		uuid := "virtualservice-test"
+		address := "127.0.0.1"
+		servicePort := uint32(port)
+		enabled := true
+
+		return &models.VirtualService{
+			Name:     &name,
+			Services: []*models.Service{{Port: &servicePort, EnableSsl: &enabled}},
+			UUID:     &uuid,
+			Vip:      []*models.Vip{{IPAddress: &models.IPAddr{Addr: &address}}},
+		}
+	}
+
+	content := certificatePem
+	kac := &models.SSLKeyAndCertificate{
+		Certificate: &models.SSLCertificate{Certificate: &content},
+	}
+
+	client := &domain.Client{Tenant: "test"}
+
+	setup := func(t *testing.T, state string) *WebhookServiceImpl {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		mockClientServices.EXPECT().
+			GetVirtualServiceRuntime(gomock.Any(), gomock.Eq("virtualservice-test")).
+			Return(&domain.VirtualServiceRuntime{
+				OperStatus: &models.OperationalStatus{State: &state},
+			}, nil).
+			AnyTimes()
+
+		whService := NewWebhookService(mockClientServices, nil)
+		whService.healthCheckInterval = 100 * time.Millisecond
+		return whService
+	}
+
+	binding := &domain.Binding{
+		VirtualServiceName: "vstest",
+		VerifyOperStatus:   true,
+		HealthCheckTimeout: 1,
+	}
+
+	t.Run("up_presenting_bound_certificate", func(t *testing.T) {
+		whService := setup(t, domain.OperStateUp)
+
+		err := whService.verifyVirtualService(context.Background(), client, binding, newVirtualService(newServer(t, bound)), kac)
+		require.NoError(t, err)
+	})
+
+	t.Run("up_presenting_other_certificate", func(t *testing.T) {
+		whService := setup(t, domain.OperStateUp)
+
+		// the virtual service is up, but it is only reported up once it presents the bound certificate
+		port := newServer(t, other)
+		err := whService.verifyVirtualService(context.Background(), client, binding, newVirtualService(port), kac)
+		require.ErrorContains(t, err, "health check did not succeed within 1s")
+		require.ErrorContains(t, err, "127.0.0.1:"+strconv.Itoa(port)+" is not presenting the bound certificate")
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		whService := setup(t, "OPER_DOWN")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		started := time.Now()
+		err := whService.verifyVirtualService(ctx, client, binding, newVirtualService(newServer(t, bound)), kac)
+		require.ErrorContains(t, err, "health check was canceled")
+		require.ErrorContains(t, err, "OPER_DOWN")
+		require.Less(t, time.Since(started), time.Second)
+	})
+}
@@ -0,0 +1,179 @@
+package vmwareavi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+// GetVirtualServiceHostnames will return the unique hostnames served by the virtual service, from the virtual hosting
+// domain names of the virtual service and the DNS names of its VIP
+func GetVirtualServiceHostnames(clientServices ClientServices, client *domain.Client, vs *models.VirtualService, options ...session.ApiOptionsParams) ([]string, error) {
+	var vsvip *models.VsVip
+	if vs.VsvipRef != nil {
+		var err error
+		vsvip, err = clientServices.GetVsVipByID(client, getUUIDFromRef(*vs.VsvipRef), options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read virtual service VIP: %w", err)
+		}
+	}
+
+	return GetHostnames(vs, vsvip), nil
+}
+
+// GetHostnames will return the unique hostnames from the virtual hosting domain names of the virtual service and the
+// DNS names of its VIP, the VIP is optional
+func GetHostnames(vs *models.VirtualService, vsvip *models.VsVip) []string {
+	hostnames := make([]string, 0, len(vs.VhDomainName))
+	add := func(hostname string) {
+		hostname = normalizeHostname(hostname)
+		if len(hostname) == 0 {
+			return
+		}
+
+		for _, existing := range hostnames {
+			if existing == hostname {
+				return
+			}
+		}
+
+		hostnames = append(hostnames, hostname)
+	}
+
+	for _, hostname := range vs.VhDomainName {
+		add(hostname)
+	}
+
+	if vsvip != nil {
+		for _, dnsInfo := range vsvip.DNSInfo {
+			if dnsInfo != nil && dnsInfo.Fqdn != nil {
+				add(*dnsInfo.Fqdn)
+			}
+		}
+	}
+
+	return hostnames
+}
+
+// GetUnmatchedHostnames will return the hostnames not covered by the DNS subject alternative names of the PEM encoded
+// certificate, or by its common name when the certificate has no DNS subject alternative names.
+// A wildcard name covers a single label only, so *.example.com covers a.example.com but not example.com or a.b.example.com.
+func GetUnmatchedHostnames(certificatePEM string, hostnames []string) ([]string, error) {
+	certificate, err := parseCertificatePEM([]byte(certificatePEM))
+	if err != nil {
+		return nil, err
+	}
+
+	if certificate == nil {
+		return nil, errors.New("no certificate found in content")
+	}
+
+	names := certificate.DNSNames
+	if len(names) == 0 && len(certificate.Subject.CommonName) > 0 {
+		names = []string{certificate.Subject.CommonName}
+	}
+
+	unmatched := make([]string, 0)
+	for _, hostname := range hostnames {
+		matched := false
+		for _, name := range names {
+			if matchesHostname(name, hostname) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			unmatched = append(unmatched, hostname)
+		}
+	}
+
+	return unmatched, nil
+}
+
+func matchesHostname(name, hostname string) bool {
+	name = normalizeHostname(name)
+	hostname = normalizeHostname(hostname)
+
+	if len(name) == 0 || len(hostname) == 0 {
+		return false
+	}
+
+	if name == hostname {
+		return true
+	}
+
+	// a wildcard hostname is only covered by the same wildcard name
+	if !strings.HasPrefix(name, "*.") || strings.HasPrefix(hostname, "*.") {
+		return false
+	}
+
+	idx := strings.Index(hostname, ".")
+	if idx <= 0 {
+		return false
+	}
+
+	return hostname[idx+1:] == name[2:]
+}
+
+func normalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+}
+
+// validateHostnames will compare the certificate names with the hostnames of the virtual service and, depending on the
+// keystore hostname validation policy, log a warning or return an error for any hostname not covered by the certificate
+func (svc *WebhookServiceImpl) validateHostnames(client *domain.Client, keystore *domain.Keystore, vs *models.VirtualService, kac *models.SSLKeyAndCertificate, options ...session.ApiOptionsParams) error {
+	policy := strings.ToLower(keystore.HostnameValidation)
+	if policy == domain.HostnameValidationNone {
+		return nil
+	}
+
+	enforce := policy == domain.HostnameValidationEnforce
+
+	hostnames, err := GetVirtualServiceHostnames(svc.ClientServices, client, vs, options...)
+	if err != nil {
+		if enforce {
+			return err
+		}
+
+		zap.L().Warn("unable to read virtual service hostnames for validation", zap.String("tenant", client.Tenant), zap.String("virtualService", getValue(vs.Name)), zap.Error(err))
+		return nil
+	}
+
+	if len(hostnames) == 0 {
+		return nil
+	}
+
+	var unmatched []string
+	if kac.Certificate == nil || kac.Certificate.Certificate == nil {
+		err = errors.New("no certificate content")
+	} else {
+		unmatched, err = GetUnmatchedHostnames(*kac.Certificate.Certificate, hostnames)
+	}
+
+	if err != nil {
+		if enforce {
+			return fmt.Errorf(`unable to validate certificate "%s" names: %w`, getValue(kac.Name), err)
+		}
+
+		zap.L().Warn("unable to validate certificate names", zap.String("tenant", client.Tenant), zap.String("certificateName", getValue(kac.Name)), zap.Error(err))
+		return nil
+	}
+
+	if len(unmatched) == 0 {
+		return nil
+	}
+
+	if enforce {
+		return fmt.Errorf(`certificate "%s" does not cover the virtual service hostnames: %s`, getValue(kac.Name), strings.Join(unmatched, ", "))
+	}
+
+	zap.L().Warn("certificate does not cover the virtual service hostnames", zap.String("tenant", client.Tenant), zap.String("certificateName", getValue(kac.Name)), zap.String("virtualService", getValue(vs.Name)), zap.Strings("hostnames", unmatched))
+	return nil
+}
@@ -0,0 +1,34 @@
+package vmwareavi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchesHostname(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		require.True(t, matchesHostname("www.example.com", "WWW.Example.com."))
+		require.True(t, matchesHostname("*.example.com", "www.example.com"))
+		require.True(t, matchesHostname("*.example.com", "*.example.com"))
+
+		require.False(t, matchesHostname("*.example.com", "example.com"))
+		require.False(t, matchesHostname("*.example.com", "a.www.example.com"))
+		require.False(t, matchesHostname("www.example.com", "*.example.com"))
+		require.False(t, matchesHostname("www.example.com", "api.example.com"))
+		require.False(t, matchesHostname("", "www.example.com"))
+	})
+}
+
+func TestGetUnmatchedHostnames(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		unmatched, err := GetUnmatchedHostnames(certificatePem, []string{"delay.daytona.qa.venafi.io", "other.venafi.io"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"other.venafi.io"}, unmatched)
+	})
+
+	t.Run("invalid_certificate", func(t *testing.T) {
+		_, err := GetUnmatchedHostnames("", []string{"delay.daytona.qa.venafi.io"})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,89 @@
+package vmwareavi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+// ErrVirtualServiceBusy is returned when another request holds the lock of a virtual service for longer than the lock timeout
+var ErrVirtualServiceBusy = errors.New("virtual service is busy")
+
+// keyedLock provides mutual exclusion per key, with a bounded wait to acquire a lock
+type keyedLock struct {
+	mutex   sync.Mutex
+	entries map[string]*keyedLockEntry
+}
+
+type keyedLockEntry struct {
+	held chan struct{}
+	refs int
+}
+
+func newKeyedLock() *keyedLock {
+	return &keyedLock{
+		entries: make(map[string]*keyedLockEntry),
+	}
+}
+
+// lock will wait up to the timeout to acquire the lock for the key, and returns the function that releases the lock
+func (kl *keyedLock) lock(key string, timeout time.Duration) (func(), bool) {
+	kl.mutex.Lock()
+	entry, ok := kl.entries[key]
+	if !ok {
+		entry = &keyedLockEntry{
+			held: make(chan struct{}, 1),
+		}
+		kl.entries[key] = entry
+	}
+	entry.refs++
+	kl.mutex.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case entry.held <- struct{}{}:
+		return func() {
+			<-entry.held
+			kl.release(key, entry)
+		}, true
+	case <-timer.C:
+		kl.release(key, entry)
+		return nil, false
+	}
+}
+
+// release will drop a reference to the entry, removing the entry once no request holds or waits for it
+func (kl *keyedLock) release(key string, entry *keyedLockEntry) {
+	kl.mutex.Lock()
+	defer kl.mutex.Unlock()
+
+	entry.refs--
+	if entry.refs == 0 {
+		delete(kl.entries, key)
+	}
+}
+
+// lockVirtualService will serialize updates of a virtual service by requests of this connector instance.
+// The lock is keyed by the controller, the tenant and the name of the virtual service.
+func (svc *WebhookServiceImpl) lockVirtualService(client *domain.Client, tenant, name string) (func(), error) {
+	if len(tenant) == 0 {
+		tenant = client.Tenant
+	}
+
+	key := strings.ToLower(fmt.Sprintf("%s:%d/%s/%s", client.Connection.HostnameOrAddress, client.Connection.Port, tenant, name))
+
+	unlock, ok := svc.virtualServiceLocks.lock(key, svc.virtualServiceLockTimeout)
+	if !ok {
+		zap.L().Info("timed out waiting for virtual service lock", zap.String("tenant", tenant), zap.String("virtualService", name), zap.Duration("timeout", svc.virtualServiceLockTimeout))
+		return nil, fmt.Errorf(`%w: virtual service "%s" is being updated by another request, retry later`, ErrVirtualServiceBusy, name)
+	}
+
+	return unlock, nil
+}
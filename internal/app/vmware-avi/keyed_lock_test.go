@@ -0,0 +1,144 @@
+package vmwareavi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func TestKeyedLock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("serializes_same_key", func(t *testing.T) {
+		kl := newKeyedLock()
+
+		active := 0
+		maxActive := 0
+		total := 0
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				unlock, ok := kl.lock("controller/admin/vs", time.Minute)
+				if !ok {
+					t.Error("failed to acquire lock")
+					return
+				}
+				defer unlock()
+
+				// the counters are only guarded by the keyed lock, so the race detector reports any overlap
+				active++
+				if active > maxActive {
+					maxActive = active
+				}
+				total++
+				time.Sleep(time.Millisecond)
+				active--
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, 1, maxActive)
+		require.Equal(t, 20, total)
+		require.Empty(t, kl.entries)
+	})
+
+	t.Run("independent_keys", func(t *testing.T) {
+		kl := newKeyedLock()
+
+		unlockA, ok := kl.lock("controller/admin/a", time.Minute)
+		require.True(t, ok)
+		defer unlockA()
+
+		unlockB, ok := kl.lock("controller/admin/b", 10*time.Millisecond)
+		require.True(t, ok)
+		unlockB()
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		kl := newKeyedLock()
+
+		unlock, ok := kl.lock("controller/admin/vs", time.Minute)
+		require.True(t, ok)
+
+		_, ok = kl.lock("controller/admin/vs", 10*time.Millisecond)
+		require.False(t, ok)
+
+		unlock()
+		require.Empty(t, kl.entries)
+	})
+
+	t.Run("configure_busy", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+		whService.virtualServiceLockTimeout = 10 * time.Millisecond
+
+		connection := &domain.Connection{
+			HostnameOrAddress: "localhost",
+			Password:          "password",
+			Port:              443,
+			Username:          "user",
+		}
+
+		raw, err := json.Marshal(&ConfigureInstallationEndpointRequest{
+			Connection: connection,
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+			Binding: domain.Binding{
+				VirtualServiceName: "vstest",
+			},
+		})
+		require.NoError(t, err)
+
+		recorder, ctx := setupPost(echo.New(), "/v1/configureinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		unlock, err := whService.lockVirtualService(&domain.Client{Connection: connection, Tenant: "test"}, "", "vstest")
+		require.NoError(t, err)
+		defer unlock()
+
+		err = whService.HandleConfigureInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, recorder.Body.String(), ErrVirtualServiceBusy.Error())
+	})
+}
@@ -0,0 +1,259 @@
+package vmwareavi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+// ListTenantsRequest contains the request details for listing the tenants visible to the connection user
+type ListTenantsRequest struct {
+	Connection *domain.Connection `json:"connection"`
+	// Page is the 1-based page number to return, defaults to the first page
+	Page int `json:"page,omitempty"`
+	// PageSize is the maximum number of results per page, defaults to DefaultPageSize
+	PageSize int `json:"pageSize,omitempty"`
+}
+
+// ListTenantsResponse contains the response for a ListTenantsRequest
+type ListTenantsResponse struct {
+	Tenants []string `json:"tenants"`
+	// NextPage is the page number to request for the next results, or zero when no results remain
+	NextPage int `json:"nextPage,omitempty"`
+}
+
+// ListVirtualServicesRequest contains the request details for listing the virtual services of a tenant
+type ListVirtualServicesRequest struct {
+	Connection *domain.Connection `json:"connection"`
+	// Tenant is the tenant of the binding, the virtual services of KeystoreTenant are listed when it is not set
+	Tenant         string `json:"tenant,omitempty"`
+	KeystoreTenant string `json:"keystoreTenant,omitempty"`
+	// Prefix limits the results to the virtual services with a name starting with the prefix, ignoring case
+	Prefix string `json:"prefix,omitempty"`
+	// Page is the 1-based page number to return, defaults to the first page
+	Page int `json:"page,omitempty"`
+	// PageSize is the maximum number of results per page, defaults to DefaultPageSize
+	PageSize int `json:"pageSize,omitempty"`
+}
+
+// ListVirtualServicesResponse contains the response for a ListVirtualServicesRequest
+type ListVirtualServicesResponse struct {
+	VirtualServices []*VirtualServiceSummary `json:"virtualServices"`
+	// NextPage is the page number to request for the next results, or zero when no results remain
+	NextPage int `json:"nextPage,omitempty"`
+}
+
+// VirtualServiceSummary contains the details of a virtual service used to select it for a binding
+type VirtualServiceSummary struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+	SslPorts  []int    `json:"sslPorts"`
+}
+
+// HandleListTenants will attempt to return a page of the tenant names visible to the connection user
+func (svc *WebhookServiceImpl) HandleListTenants(c echo.Context) error {
+	req := ListTenantsRequest{}
+	if err := c.Bind(&req); err != nil {
+		zap.L().Error("invalid request, failed to unmarshall json", zap.Error(err))
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall json: %s", err.Error()))
+	}
+
+	var err error
+
+	client := svc.ClientServices.NewClient(req.Connection, "")
+	err = svc.ClientServices.Connect(client)
+	defer func() {
+		svc.ClientServices.Close(client)
+	}()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	page, pageSize := getLookupPage(req.Page, req.PageSize)
+
+	var tenants []*models.Tenant
+	tenants, err = svc.ClientServices.GetAllTenants(client, session.SetParams(map[string]string{
+		"page":      strconv.Itoa(page),
+		"page_size": strconv.Itoa(pageSize),
+		"sort":      "name",
+	}))
+	if err != nil && !IsNoResultsPage(err) {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to read tenants: %s", err.Error()))
+	}
+
+	res := ListTenantsResponse{
+		Tenants: make([]string, 0, len(tenants)),
+	}
+
+	for _, tenant := range tenants {
+		if tenant != nil && tenant.Name != nil {
+			res.Tenants = append(res.Tenants, *tenant.Name)
+		}
+	}
+
+	if len(tenants) == pageSize {
+		res.NextPage = page + 1
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// HandleListVirtualServices will attempt to return a page of the virtual services of a tenant, including their VIP addresses and SSL ports.
+// When a prefix is supplied the page can contain fewer results than the page size while further pages remain.
+func (svc *WebhookServiceImpl) HandleListVirtualServices(c echo.Context) error {
+	req := ListVirtualServicesRequest{}
+	if err := c.Bind(&req); err != nil {
+		zap.L().Error("invalid request, failed to unmarshall json", zap.Error(err))
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall json: %s", err.Error()))
+	}
+
+	var err error
+
+	tenant := req.Tenant
+	if len(tenant) == 0 {
+		tenant = req.KeystoreTenant
+	}
+
+	client := svc.ClientServices.NewClient(req.Connection, tenant)
+	err = svc.ClientServices.Connect(client)
+	defer func() {
+		svc.ClientServices.Close(client)
+	}()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	page, pageSize := getLookupPage(req.Page, req.PageSize)
+
+	params := map[string]string{
+		"page":      strconv.Itoa(page),
+		"page_size": strconv.Itoa(pageSize),
+		"sort":      "name",
+	}
+
+	prefix := strings.TrimSpace(req.Prefix)
+	if len(prefix) > 0 {
+		// narrows the results on the controller, the prefix match is completed below
+		params["name.icontains"] = prefix
+	}
+
+	var virtualServices []*models.VirtualService
+	virtualServices, err = svc.ClientServices.GetAllVirtualServices(client, session.SetParams(params))
+	if err != nil && !IsNoResultsPage(err) {
+		return c.String(http.StatusBadRequest, fmt.Sprintf(`failed to read virtual services for the tenant "%s": %s`, client.Tenant, err.Error()))
+	}
+
+	res := ListVirtualServicesResponse{
+		VirtualServices: make([]*VirtualServiceSummary, 0, len(virtualServices)),
+	}
+
+	for _, vs := range virtualServices {
+		if vs == nil || vs.Name == nil {
+			continue
+		}
+
+		if len(prefix) > 0 && !strings.HasPrefix(strings.ToLower(*vs.Name), strings.ToLower(prefix)) {
+			continue
+		}
+
+		var summary *VirtualServiceSummary
+		summary, err = svc.getVirtualServiceSummary(client, vs)
+		if err != nil {
+			return c.String(http.StatusBadRequest, fmt.Sprintf(`failed to read virtual service "%s": %s`, *vs.Name, err.Error()))
+		}
+
+		res.VirtualServices = append(res.VirtualServices, summary)
+	}
+
+	if len(virtualServices) == pageSize {
+		res.NextPage = page + 1
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (svc *WebhookServiceImpl) getVirtualServiceSummary(client *domain.Client, vs *models.VirtualService) (*VirtualServiceSummary, error) {
+	summary := &VirtualServiceSummary{
+		Name:      *vs.Name,
+		Addresses: []string{},
+		SslPorts:  GetSslPorts(vs),
+	}
+
+	vips := vs.Vip
+	if len(vips) == 0 && vs.VsvipRef != nil {
+		vsvip, err := svc.ClientServices.GetVsVipByID(client, getUUIDFromRef(*vs.VsvipRef))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read virtual service VIP: %w", err)
+		}
+
+		if vsvip != nil {
+			vips = vsvip.Vip
+		}
+	}
+
+	summary.Addresses = append(summary.Addresses, GetVipAddresses(vips)...)
+
+	return summary, nil
+}
+
+// getLookupPage will return the requested page number and page size, applying the defaults and limits
+func getLookupPage(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	} else if pageSize > MaxLookupPageSize {
+		pageSize = MaxLookupPageSize
+	}
+
+	return page, pageSize
+}
+
+// GetSslPorts will return the sorted, unique SSL enabled service ports of the virtual service
+func GetSslPorts(vs *models.VirtualService) []int {
+	unique := map[int]bool{}
+	for _, service := range vs.Services {
+		if service == nil || service.EnableSsl == nil || !*service.EnableSsl || service.Port == nil {
+			continue
+		}
+
+		unique[int(*service.Port)] = true
+	}
+
+	ports := make([]int, 0, len(unique))
+	for port := range unique {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	return ports
+}
+
+// GetVipAddresses will return the IPv4, IPv6 and floating addresses of the VIPs, in that order for each VIP
+func GetVipAddresses(vips []*models.Vip) []string {
+	addresses := make([]string, 0)
+	for _, vip := range vips {
+		if vip == nil {
+			continue
+		}
+
+		for _, addr := range []*models.IPAddr{vip.IPAddress, vip.Ip6Address, vip.FloatingIP, vip.FloatingIp6} {
+			if addr != nil && addr.Addr != nil && len(*addr.Addr) > 0 {
+				addresses = append(addresses, *addr.Addr)
+			}
+		}
+	}
+
+	return addresses
+}
@@ -0,0 +1,228 @@
+package vmwareavi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLookup(t *testing.T) {
+	var err error
+
+	e := echo.New()
+
+	t.Parallel()
+
+	connection := &domain.Connection{
+		HostnameOrAddress: "localhost",
+		Password:          "password",
+		Port:              443,
+		Username:          "user",
+	}
+
+	t.Run("list_tenants", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ListTenantsRequest{
+			Connection: connection,
+			PageSize:   2,
+		})
+		require.NoError(t, err)
+
+		recorder, ctx := setupPost(e, "/v1/listtenants", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     DefaultTenantName,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		admin := DefaultTenantName
+		other := "test"
+		mockClientServices.EXPECT().
+			GetAllTenants(gomock.Any(), gomock.Any()).
+			Return([]*models.Tenant{{Name: &admin}, {Name: &other}}, nil)
+
+		err = whService.HandleListTenants(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		raw, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		res := ListTenantsResponse{}
+		err = json.Unmarshal(raw, &res)
+		require.NoError(t, err)
+		require.Equal(t, []string{admin, other}, res.Tenants)
+		require.Equal(t, 2, res.NextPage)
+	})
+
+	t.Run("list_virtual_services", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&ListVirtualServicesRequest{
+			Connection: connection,
+			Tenant:     "test",
+			Prefix:     "web",
+		})
+		require.NoError(t, err)
+
+		recorder, ctx := setupPost(e, "/v1/listvirtualservices", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Eq("test")).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		matched := "Web-01"
+		unmatched := "my-web"
+		vsvipRef := "https://localhost/api/vsvip/vsvip-web#vsvip-web"
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			Return([]*models.VirtualService{
+				{
+					Name:     &matched,
+					Services: sslServices(),
+					VsvipRef: &vsvipRef,
+				},
+				{
+					Name: &unmatched,
+				},
+			}, nil)
+
+		ipv4 := "10.0.0.1"
+		ipv6 := "fd00::1"
+		mockClientServices.EXPECT().
+			GetVsVipByID(gomock.Any(), gomock.Eq("vsvip-web")).
+			Return(&models.VsVip{
+				Vip: []*models.Vip{
+					{
+						IPAddress:  &models.IPAddr{Addr: &ipv4},
+						Ip6Address: &models.IPAddr{Addr: &ipv6},
+					},
+				},
+			}, nil)
+
+		err = whService.HandleListVirtualServices(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		raw, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		res := ListVirtualServicesResponse{}
+		err = json.Unmarshal(raw, &res)
+		require.NoError(t, err)
+		require.Zero(t, res.NextPage)
+		require.Equal(t, []*VirtualServiceSummary{
+			{
+				Name:      matched,
+				Addresses: []string{ipv4, ipv6},
+				SslPorts:  []int{443},
+			},
+		}, res.VirtualServices)
+	})
+
+	t.Run("list_virtual_services_tenant", func(t *testing.T) {
+		// the virtual services of the binding tenant are listed, or of the keystore tenant when it is not set
+		for _, tc := range []struct {
+			tenant   string
+			expected string
+		}{
+			{tenant: "shared", expected: "shared"},
+			{tenant: "", expected: "admin"},
+		} {
+			ctrl := gomock.NewController(t)
+
+			mockClientServices := mocks.NewMockClientServices(ctrl)
+			whService := NewWebhookService(mockClientServices, nil)
+
+			raw, err := json.Marshal(&ListVirtualServicesRequest{
+				Connection:     connection,
+				Tenant:         tc.tenant,
+				KeystoreTenant: "admin",
+			})
+			require.NoError(t, err)
+
+			recorder, ctx := setupPost(e, "/v1/listvirtualservices", bytes.NewReader(raw))
+
+			mockClientServices.EXPECT().
+				NewClient(gomock.Any(), gomock.Eq(tc.expected)).
+				DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+					return &domain.Client{
+						Connection: connection,
+						Tenant:     tenant,
+					}
+				})
+			mockClientServices.EXPECT().
+				Connect(gomock.Any()).
+				Return(nil)
+			mockClientServices.EXPECT().
+				Close(gomock.Any())
+			mockClientServices.EXPECT().
+				GetAllVirtualServices(gomock.Any(), gomock.Any()).
+				Return([]*models.VirtualService{}, nil)
+
+			require.NoError(t, whService.HandleListVirtualServices(ctx))
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			ctrl.Finish()
+		}
+	})
+}
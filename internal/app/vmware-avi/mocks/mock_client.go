@@ -87,6 +87,105 @@ func (mr *MockClientServicesMockRecorder) CreateSSLKeyAndCertificate(client, obj
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSSLKeyAndCertificate", reflect.TypeOf((*MockClientServices)(nil).CreateSSLKeyAndCertificate), varargs...)
 }
 
+// DeleteSSLKeyAndCertificate mocks base method.
+func (m *MockClientServices) DeleteSSLKeyAndCertificate(client *domain.Client, uuid string, options ...session.ApiOptionsParams) error {
+	m.ctrl.T.Helper()
+	varargs := []any{client, uuid}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteSSLKeyAndCertificate", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSSLKeyAndCertificate indicates an expected call of DeleteSSLKeyAndCertificate.
+func (mr *MockClientServicesMockRecorder) DeleteSSLKeyAndCertificate(client, uuid any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client, uuid}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSSLKeyAndCertificate", reflect.TypeOf((*MockClientServices)(nil).DeleteSSLKeyAndCertificate), varargs...)
+}
+
+// GetAllClouds mocks base method.
+func (m *MockClientServices) GetAllClouds(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Cloud, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAllClouds", varargs...)
+	ret0, _ := ret[0].([]*models.Cloud)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllClouds indicates an expected call of GetAllClouds.
+func (mr *MockClientServicesMockRecorder) GetAllClouds(client any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllClouds", reflect.TypeOf((*MockClientServices)(nil).GetAllClouds), varargs...)
+}
+
+// GetAllGslbServices mocks base method.
+func (m *MockClientServices) GetAllGslbServices(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.GslbService, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAllGslbServices", varargs...)
+	ret0, _ := ret[0].([]*models.GslbService)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllGslbServices indicates an expected call of GetAllGslbServices.
+func (mr *MockClientServicesMockRecorder) GetAllGslbServices(client any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllGslbServices", reflect.TypeOf((*MockClientServices)(nil).GetAllGslbServices), varargs...)
+}
+
+// GetAllPKIProfiles mocks base method.
+func (m *MockClientServices) GetAllPKIProfiles(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.PKIprofile, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAllPKIProfiles", varargs...)
+	ret0, _ := ret[0].([]*models.PKIprofile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllPKIProfiles indicates an expected call of GetAllPKIProfiles.
+func (mr *MockClientServicesMockRecorder) GetAllPKIProfiles(client any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPKIProfiles", reflect.TypeOf((*MockClientServices)(nil).GetAllPKIProfiles), varargs...)
+}
+
+// GetAllPools mocks base method.
+func (m *MockClientServices) GetAllPools(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Pool, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAllPools", varargs...)
+	ret0, _ := ret[0].([]*models.Pool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllPools indicates an expected call of GetAllPools.
+func (mr *MockClientServicesMockRecorder) GetAllPools(client any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPools", reflect.TypeOf((*MockClientServices)(nil).GetAllPools), varargs...)
+}
+
 // GetAllSSLKeysAndCertificates mocks base method.
 func (m *MockClientServices) GetAllSSLKeysAndCertificates(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.SSLKeyAndCertificate, error) {
 	m.ctrl.T.Helper()
@@ -107,6 +206,26 @@ func (mr *MockClientServicesMockRecorder) GetAllSSLKeysAndCertificates(client an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSSLKeysAndCertificates", reflect.TypeOf((*MockClientServices)(nil).GetAllSSLKeysAndCertificates), varargs...)
 }
 
+// GetAllServiceEngineGroups mocks base method.
+func (m *MockClientServices) GetAllServiceEngineGroups(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.ServiceEngineGroup, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAllServiceEngineGroups", varargs...)
+	ret0, _ := ret[0].([]*models.ServiceEngineGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllServiceEngineGroups indicates an expected call of GetAllServiceEngineGroups.
+func (mr *MockClientServicesMockRecorder) GetAllServiceEngineGroups(client any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllServiceEngineGroups", reflect.TypeOf((*MockClientServices)(nil).GetAllServiceEngineGroups), varargs...)
+}
+
 // GetAllTenants mocks base method.
 func (m *MockClientServices) GetAllTenants(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.Tenant, error) {
 	m.ctrl.T.Helper()
@@ -127,6 +246,26 @@ func (mr *MockClientServicesMockRecorder) GetAllTenants(client any, options ...a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllTenants", reflect.TypeOf((*MockClientServices)(nil).GetAllTenants), varargs...)
 }
 
+// GetAllVirtualServiceInventories mocks base method.
+func (m *MockClientServices) GetAllVirtualServiceInventories(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VsInventory, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAllVirtualServiceInventories", varargs...)
+	ret0, _ := ret[0].([]*models.VsInventory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllVirtualServiceInventories indicates an expected call of GetAllVirtualServiceInventories.
+func (mr *MockClientServicesMockRecorder) GetAllVirtualServiceInventories(client any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllVirtualServiceInventories", reflect.TypeOf((*MockClientServices)(nil).GetAllVirtualServiceInventories), varargs...)
+}
+
 // GetAllVirtualServices mocks base method.
 func (m *MockClientServices) GetAllVirtualServices(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
 	m.ctrl.T.Helper()
@@ -147,6 +286,61 @@ func (mr *MockClientServicesMockRecorder) GetAllVirtualServices(client any, opti
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllVirtualServices", reflect.TypeOf((*MockClientServices)(nil).GetAllVirtualServices), varargs...)
 }
 
+// GetCluster mocks base method.
+func (m *MockClientServices) GetCluster(client *domain.Client, options ...session.ApiOptionsParams) (*domain.Cluster, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetCluster", varargs...)
+	ret0, _ := ret[0].(*domain.Cluster)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCluster indicates an expected call of GetCluster.
+func (mr *MockClientServicesMockRecorder) GetCluster(client any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCluster", reflect.TypeOf((*MockClientServices)(nil).GetCluster), varargs...)
+}
+
+// GetControllerVersion mocks base method.
+func (m *MockClientServices) GetControllerVersion(client *domain.Client) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetControllerVersion", client)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetControllerVersion indicates an expected call of GetControllerVersion.
+func (mr *MockClientServicesMockRecorder) GetControllerVersion(client any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetControllerVersion", reflect.TypeOf((*MockClientServices)(nil).GetControllerVersion), client)
+}
+
+// GetObjectCount mocks base method.
+func (m *MockClientServices) GetObjectCount(client *domain.Client, objectType string, params map[string]string, options ...session.ApiOptionsParams) (int, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client, objectType, params}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetObjectCount", varargs...)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetObjectCount indicates an expected call of GetObjectCount.
+func (mr *MockClientServicesMockRecorder) GetObjectCount(client, objectType, params any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client, objectType, params}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetObjectCount", reflect.TypeOf((*MockClientServices)(nil).GetObjectCount), varargs...)
+}
+
 // GetSSLKeyAndCertificateByID mocks base method.
 func (m *MockClientServices) GetSSLKeyAndCertificateByID(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.SSLKeyAndCertificate, error) {
 	m.ctrl.T.Helper()
@@ -187,6 +381,66 @@ func (mr *MockClientServicesMockRecorder) GetSSLKeyAndCertificateByName(client,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSSLKeyAndCertificateByName", reflect.TypeOf((*MockClientServices)(nil).GetSSLKeyAndCertificateByName), varargs...)
 }
 
+// GetSSLProfile mocks base method.
+func (m *MockClientServices) GetSSLProfile(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.SSLProfile, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client, uuid}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetSSLProfile", varargs...)
+	ret0, _ := ret[0].(*models.SSLProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSSLProfile indicates an expected call of GetSSLProfile.
+func (mr *MockClientServicesMockRecorder) GetSSLProfile(client, uuid any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client, uuid}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSSLProfile", reflect.TypeOf((*MockClientServices)(nil).GetSSLProfile), varargs...)
+}
+
+// GetSSLProfileByName mocks base method.
+func (m *MockClientServices) GetSSLProfileByName(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.SSLProfile, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client, name}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetSSLProfileByName", varargs...)
+	ret0, _ := ret[0].(*models.SSLProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSSLProfileByName indicates an expected call of GetSSLProfileByName.
+func (mr *MockClientServicesMockRecorder) GetSSLProfileByName(client, name any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client, name}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSSLProfileByName", reflect.TypeOf((*MockClientServices)(nil).GetSSLProfileByName), varargs...)
+}
+
+// GetSystemConfiguration mocks base method.
+func (m *MockClientServices) GetSystemConfiguration(client *domain.Client, options ...session.ApiOptionsParams) (*models.SystemConfiguration, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetSystemConfiguration", varargs...)
+	ret0, _ := ret[0].(*models.SystemConfiguration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSystemConfiguration indicates an expected call of GetSystemConfiguration.
+func (mr *MockClientServicesMockRecorder) GetSystemConfiguration(client any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSystemConfiguration", reflect.TypeOf((*MockClientServices)(nil).GetSystemConfiguration), varargs...)
+}
+
 // GetVirtualServiceByName mocks base method.
 func (m *MockClientServices) GetVirtualServiceByName(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
 	m.ctrl.T.Helper()
@@ -207,6 +461,46 @@ func (mr *MockClientServicesMockRecorder) GetVirtualServiceByName(client, name a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVirtualServiceByName", reflect.TypeOf((*MockClientServices)(nil).GetVirtualServiceByName), varargs...)
 }
 
+// GetVirtualServiceRuntime mocks base method.
+func (m *MockClientServices) GetVirtualServiceRuntime(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*domain.VirtualServiceRuntime, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client, uuid}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetVirtualServiceRuntime", varargs...)
+	ret0, _ := ret[0].(*domain.VirtualServiceRuntime)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVirtualServiceRuntime indicates an expected call of GetVirtualServiceRuntime.
+func (mr *MockClientServicesMockRecorder) GetVirtualServiceRuntime(client, uuid any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client, uuid}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVirtualServiceRuntime", reflect.TypeOf((*MockClientServices)(nil).GetVirtualServiceRuntime), varargs...)
+}
+
+// GetVsVipByID mocks base method.
+func (m *MockClientServices) GetVsVipByID(client *domain.Client, uuid string, options ...session.ApiOptionsParams) (*models.VsVip, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{client, uuid}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetVsVipByID", varargs...)
+	ret0, _ := ret[0].(*models.VsVip)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVsVipByID indicates an expected call of GetVsVipByID.
+func (mr *MockClientServicesMockRecorder) GetVsVipByID(client, uuid any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{client, uuid}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVsVipByID", reflect.TypeOf((*MockClientServices)(nil).GetVsVipByID), varargs...)
+}
+
 // NewClient mocks base method.
 func (m *MockClientServices) NewClient(connection *domain.Connection, tenant string) *domain.Client {
 	m.ctrl.T.Helper()
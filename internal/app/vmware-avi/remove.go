@@ -0,0 +1,416 @@
+package vmwareavi
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+// certificateReferrerTypes are the object types that can reference a certificate, a certificate is only deleted once
+// no object of these types refers to it
+var certificateReferrerTypes = []string{
+	"virtualservice",
+	"pool",
+	"sslkeyandcertificate",
+	"vsdatascriptset",
+	"healthmonitor",
+	"authprofile",
+	"alertsyslogconfig",
+}
+
+// RemoveInstallationEndpointRequest contains the request details for removing the usage of a keystore
+type RemoveInstallationEndpointRequest struct {
+	Connection *domain.Connection `json:"connection"`
+	Keystore   domain.Keystore    `json:"keystore"`
+	Binding    domain.Binding     `json:"binding"`
+}
+
+// HandleRemoveInstallationEndpoint will attempt to unbind a keystore from its virtual services and delete the keystore
+// certificate, and any chain certificates installed by the connector, once nothing else references them
+func (svc *WebhookServiceImpl) HandleRemoveInstallationEndpoint(c echo.Context) error {
+	req := RemoveInstallationEndpointRequest{}
+	if err := c.Bind(&req); err != nil {
+		zap.L().Error("invalid request, failed to unmarshall json", zap.Error(err))
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall json: %s", err.Error()))
+	}
+
+	if !req.Binding.IsVirtualService() {
+		zap.L().Info("invalid request, only the usage of a certificate by a virtual service can be removed", zap.String("usageType", req.Binding.UsageType))
+		return c.String(http.StatusBadRequest, fmt.Sprintf(`the "%s" usage of a certificate cannot be removed`, req.Binding.UsageType))
+	}
+
+	var err error
+
+	client := svc.ClientServices.NewClient(req.Connection, req.Keystore.Tenant)
+	err = svc.ClientServices.Connect(client)
+	defer func() {
+		svc.ClientServices.Close(client)
+	}()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zap.L().Info("removing installation endpoint on VMware NSX-ALB", zap.String("address", req.Connection.HostnameOrAddress), zap.Int("port", req.Connection.Port))
+
+	err = svc.removeInstallationEndpoint(client, &req.Binding, &req.Keystore)
+	if err != nil {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to remove installation from VMware NSX-ALB: %s", err.Error()))
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (svc *WebhookServiceImpl) removeInstallationEndpoint(client *domain.Client, binding *domain.Binding, keystore *domain.Keystore) error {
+	var err error
+
+	// the certificate is read as a collection filtered by name, so a removed certificate is an empty collection
+	var certificates []*models.SSLKeyAndCertificate
+	certificates, err = svc.ClientServices.GetAllSSLKeysAndCertificates(client, session.SetParams(map[string]string{
+		"export_key": "false",
+		"name":       keystore.CertificateName,
+	}))
+	if err != nil && !isNotFound(err) && !IsNoResultsPage(err) {
+		return fmt.Errorf(`failed to retrieve certificate "%s": %w`, keystore.CertificateName, err)
+	}
+
+	if len(certificates) == 0 {
+		zap.L().Info("certificate already removed", zap.String("tenant", client.Tenant), zap.String("certificateName", keystore.CertificateName))
+		return nil
+	}
+
+	kac := certificates[0]
+	if kac == nil || kac.URL == nil || len(*kac.URL) == 0 {
+		return fmt.Errorf(`invalid certificate "%s": no assigned UUID`, keystore.CertificateName)
+	}
+
+	if len(binding.VirtualServiceName) > 0 || isBulkBinding(binding) {
+		var names []string
+		names, err = svc.resolveVirtualServiceNames(client, binding)
+		if err != nil {
+			return err
+		}
+
+		var fallback *string
+		fallback, err = svc.getFallbackCertificateRef(client, binding)
+		if err != nil {
+			return err
+		}
+
+		options := getTenantOptions(client, binding.Tenant)
+		for _, name := range names {
+			var unlock func()
+			unlock, err = svc.lockVirtualService(client, binding.Tenant, name)
+			if err != nil {
+				return err
+			}
+
+			err = svc.unbindVirtualService(client, binding.Tenant, name, *kac.URL, fallback, options...)
+			unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var deleted bool
+	deleted, err = svc.deleteUnreferencedCertificate(client, kac)
+	if err != nil || !deleted {
+		return err
+	}
+
+	for _, caCert := range kac.CaCerts {
+		if caCert == nil || caCert.CaRef == nil {
+			continue
+		}
+
+		err = svc.deleteConnectorChainCertificate(client, *caCert.CaRef)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getFallbackCertificateRef will return the reference of the fallback certificate, which must be visible to the tenant
+// of the virtual services. The fallback certificate is read in the tenant of the virtual services, and then in the
+// admin tenant, whose certificates can be shared with the other tenants.
+func (svc *WebhookServiceImpl) getFallbackCertificateRef(client *domain.Client, binding *domain.Binding) (*string, error) {
+	if len(binding.FallbackCertificateName) == 0 {
+		return nil, nil
+	}
+
+	tenant := binding.Tenant
+	if len(tenant) == 0 {
+		tenant = client.Tenant
+	}
+
+	options := getTenantOptions(client, tenant)
+
+	fallback, err := svc.ClientServices.GetSSLKeyAndCertificateByName(client, binding.FallbackCertificateName, append(options, session.SetParams(map[string]string{
+		"export_key": "false",
+	}))...)
+	if err != nil && !strings.EqualFold(tenant, DefaultTenantName) {
+		fallback, err = svc.getSharedFallbackCertificate(client, binding.FallbackCertificateName, tenant, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if fallback == nil || fallback.URL == nil || len(*fallback.URL) == 0 {
+		return nil, fmt.Errorf(`invalid fallback certificate "%s": no assigned UUID`, binding.FallbackCertificateName)
+	}
+
+	return fallback.URL, nil
+}
+
+// getSharedFallbackCertificate will read the fallback certificate from the admin tenant, when it is not a certificate
+// of the tenant of the virtual services, and verify the certificate is shared with the tenant
+func (svc *WebhookServiceImpl) getSharedFallbackCertificate(client *domain.Client, name, tenant string, tenantErr error) (*models.SSLKeyAndCertificate, error) {
+	fallback, err := svc.ClientServices.GetSSLKeyAndCertificateByName(client, name, append(getTenantOptions(client, DefaultTenantName), session.SetParams(map[string]string{
+		"export_key": "false",
+	}))...)
+	if err != nil {
+		zap.L().Info("fallback certificate not found in the admin tenant", zap.String("tenant", tenant), zap.String("certificateName", name), zap.Error(err))
+		return nil, fmt.Errorf(`fallback certificate "%s" is not visible to the tenant "%s": %w`, name, tenant, tenantErr)
+	}
+
+	if fallback == nil || fallback.URL == nil || len(*fallback.URL) == 0 {
+		return nil, fmt.Errorf(`invalid fallback certificate "%s": no assigned UUID`, name)
+	}
+
+	_, err = svc.ClientServices.GetSSLKeyAndCertificateByID(client, getUUIDFromRef(*fallback.URL), append(getTenantOptions(client, tenant), session.SetParams(map[string]string{
+		"export_key": "false",
+	}))...)
+	if err != nil {
+		return nil, fmt.Errorf(`fallback certificate "%s" of tenant "%s" is not visible to the tenant "%s": %w`, name, DefaultTenantName, tenant, err)
+	}
+
+	return fallback, nil
+}
+
+// unbindVirtualService will drop the certificate reference from the virtual service, or replace it with the fallback certificate reference
+func (svc *WebhookServiceImpl) unbindVirtualService(client *domain.Client, tenant, name, certificateRef string, fallback *string, options ...session.ApiOptionsParams) error {
+	// the virtual service is read as a collection filtered by name, so a removed virtual service is an empty collection
+	virtualServices, err := svc.ClientServices.GetAllVirtualServices(client, append([]session.ApiOptionsParams{session.SetParams(map[string]string{
+		"name": name,
+	})}, options...)...)
+	if err != nil && !isNotFound(err) && !IsNoResultsPage(err) {
+		return fmt.Errorf(`failed to retrieve virtual service "%s": %w`, name, err)
+	}
+
+	if len(virtualServices) == 0 || virtualServices[0] == nil {
+		zap.L().Info("virtual service already removed", zap.String("tenant", client.Tenant), zap.String("virtualService", name))
+		return nil
+	}
+
+	vs := virtualServices[0]
+
+	certificateUUID := getUUIDFromRef(certificateRef)
+
+	changed := false
+	refs := make([]string, 0, len(vs.SslKeyAndCertificateRefs))
+	for _, ref := range vs.SslKeyAndCertificateRefs {
+		if getUUIDFromRef(ref) != certificateUUID {
+			refs = append(refs, ref)
+			continue
+		}
+
+		changed = true
+		if fallback != nil && !containsRef(refs, *fallback) && !containsRef(vs.SslKeyAndCertificateRefs, *fallback) {
+			refs = append(refs, *fallback)
+		}
+	}
+
+	if !changed {
+		zap.L().Info("virtual service does not reference the certificate", zap.String("tenant", client.Tenant), zap.String("virtualService", name))
+		return nil
+	}
+
+	err = svc.snapshotVirtualService(client, tenant, name, vs.UUID, vs.SslKeyAndCertificateRefs)
+	if err != nil {
+		return err
+	}
+
+	vs.SslKeyAndCertificateRefs = refs
+
+	_, err = svc.ClientServices.UpdateVirtualService(client, vs, options...)
+	if err != nil {
+		return fmt.Errorf(`failed to update the virtual service "%s": %w`, name, err)
+	}
+
+	return nil
+}
+
+// deleteUnreferencedCertificate will delete the certificate when no object still references it
+func (svc *WebhookServiceImpl) deleteUnreferencedCertificate(client *domain.Client, kac *models.SSLKeyAndCertificate) (bool, error) {
+	name := getValue(kac.Name)
+	certificateUUID := getUUIDFromRef(*kac.URL)
+
+	referrers, err := svc.getCertificateReferrers(client, kac)
+	if err != nil {
+		return false, fmt.Errorf(`failed to read the objects referencing certificate "%s": %w`, name, err)
+	}
+
+	if len(referrers) > 0 {
+		zap.L().Info("certificate is still in use and will not be deleted", zap.String("tenant", client.Tenant), zap.String("certificateName", name), zap.Strings("referrers", referrers))
+		return false, nil
+	}
+
+	err = svc.ClientServices.DeleteSSLKeyAndCertificate(client, certificateUUID)
+	if err != nil {
+		return false, fmt.Errorf(`failed to delete certificate "%s": %w`, name, err)
+	}
+
+	zap.L().Info("deleted certificate", zap.String("tenant", client.Tenant), zap.String("certificateName", name))
+	return true, nil
+}
+
+// deleteConnectorChainCertificate will delete a CA certificate installed by the connector when no object references it
+func (svc *WebhookServiceImpl) deleteConnectorChainCertificate(client *domain.Client, caRef string) error {
+	caUUID := getUUIDFromRef(caRef)
+
+	cac, err := svc.ClientServices.GetSSLKeyAndCertificateByID(client, caUUID)
+	if err != nil {
+		zap.L().Info("unable to read chain certificate, skipping removal", zap.String("tenant", client.Tenant), zap.String("reference", caRef), zap.Error(err))
+		return nil
+	}
+
+	if !isConnectorChainCertificate(cac) {
+		return nil
+	}
+
+	name := getValue(cac.Name)
+
+	var referrers []string
+	referrers, err = svc.getCertificateReferrers(client, cac)
+	if err != nil {
+		return fmt.Errorf(`failed to read the objects referencing chain certificate "%s": %w`, name, err)
+	}
+
+	if len(referrers) > 0 {
+		zap.L().Info("chain certificate is still in use and will not be deleted", zap.String("tenant", client.Tenant), zap.String("certificateName", name), zap.Strings("referrers", referrers))
+		return nil
+	}
+
+	err = svc.ClientServices.DeleteSSLKeyAndCertificate(client, caUUID)
+	if err != nil {
+		return fmt.Errorf(`failed to delete chain certificate "%s": %w`, name, err)
+	}
+
+	zap.L().Info("deleted chain certificate", zap.String("tenant", client.Tenant), zap.String("certificateName", name))
+	return nil
+}
+
+// getCertificateReferrers will return the types of the objects still using the certificate. Certificates of the admin
+// tenant can be shared, so the objects of every tenant are checked, along with the portal and the secure channel of the
+// system configuration. A PKI profile holds a copy of its CA certificates, so the copies are compared with the certificate.
+func (svc *WebhookServiceImpl) getCertificateReferrers(client *domain.Client, kac *models.SSLKeyAndCertificate) ([]string, error) {
+	certificateUUID := getUUIDFromRef(*kac.URL)
+	admin := strings.EqualFold(client.Tenant, DefaultTenantName)
+
+	var options []session.ApiOptionsParams
+	if admin {
+		options = append(options, session.SetOptTenant(WildcardTenantName))
+	}
+
+	referrers := make([]string, 0)
+
+	for _, objectType := range certificateReferrerTypes {
+		count, err := svc.ClientServices.GetObjectCount(client, objectType, map[string]string{
+			"refers_to": fmt.Sprintf("sslkeyandcertificate:%s", certificateUUID),
+		}, options...)
+		if err != nil && !IsNoResultsPage(err) {
+			return nil, fmt.Errorf(`failed to read the %s objects: %w`, objectType, err)
+		}
+
+		if count > 0 {
+			referrers = append(referrers, objectType)
+		}
+	}
+
+	if kac.Certificate != nil && kac.Certificate.Certificate != nil {
+		certificate, err := parseCertificatePEM([]byte(*kac.Certificate.Certificate))
+		if err == nil && certificate != nil {
+			var profiles []*models.PKIprofile
+			profiles, err = GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.PKIprofile, error) {
+				return svc.ClientServices.GetAllPKIProfiles(client, options...)
+			}, nil, options...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the PKI profiles: %w", err)
+			}
+
+			if includesCertificate(profiles, certificate) {
+				referrers = append(referrers, "pkiprofile")
+			}
+		}
+	}
+
+	if !admin {
+		return referrers, nil
+	}
+
+	configuration, err := svc.ClientServices.GetSystemConfiguration(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the system configuration: %w", err)
+	}
+
+	if configuration != nil && configuration.PortalConfiguration != nil && containsRef(configuration.PortalConfiguration.SslkeyandcertificateRefs, *kac.URL) {
+		referrers = append(referrers, "portal")
+	}
+
+	if configuration != nil && configuration.SecureChannelConfiguration != nil && containsRef(configuration.SecureChannelConfiguration.SslkeyandcertificateRefs, *kac.URL) {
+		referrers = append(referrers, "secure channel")
+	}
+
+	return referrers, nil
+}
+
+// includesCertificate checks if any of the PKI profiles holds a copy of the certificate
+func includesCertificate(profiles []*models.PKIprofile, certificate *x509.Certificate) bool {
+	for _, profile := range profiles {
+		for _, caCert := range profile.CaCerts {
+			if caCert == nil || caCert.Certificate == nil {
+				continue
+			}
+
+			copied, err := parseCertificatePEM([]byte(*caCert.Certificate))
+			if err == nil && copied != nil && copied.Equal(certificate) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isConnectorChainCertificate checks if the CA certificate carries the name the connector assigns when installing a chain
+func isConnectorChainCertificate(cac *models.SSLKeyAndCertificate) bool {
+	if cac == nil || cac.Name == nil || cac.Type == nil || *cac.Type != SslCertificateTypeCA {
+		return false
+	}
+
+	if cac.Certificate == nil || cac.Certificate.Certificate == nil {
+		return false
+	}
+
+	certificate, err := parseCertificatePEM([]byte(*cac.Certificate.Certificate))
+	if err != nil || certificate == nil {
+		return false
+	}
+
+	name, err := getCertificateName(certificate, "")
+	if err != nil {
+		return false
+	}
+
+	return name == *cac.Name
+}
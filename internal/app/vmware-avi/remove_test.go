@@ -0,0 +1,485 @@
+package vmwareavi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRemove(t *testing.T) {
+	var err error
+
+	e := echo.New()
+
+	t.Parallel()
+
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&RemoveInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+			Binding: domain.Binding{
+				VirtualServiceName:      "vstest",
+				FallbackCertificateName: "fallback",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/removeinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		issuer, err := parseCertificatePEM([]byte(intermediateIssuerPem))
+		require.NoError(t, err)
+
+		caName, err := getCertificateName(issuer, "")
+		require.NoError(t, err)
+
+		caType := SslCertificateTypeCA
+		caUUID := "sslkeyandcertificate-ca"
+		caURL := "https://localhost/api/sslkeyandcertificate/" + caUUID + "#" + caName
+
+		kacn := "installation.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-leaf#" + kacn
+		fallbackName := "fallback"
+		fallbackURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-fallback#" + fallbackName
+
+		mockClientServices.EXPECT().
+			GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+			Return([]*models.SSLKeyAndCertificate{
+				{
+					CaCerts: []*models.CertificateAuthority{
+						{
+							CaRef: &caURL,
+							Name:  &caName,
+						},
+					},
+					Name: &kacn,
+					URL:  &kacURL,
+				},
+			}, nil)
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Eq(fallbackName), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{
+				Name: &fallbackName,
+				URL:  &fallbackURL,
+			}, nil)
+
+		mockClientServices.EXPECT().
+			GetAllVirtualServices(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+				vsn := "vstest"
+				vsUUID := "virtualservice-test"
+
+				vs := &models.VirtualService{
+					Name:                     &vsn,
+					SslKeyAndCertificateRefs: []string{kacURL},
+					UUID:                     &vsUUID,
+				}
+				return []*models.VirtualService{vs}, nil
+			})
+
+		mockClientServices.EXPECT().
+			UpdateVirtualService(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				require.NotNil(t, obj)
+				require.Equal(t, []string{fallbackURL}, obj.SslKeyAndCertificateRefs)
+				return obj, nil
+			})
+
+		// nothing references the certificate or its chain certificate
+		mockClientServices.EXPECT().
+			GetObjectCount(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, objectType string, params map[string]string, options ...session.ApiOptionsParams) (int, error) {
+				require.Contains(t, []string{"sslkeyandcertificate:sslkeyandcertificate-leaf", "sslkeyandcertificate:" + caUUID}, params["refers_to"])
+				return 0, nil
+			}).
+			Times(2 * len(certificateReferrerTypes))
+		mockClientServices.EXPECT().
+			GetAllPKIProfiles(gomock.Any(), gomock.Any()).
+			Return([]*models.PKIprofile{}, nil).
+			Times(1)
+
+		mockClientServices.EXPECT().
+			DeleteSSLKeyAndCertificate(gomock.Any(), gomock.Eq("sslkeyandcertificate-leaf")).
+			Return(nil)
+
+		caPem := intermediateIssuerPem
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByID(gomock.Any(), gomock.Eq(caUUID)).
+			Return(&models.SSLKeyAndCertificate{
+				Certificate: &models.SSLCertificate{
+					Certificate: &caPem,
+				},
+				Name: &caName,
+				Type: &caType,
+				URL:  &caURL,
+			}, nil)
+
+		mockClientServices.EXPECT().
+			DeleteSSLKeyAndCertificate(gomock.Any(), gomock.Eq(caUUID)).
+			Return(nil)
+
+		err = whService.HandleRemoveInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("success_in_use", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&RemoveInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/removeinstallationendpoint", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		kacn := "installation.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-leaf#" + kacn
+
+		mockClientServices.EXPECT().
+			GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+			Return([]*models.SSLKeyAndCertificate{{Name: &kacn, URL: &kacURL}}, nil)
+
+		// a pool still references the certificate
+		mockClientServices.EXPECT().
+			GetObjectCount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, objectType string, params map[string]string, options ...session.ApiOptionsParams) (int, error) {
+				if objectType == "pool" {
+					return 1, nil
+				}
+
+				return 0, nil
+			}).
+			Times(len(certificateReferrerTypes))
+
+		err = whService.HandleRemoveInstallationEndpoint(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("in_use_by_admin_objects", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&RemoveInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          DefaultTenantName,
+			},
+		})
+		require.NoError(t, err)
+
+		recorder, ctx := setupPost(e, "/v1/removeinstallationendpoint", bytes.NewReader(raw))
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		kacn := "installation.test.io"
+		kacURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-leaf#" + kacn
+		kacPem := intermediateIssuerPem
+
+		mockClientServices.EXPECT().
+			GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+			Return([]*models.SSLKeyAndCertificate{
+				{
+					Certificate: &models.SSLCertificate{Certificate: &kacPem},
+					Name:        &kacn,
+					URL:         &kacURL,
+				},
+			}, nil)
+
+		mockClientServices.EXPECT().
+			GetObjectCount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(0, nil).
+			Times(len(certificateReferrerTypes))
+
+		// a PKI profile holds a copy of the certificate, and the portal references it
+		mockClientServices.EXPECT().
+			GetAllPKIProfiles(gomock.Any(), gomock.Any()).
+			Return([]*models.PKIprofile{
+				{CaCerts: []*models.SSLCertificate{{Certificate: &kacPem}}},
+			}, nil).
+			Times(1)
+		mockClientServices.EXPECT().
+			GetSystemConfiguration(gomock.Any()).
+			Return(&models.SystemConfiguration{
+				PortalConfiguration: &models.PortalConfiguration{
+					SslkeyandcertificateRefs: []string{"https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-leaf"},
+				},
+			}, nil).
+			Times(1)
+
+		err = whService.HandleRemoveInstallationEndpoint(ctx)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("already_removed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&RemoveInstallationEndpointRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+			Keystore: domain.Keystore{
+				CertificateName: "installation.test.io",
+				Tenant:          "test",
+			},
+		})
+		require.NoError(t, err)
+
+		recorder, ctx := setupPost(e, "/v1/removeinstallationendpoint", bytes.NewReader(raw))
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		// the controller reports the missing certificate with the HTTP status
+		mockClientServices.EXPECT().
+			GetAllSSLKeysAndCertificates(gomock.Any(), gomock.Any()).
+			Return(nil, session.AviError{HttpStatusCode: http.StatusNotFound})
+
+		err = whService.HandleRemoveInstallationEndpoint(ctx)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
+
+func TestGetFallbackCertificateRef(t *testing.T) {
+	fallbackName := "fallback"
+	fallbackURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-fallback#" + fallbackName
+
+	client := &domain.Client{
+		Connection: &domain.Connection{
+			HostnameOrAddress: "localhost",
+			Port:              443,
+		},
+		Tenant: "test",
+	}
+
+	binding := &domain.Binding{
+		FallbackCertificateName: fallbackName,
+		Tenant:                  "other",
+		VirtualServiceName:      "vstest",
+	}
+
+	t.Run("binding_tenant", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		whService := NewWebhookService(mockClientServices, nil)
+
+		// the fallback certificate is read in the tenant of the virtual service
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Eq(fallbackName), gomock.Any(), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{Name: &fallbackName, URL: &fallbackURL}, nil)
+
+		ref, err := whService.getFallbackCertificateRef(client, binding)
+		require.NoError(t, err)
+		require.Equal(t, fallbackURL, *ref)
+	})
+
+	t.Run("shared_by_admin", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		whService := NewWebhookService(mockClientServices, nil)
+
+		gomock.InOrder(
+			mockClientServices.EXPECT().
+				GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Eq(fallbackName), gomock.Any(), gomock.Any()).
+				Return(nil, errors.New("No object of type sslkeyandcertificate with name fallback is found")),
+			mockClientServices.EXPECT().
+				GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Eq(fallbackName), gomock.Any(), gomock.Any()).
+				Return(&models.SSLKeyAndCertificate{Name: &fallbackName, URL: &fallbackURL}, nil),
+		)
+
+		// the admin certificate must be visible to the tenant of the virtual service
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByID(gomock.Any(), gomock.Eq("sslkeyandcertificate-fallback"), gomock.Any(), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{Name: &fallbackName, URL: &fallbackURL}, nil)
+
+		ref, err := whService.getFallbackCertificateRef(client, binding)
+		require.NoError(t, err)
+		require.Equal(t, fallbackURL, *ref)
+	})
+
+	t.Run("not_shared_by_admin", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		whService := NewWebhookService(mockClientServices, nil)
+
+		gomock.InOrder(
+			mockClientServices.EXPECT().
+				GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Eq(fallbackName), gomock.Any(), gomock.Any()).
+				Return(nil, errors.New("No object of type sslkeyandcertificate with name fallback is found")),
+			mockClientServices.EXPECT().
+				GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Eq(fallbackName), gomock.Any(), gomock.Any()).
+				Return(&models.SSLKeyAndCertificate{Name: &fallbackName, URL: &fallbackURL}, nil),
+		)
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByID(gomock.Any(), gomock.Eq("sslkeyandcertificate-fallback"), gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("not found"))
+
+		_, err := whService.getFallbackCertificateRef(client, binding)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `fallback certificate "fallback" of tenant "admin" is not visible to the tenant "other"`)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		whService := NewWebhookService(mockClientServices, nil)
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByName(gomock.Any(), gomock.Eq(fallbackName), gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("No object of type sslkeyandcertificate with name fallback is found")).
+			Times(2)
+
+		_, err := whService.getFallbackCertificateRef(client, binding)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `fallback certificate "fallback" is not visible to the tenant "other"`)
+	})
+}
@@ -0,0 +1,144 @@
+package vmwareavi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+// ListSnapshotsRequest contains the request details for listing the snapshots of a virtual service
+type ListSnapshotsRequest struct {
+	Connection         *domain.Connection `json:"connection"`
+	Tenant             string             `json:"tenant,omitempty"`
+	VirtualServiceName string             `json:"virtualServiceName"`
+}
+
+// ListSnapshotsResponse contains the response for a ListSnapshotsRequest
+type ListSnapshotsResponse struct {
+	Snapshots []*VirtualServiceSnapshot `json:"snapshots"`
+}
+
+// RollbackRequest contains the request details for restoring a snapshot of a virtual service
+type RollbackRequest struct {
+	Connection         *domain.Connection `json:"connection"`
+	Tenant             string             `json:"tenant,omitempty"`
+	VirtualServiceName string             `json:"virtualServiceName"`
+	// SnapshotID is the snapshot to restore, no value restores the newest snapshot
+	SnapshotID string `json:"snapshotId,omitempty"`
+}
+
+// RollbackResponse contains the response for a RollbackRequest
+type RollbackResponse struct {
+	Snapshot *VirtualServiceSnapshot `json:"snapshot"`
+}
+
+// HandleListSnapshots will return the snapshots of a virtual service, newest snapshot first
+func (svc *WebhookServiceImpl) HandleListSnapshots(c echo.Context) error {
+	req := ListSnapshotsRequest{}
+	if err := c.Bind(&req); err != nil {
+		zap.L().Error("invalid request, failed to unmarshall json", zap.Error(err))
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall json: %s", err.Error()))
+	}
+
+	client := svc.ClientServices.NewClient(req.Connection, req.Tenant)
+
+	snapshots, err := svc.snapshots.list(getSnapshotController(client), client.Tenant, req.VirtualServiceName)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, ListSnapshotsResponse{
+		Snapshots: snapshots,
+	})
+}
+
+// HandleRollback will attempt to restore the certificate references of a virtual service from a snapshot
+func (svc *WebhookServiceImpl) HandleRollback(c echo.Context) error {
+	req := RollbackRequest{}
+	if err := c.Bind(&req); err != nil {
+		zap.L().Error("invalid request, failed to unmarshall json", zap.Error(err))
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall json: %s", err.Error()))
+	}
+
+	var err error
+
+	client := svc.ClientServices.NewClient(req.Connection, req.Tenant)
+	err = svc.ClientServices.Connect(client)
+	defer func() {
+		svc.ClientServices.Close(client)
+	}()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zap.L().Info("rolling back virtual service on VMware NSX-ALB", zap.String("address", req.Connection.HostnameOrAddress), zap.Int("port", req.Connection.Port), zap.String("virtualService", req.VirtualServiceName))
+
+	var snapshot *VirtualServiceSnapshot
+	snapshot, err = svc.rollbackVirtualService(client, req.VirtualServiceName, req.SnapshotID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to roll back VMware NSX-ALB: %s", err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, RollbackResponse{
+		Snapshot: snapshot,
+	})
+}
+
+func (svc *WebhookServiceImpl) rollbackVirtualService(client *domain.Client, name, id string) (*VirtualServiceSnapshot, error) {
+	unlock, err := svc.lockVirtualService(client, "", name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var snapshot *VirtualServiceSnapshot
+	snapshot, err = svc.snapshots.get(getSnapshotController(client), client.Tenant, name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the snapshot is only restored when every certificate it references still exists
+	for _, ref := range snapshot.SslKeyAndCertificateRefs {
+		_, err = svc.ClientServices.GetSSLKeyAndCertificateByID(client, getUUIDFromRef(ref), session.SetParams(map[string]string{
+			"export_key": "false",
+		}))
+		if err != nil {
+			return nil, fmt.Errorf(`certificate "%s" of snapshot "%s" is no longer available: %w`, ref, snapshot.ID, err)
+		}
+	}
+
+	var vs *models.VirtualService
+	vs, err = svc.ClientServices.GetVirtualServiceByName(client, name)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to retrieve virtual service "%s": %w`, name, err)
+	}
+
+	if vs == nil {
+		return nil, fmt.Errorf(`failed to retrieve virtual service "%s": empty response`, name)
+	}
+
+	if len(snapshot.VirtualServiceUUID) > 0 && vs.UUID != nil && *vs.UUID != snapshot.VirtualServiceUUID {
+		zap.L().Warn("virtual service was recreated since the snapshot", zap.String("tenant", client.Tenant), zap.String("virtualService", name), zap.String("snapshot", snapshot.ID))
+	}
+
+	err = svc.snapshotVirtualService(client, "", name, vs.UUID, vs.SslKeyAndCertificateRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	vs.SslKeyAndCertificateRefs = snapshot.SslKeyAndCertificateRefs
+
+	_, err = svc.ClientServices.UpdateVirtualService(client, vs)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to update the virtual service "%s": %w`, name, err)
+	}
+
+	zap.L().Info("restored virtual service snapshot", zap.String("tenant", client.Tenant), zap.String("virtualService", name), zap.String("snapshot", snapshot.ID))
+	return snapshot, nil
+}
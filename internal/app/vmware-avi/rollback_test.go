@@ -0,0 +1,157 @@
+package vmwareavi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRollback(t *testing.T) {
+	var err error
+
+	e := echo.New()
+
+	t.Parallel()
+
+	connection := &domain.Connection{
+		HostnameOrAddress: "localhost",
+		Password:          "password",
+		Port:              443,
+		Username:          "user",
+	}
+
+	oldURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-old#old"
+	newURL := "https://localhost/api/sslkeyandcertificate/sslkeyandcertificate-new#new"
+
+	setup := func(t *testing.T, mockClientServices *mocks.MockClientServices) *WebhookServiceImpl {
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+		whService.snapshots = newSnapshotStore(t.TempDir())
+
+		err = whService.snapshots.save(&VirtualServiceSnapshot{
+			Controller:               "localhost:443",
+			Tenant:                   "test",
+			VirtualServiceName:       "vstest",
+			SslKeyAndCertificateRefs: []string{oldURL},
+		})
+		require.NoError(t, err)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     tenant,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		return whService
+	}
+
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		whService := setup(t, mockClientServices)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&RollbackRequest{
+			Connection:         connection,
+			Tenant:             "test",
+			VirtualServiceName: "vstest",
+		})
+		require.NoError(t, err)
+
+		recorder, ctx := setupPost(e, "/v1/rollback", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByID(gomock.Any(), gomock.Eq("sslkeyandcertificate-old"), gomock.Any()).
+			Return(&models.SSLKeyAndCertificate{URL: &oldURL}, nil)
+
+		mockClientServices.EXPECT().
+			GetVirtualServiceByName(gomock.Any(), gomock.Eq("vstest")).
+			DoAndReturn(func(client *domain.Client, name string, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				vsn := name
+				return &models.VirtualService{
+					Name:                     &vsn,
+					SslKeyAndCertificateRefs: []string{newURL},
+				}, nil
+			})
+
+		mockClientServices.EXPECT().
+			UpdateVirtualService(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(client *domain.Client, obj *models.VirtualService, options ...session.ApiOptionsParams) (*models.VirtualService, error) {
+				require.Equal(t, []string{oldURL}, obj.SslKeyAndCertificateRefs)
+				return obj, nil
+			})
+
+		err = whService.HandleRollback(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		var snapshots []*VirtualServiceSnapshot
+		snapshots, err = whService.snapshots.list("localhost:443", "test", "vstest")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 2)
+		require.Equal(t, []string{newURL}, snapshots[0].SslKeyAndCertificateRefs)
+	})
+
+	t.Run("certificate_removed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+		whService := setup(t, mockClientServices)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&RollbackRequest{
+			Connection:         connection,
+			Tenant:             "test",
+			VirtualServiceName: "vstest",
+		})
+		require.NoError(t, err)
+
+		recorder, ctx := setupPost(e, "/v1/rollback", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			GetSSLKeyAndCertificateByID(gomock.Any(), gomock.Eq("sslkeyandcertificate-old"), gomock.Any()).
+			Return(nil, errors.New("No object of type sslkeyandcertificate with uuid sslkeyandcertificate-old is found"))
+
+		err = whService.HandleRollback(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.Equal(t, http.StatusBadRequest, response.StatusCode)
+		require.Contains(t, recorder.Body.String(), "is no longer available")
+	})
+}
@@ -0,0 +1,214 @@
+package vmwareavi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+)
+
+// SnapshotDirectoryEnvironmentVariable names the environment variable with the directory, typically a mounted volume,
+// where the virtual service snapshots are stored. Snapshots are disabled when no directory is configured.
+const SnapshotDirectoryEnvironmentVariable = "VMWARE_AVI_SNAPSHOT_DIRECTORY"
+
+// ErrSnapshotsDisabled is returned when a snapshot is requested and no snapshot directory is configured
+var ErrSnapshotsDisabled = errors.New("virtual service snapshots are not enabled")
+
+// VirtualServiceSnapshot contains the certificate references of a virtual service before it was changed by the connector
+type VirtualServiceSnapshot struct {
+	ID                       string    `json:"id"`
+	Controller               string    `json:"controller"`
+	Tenant                   string    `json:"tenant"`
+	VirtualServiceName       string    `json:"virtualServiceName"`
+	VirtualServiceUUID       string    `json:"virtualServiceUuid,omitempty"`
+	SslKeyAndCertificateRefs []string  `json:"sslKeyAndCertificateRefs"`
+	Created                  time.Time `json:"created"`
+}
+
+// snapshotStore persists the snapshots of each virtual service in a JSON file, newest snapshot first
+type snapshotStore struct {
+	directory string
+	limit     int
+	mutex     sync.Mutex
+}
+
+// newSnapshotStore will return a store using the directory, or nil when the directory is empty
+func newSnapshotStore(directory string) *snapshotStore {
+	if len(directory) == 0 {
+		return nil
+	}
+
+	return &snapshotStore{
+		directory: directory,
+		limit:     MaxSnapshotsPerVirtualService,
+	}
+}
+
+func getSnapshotController(client *domain.Client) string {
+	return strings.ToLower(fmt.Sprintf("%s:%d", client.Connection.HostnameOrAddress, client.Connection.Port))
+}
+
+// path will return the file of the virtual service snapshots, the file name is a digest of the key since the
+// controller, tenant and virtual service names can contain characters that are not valid in a file name
+func (store *snapshotStore) path(controller, tenant, name string) string {
+	digest := sha256.Sum256([]byte(strings.ToLower(fmt.Sprintf("%s/%s/%s", controller, tenant, name))))
+	return filepath.Join(store.directory, hex.EncodeToString(digest[:])+".json")
+}
+
+// save will add the snapshot as the newest snapshot of the virtual service, dropping the oldest snapshots over the limit
+func (store *snapshotStore) save(snapshot *VirtualServiceSnapshot) error {
+	if store == nil {
+		return ErrSnapshotsDisabled
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	snapshots, err := store.read(snapshot.Controller, snapshot.Tenant, snapshot.VirtualServiceName)
+	if err != nil {
+		return err
+	}
+
+	if snapshot.Created.IsZero() {
+		snapshot.Created = time.Now().UTC()
+	}
+	if len(snapshot.ID) == 0 {
+		snapshot.ID = snapshot.Created.Format("20060102T150405.000000000Z")
+	}
+
+	snapshots = append([]*VirtualServiceSnapshot{snapshot}, snapshots...)
+	if len(snapshots) > store.limit {
+		snapshots = snapshots[:store.limit]
+	}
+
+	return store.write(snapshot.Controller, snapshot.Tenant, snapshot.VirtualServiceName, snapshots)
+}
+
+// list will return the snapshots of the virtual service, newest snapshot first
+func (store *snapshotStore) list(controller, tenant, name string) ([]*VirtualServiceSnapshot, error) {
+	if store == nil {
+		return nil, ErrSnapshotsDisabled
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.read(controller, tenant, name)
+}
+
+// get will return the snapshot of the virtual service with the ID, or the newest snapshot when no ID is supplied
+func (store *snapshotStore) get(controller, tenant, name, id string) (*VirtualServiceSnapshot, error) {
+	snapshots, err := store.list(controller, tenant, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snapshot := range snapshots {
+		if len(id) == 0 || snapshot.ID == id {
+			return snapshot, nil
+		}
+	}
+
+	if len(id) == 0 {
+		return nil, fmt.Errorf(`no snapshots of virtual service "%s"`, name)
+	}
+
+	return nil, fmt.Errorf(`no snapshot "%s" of virtual service "%s"`, id, name)
+}
+
+func (store *snapshotStore) read(controller, tenant, name string) ([]*VirtualServiceSnapshot, error) {
+	raw, err := os.ReadFile(store.path(controller, tenant, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*VirtualServiceSnapshot{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	var snapshots []*VirtualServiceSnapshot
+	err = json.Unmarshal(raw, &snapshots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// write will replace the snapshots file using a rename so a failure never leaves a partially written file
+func (store *snapshotStore) write(controller, tenant, name string, snapshots []*VirtualServiceSnapshot) error {
+	raw, err := json.Marshal(snapshots)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshots: %w", err)
+	}
+
+	err = os.MkdirAll(store.directory, 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	var file *os.File
+	file, err = os.CreateTemp(store.directory, "snapshot-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	_, err = file.Write(raw)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+
+	err = os.Rename(file.Name(), store.path(controller, tenant, name))
+	if err != nil {
+		return fmt.Errorf("failed to replace snapshot file: %w", err)
+	}
+
+	return nil
+}
+
+// snapshotVirtualService will store the current certificate references of the virtual service before the connector changes them.
+// Nothing is stored when snapshots are not enabled.
+func (svc *WebhookServiceImpl) snapshotVirtualService(client *domain.Client, tenant, name string, vsUUID *string, refs []string) error {
+	if svc.snapshots == nil {
+		return nil
+	}
+
+	if len(tenant) == 0 {
+		tenant = client.Tenant
+	}
+
+	snapshot := &VirtualServiceSnapshot{
+		Controller:               getSnapshotController(client),
+		Tenant:                   tenant,
+		VirtualServiceName:       name,
+		VirtualServiceUUID:       getString(vsUUID),
+		SslKeyAndCertificateRefs: append([]string{}, refs...),
+	}
+
+	err := svc.snapshots.save(snapshot)
+	if err != nil {
+		return fmt.Errorf(`failed to snapshot virtual service "%s": %w`, name, err)
+	}
+
+	zap.L().Info("saved virtual service snapshot", zap.String("tenant", tenant), zap.String("virtualService", name), zap.String("snapshot", snapshot.ID))
+	return nil
+}
@@ -0,0 +1,58 @@
+package vmwareavi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled", func(t *testing.T) {
+		store := newSnapshotStore("")
+		require.Nil(t, store)
+
+		err := store.save(&VirtualServiceSnapshot{})
+		require.ErrorIs(t, err, ErrSnapshotsDisabled)
+
+		_, err = store.list("localhost:443", "admin", "vstest")
+		require.ErrorIs(t, err, ErrSnapshotsDisabled)
+	})
+
+	t.Run("save_and_get", func(t *testing.T) {
+		store := newSnapshotStore(t.TempDir())
+		store.limit = 2
+
+		for _, ref := range []string{"first", "second", "third"} {
+			err := store.save(&VirtualServiceSnapshot{
+				Controller:               "localhost:443",
+				Tenant:                   "admin",
+				VirtualServiceName:       "vstest",
+				SslKeyAndCertificateRefs: []string{ref},
+			})
+			require.NoError(t, err)
+		}
+
+		snapshots, err := store.list("localhost:443", "admin", "vstest")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 2)
+		require.Equal(t, []string{"third"}, snapshots[0].SslKeyAndCertificateRefs)
+		require.Equal(t, []string{"second"}, snapshots[1].SslKeyAndCertificateRefs)
+
+		latest, err := store.get("localhost:443", "admin", "vstest", "")
+		require.NoError(t, err)
+		require.Equal(t, snapshots[0].ID, latest.ID)
+
+		chosen, err := store.get("localhost:443", "admin", "vstest", snapshots[1].ID)
+		require.NoError(t, err)
+		require.Equal(t, []string{"second"}, chosen.SslKeyAndCertificateRefs)
+
+		_, err = store.get("localhost:443", "admin", "vstest", "missing")
+		require.Error(t, err)
+
+		snapshots, err = store.list("localhost:443", "other", "vstest")
+		require.NoError(t, err)
+		require.Empty(t, snapshots)
+	})
+}
@@ -0,0 +1,136 @@
+package vmwareavi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	// SslProfileTypeApplication is the value for an SSL profile type that can be assigned to a virtual service
+	SslProfileTypeApplication = "SSL_PROFILE_TYPE_APPLICATION"
+)
+
+// legacySslVersions are the accepted SSL profile versions that are no longer considered secure
+var legacySslVersions = map[string]bool{
+	"SSL_VERSION_SSLV3":  true,
+	"SSL_VERSION_TLS1":   true,
+	"SSL_VERSION_TLS1_1": true,
+}
+
+// configureVirtualServiceSSL will ensure the virtual service has an SSL enabled service port, enabling the binding
+// service port when requested, and assign the binding SSL profile. A profile accepting a legacy protocol version fails
+// the binding, the legacy profile of the virtual service must be replaced by the binding SSL profile.
+func (svc *WebhookServiceImpl) configureVirtualServiceSSL(client *domain.Client, binding *domain.Binding, vs *models.VirtualService, options ...session.ApiOptionsParams) error {
+	if binding.ServicePort > 0 {
+		service := findServicePort(vs, binding.ServicePort)
+		if service == nil {
+			return fmt.Errorf("no service port %d", binding.ServicePort)
+		}
+
+		if service.EnableSsl == nil || !*service.EnableSsl {
+			zap.L().Info("enabling SSL on virtual service port", zap.String("tenant", client.Tenant), zap.String("virtualService", binding.VirtualServiceName), zap.Int("servicePort", binding.ServicePort))
+
+			enabled := true
+			service.EnableSsl = &enabled
+		}
+	} else if !hasSslServicePort(vs) {
+		return errors.New("no SSL enabled service port")
+	}
+
+	if len(binding.SslProfileName) == 0 {
+		return svc.checkVirtualServiceSSLProfile(client, vs, options...)
+	}
+
+	profile, err := svc.ClientServices.GetSSLProfileByName(client, binding.SslProfileName, options...)
+	if err != nil {
+		return fmt.Errorf(`failed to retrieve SSL profile "%s": %w`, binding.SslProfileName, err)
+	}
+
+	if profile == nil || profile.URL == nil || len(*profile.URL) == 0 {
+		return fmt.Errorf(`invalid SSL profile "%s": no assigned UUID`, binding.SslProfileName)
+	}
+
+	if profile.Type != nil && *profile.Type != SslProfileTypeApplication {
+		return fmt.Errorf(`SSL profile "%s" of type %s cannot be assigned to a virtual service`, binding.SslProfileName, *profile.Type)
+	}
+
+	if version := getLegacySslVersion(profile); len(version) > 0 {
+		return fmt.Errorf(`SSL profile "%s" accepts the legacy protocol version %s`, binding.SslProfileName, version)
+	}
+
+	vs.SslProfileRef = profile.URL
+	return nil
+}
+
+// checkVirtualServiceSSLProfile will fail when the SSL profile of the virtual service accepts a legacy protocol version
+func (svc *WebhookServiceImpl) checkVirtualServiceSSLProfile(client *domain.Client, vs *models.VirtualService, options ...session.ApiOptionsParams) error {
+	if vs.SslProfileRef == nil || len(*vs.SslProfileRef) == 0 {
+		return nil
+	}
+
+	name := GetNameFromRef(vs.SslProfileRef)
+
+	profile, err := svc.ClientServices.GetSSLProfile(client, getUUIDFromRef(*vs.SslProfileRef), options...)
+	if err != nil {
+		return fmt.Errorf(`failed to retrieve SSL profile "%s": %w`, name, err)
+	}
+
+	if version := getLegacySslVersion(profile); len(version) > 0 {
+		zap.L().Info("virtual service SSL profile accepts a legacy protocol version", zap.String("tenant", client.Tenant), zap.String("virtualService", getValue(vs.Name)), zap.String("sslProfile", name), zap.String("version", version))
+		return fmt.Errorf(`SSL profile "%s" accepts the legacy protocol version %s, set sslProfileName to replace it`, name, version)
+	}
+
+	return nil
+}
+
+// getLegacySslVersion will return the first legacy protocol version accepted by the SSL profile
+func getLegacySslVersion(profile *models.SSLProfile) string {
+	if profile == nil {
+		return ""
+	}
+
+	for _, version := range profile.AcceptedVersions {
+		if version != nil && version.Type != nil && legacySslVersions[*version.Type] {
+			return *version.Type
+		}
+	}
+
+	return ""
+}
+
+// findServicePort will return the virtual service service that includes the port
+func findServicePort(vs *models.VirtualService, port int) *models.Service {
+	for _, service := range vs.Services {
+		if service == nil || service.Port == nil {
+			continue
+		}
+
+		start := int(*service.Port)
+		end := int(service.PortRangeEnd)
+		if end < start {
+			end = start
+		}
+
+		if port >= start && port <= end {
+			return service
+		}
+	}
+
+	return nil
+}
+
+func hasSslServicePort(vs *models.VirtualService) bool {
+	for _, service := range vs.Services {
+		if service != nil && service.EnableSsl != nil && *service.EnableSsl {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,192 @@
+package vmwareavi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"go.uber.org/zap"
+)
+
+// GetTargetConfigurationRequest contains the request details for retrieving VMware AVI host configuration information
+type GetTargetConfigurationRequest struct {
+	Connection *domain.Connection `json:"connection"`
+}
+
+// GetTargetConfigurationResponse contains the response for a GetTargetConfigurationRequest
+type GetTargetConfigurationResponse struct {
+	TargetConfiguration TargetConfiguration `json:"targetConfiguration"`
+}
+
+// HandleGetTargetConfiguration will attempt to retrieve the configuration information of the VMware AVI host
+func (svc *WebhookServiceImpl) HandleGetTargetConfiguration(c echo.Context) error {
+	req := GetTargetConfigurationRequest{}
+	if err := c.Bind(&req); err != nil {
+		zap.L().Error("invalid request, failed to unmarshall json", zap.Error(err))
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to unmarshall json: %s", err.Error()))
+	}
+
+	var err error
+
+	client := svc.ClientServices.NewClient(req.Connection, "")
+	err = svc.ClientServices.Connect(client)
+	defer func() {
+		svc.ClientServices.Close(client)
+	}()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zap.L().Info("retrieving target configuration of VMware NSX-ALB", zap.String("address", req.Connection.HostnameOrAddress), zap.Int("port", req.Connection.Port))
+
+	res := GetTargetConfigurationResponse{}
+
+	err = svc.getTargetConfiguration(client, &res.TargetConfiguration)
+	if err != nil {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("failed to retrieve the VMware NSX-ALB configuration: %s", err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (svc *WebhookServiceImpl) getTargetConfiguration(client *domain.Client, tc *TargetConfiguration) error {
+	var err error
+
+	tc.ControllerVersion, err = svc.ClientServices.GetControllerVersion(client)
+	if err != nil {
+		return fmt.Errorf("failed to read the controller version: %w", err)
+	}
+
+	var cluster *domain.Cluster
+	cluster, err = svc.ClientServices.GetCluster(client)
+	if err != nil {
+		return fmt.Errorf("failed to read the controller cluster: %w", err)
+	}
+
+	tc.ClusterNodes = []*ClusterNode{}
+	if cluster != nil {
+		tc.ClusterName = getString(cluster.Name)
+		for _, node := range cluster.Nodes {
+			if node == nil {
+				continue
+			}
+
+			tc.ClusterNodes = append(tc.ClusterNodes, &ClusterNode{
+				Name:      getString(node.Name),
+				IPAddress: getClusterNodeAddress(node),
+			})
+		}
+	}
+
+	var systemConfiguration *models.SystemConfiguration
+	systemConfiguration, err = svc.ClientServices.GetSystemConfiguration(client)
+	if err != nil {
+		return fmt.Errorf("failed to read the system configuration: %w", err)
+	}
+
+	if systemConfiguration != nil {
+		tc.LicenseTier = getString(systemConfiguration.DefaultLicenseTier)
+	}
+
+	var clouds []*models.Cloud
+	clouds, err = GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.Cloud, error) {
+		return svc.ClientServices.GetAllClouds(client, options...)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read the clouds: %w", err)
+	}
+
+	tc.Clouds = make([]*Cloud, 0, len(clouds))
+	for _, cloud := range clouds {
+		if cloud == nil {
+			continue
+		}
+
+		tc.Clouds = append(tc.Clouds, &Cloud{
+			Name:        getString(cloud.Name),
+			Type:        getString(cloud.Vtype),
+			LicenseTier: getString(cloud.LicenseTier),
+		})
+	}
+
+	var seGroups []*models.ServiceEngineGroup
+	seGroups, err = GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.ServiceEngineGroup, error) {
+		return svc.ClientServices.GetAllServiceEngineGroups(client, options...)
+	}, map[string]string{
+		"include_name": "true",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read the service engine groups: %w", err)
+	}
+
+	tc.ServiceEngineGroups = make([]*ServiceEngineGroup, 0, len(seGroups))
+	for _, seGroup := range seGroups {
+		if seGroup == nil {
+			continue
+		}
+
+		tc.ServiceEngineGroups = append(tc.ServiceEngineGroups, &ServiceEngineGroup{
+			Name:  getString(seGroup.Name),
+			Cloud: GetNameFromRef(seGroup.CloudRef),
+		})
+	}
+
+	tc.Tenants, err = svc.getTenantInventories(client)
+	return err
+}
+
+// getTenantInventories will return the number of virtual services and certificates of each tenant, sorted by tenant name
+func (svc *WebhookServiceImpl) getTenantInventories(client *domain.Client) ([]*TenantInventory, error) {
+	tenants, err := GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.Tenant, error) {
+		return svc.ClientServices.GetAllTenants(client, options...)
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the tenants: %w", err)
+	}
+
+	inventories := make([]*TenantInventory, 0, len(tenants))
+	for _, tenant := range tenants {
+		if tenant == nil || tenant.Name == nil {
+			continue
+		}
+
+		inventory := &TenantInventory{
+			Name: *tenant.Name,
+		}
+
+		options := session.SetOptTenant(*tenant.Name)
+
+		inventory.VirtualServices, err = svc.ClientServices.GetObjectCount(client, "virtualservice", nil, options)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to count the virtual services of the tenant "%s": %w`, *tenant.Name, err)
+		}
+
+		inventory.Certificates, err = svc.ClientServices.GetObjectCount(client, "sslkeyandcertificate", nil, options)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to count the certificates of the tenant "%s": %w`, *tenant.Name, err)
+		}
+
+		inventories = append(inventories, inventory)
+	}
+
+	sort.Slice(inventories, func(i, j int) bool {
+		return inventories[i].Name < inventories[j].Name
+	})
+
+	return inventories, nil
+}
+
+func getClusterNodeAddress(node *models.ClusterNode) string {
+	for _, addr := range []*models.IPAddr{node.IP, node.Ip6} {
+		if addr != nil && addr.Addr != nil && len(*addr.Addr) > 0 {
+			return *addr.Addr
+		}
+	}
+
+	return getString(node.VMHostname)
+}
@@ -0,0 +1,272 @@
+package vmwareavi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
+	"github.com/venafi/vmware-avi-connector/internal/app/vmware-avi/mocks"
+	"github.com/vmware/alb-sdk/go/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetTargetConfiguration(t *testing.T) {
+	var err error
+
+	e := echo.New()
+
+	t.Parallel()
+
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&GetTargetConfigurationRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/gettargetconfiguration", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     DefaultTenantName,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		mockClientServices.EXPECT().
+			GetControllerVersion(gomock.Any()).
+			Return("22.1.5", nil)
+
+		clusterName := "cluster-0-1"
+		nodeName := "node-1"
+		nodeAddr := "10.0.0.10"
+		mockClientServices.EXPECT().
+			GetCluster(gomock.Any()).
+			Return(&domain.Cluster{
+				Name: &clusterName,
+				Nodes: []*models.ClusterNode{
+					{
+						IP:   &models.IPAddr{Addr: &nodeAddr},
+						Name: &nodeName,
+					},
+				},
+			}, nil)
+
+		licenseTier := "ENTERPRISE"
+		mockClientServices.EXPECT().
+			GetSystemConfiguration(gomock.Any()).
+			Return(&models.SystemConfiguration{
+				DefaultLicenseTier: &licenseTier,
+			}, nil)
+
+		cloudName := "Default-Cloud"
+		cloudType := "CLOUD_VCENTER"
+		mockClientServices.EXPECT().
+			GetAllClouds(gomock.Any(), gomock.Any()).
+			Return([]*models.Cloud{
+				{
+					Name:  &cloudName,
+					Vtype: &cloudType,
+				},
+			}, nil)
+
+		seGroupName := "Default-Group"
+		cloudRef := "https://localhost/api/cloud/cloud-test#" + cloudName
+		mockClientServices.EXPECT().
+			GetAllServiceEngineGroups(gomock.Any(), gomock.Any()).
+			Return([]*models.ServiceEngineGroup{
+				{
+					CloudRef: &cloudRef,
+					Name:     &seGroupName,
+				},
+			}, nil)
+
+		admin := DefaultTenantName
+		other := "test"
+		mockClientServices.EXPECT().
+			GetAllTenants(gomock.Any(), gomock.Any()).
+			Return([]*models.Tenant{{Name: &other}, {Name: &admin}}, nil)
+
+		mockClientServices.EXPECT().
+			GetObjectCount(gomock.Any(), gomock.Eq("virtualservice"), gomock.Nil(), gomock.Any()).
+			Return(3, nil).
+			Times(2)
+		mockClientServices.EXPECT().
+			GetObjectCount(gomock.Any(), gomock.Eq("sslkeyandcertificate"), gomock.Nil(), gomock.Any()).
+			Return(7, nil).
+			Times(2)
+
+		err = whService.HandleGetTargetConfiguration(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		raw, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		res := GetTargetConfigurationResponse{}
+		err = json.Unmarshal(raw, &res)
+		require.NoError(t, err)
+
+		tc := res.TargetConfiguration
+		require.Equal(t, "22.1.5", tc.ControllerVersion)
+		require.Equal(t, clusterName, tc.ClusterName)
+		require.Equal(t, []*ClusterNode{{Name: nodeName, IPAddress: nodeAddr}}, tc.ClusterNodes)
+		require.Equal(t, licenseTier, tc.LicenseTier)
+		require.Equal(t, []*Cloud{{Name: cloudName, Type: cloudType}}, tc.Clouds)
+		require.Equal(t, []*ServiceEngineGroup{{Name: seGroupName, Cloud: cloudName}}, tc.ServiceEngineGroups)
+		require.Len(t, tc.Tenants, 2)
+		require.Equal(t, DefaultTenantName, tc.Tenants[0].Name)
+		require.Equal(t, other, tc.Tenants[1].Name)
+		require.Equal(t, 3, tc.Tenants[1].VirtualServices)
+		require.Equal(t, 7, tc.Tenants[1].Certificates)
+	})
+	t.Run("success_paged", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockClientServices := mocks.NewMockClientServices(ctrl)
+
+		whService := NewWebhookService(mockClientServices, nil)
+		require.NotNil(t, whService)
+
+		var raw []byte
+
+		raw, err = json.Marshal(&GetTargetConfigurationRequest{
+			Connection: &domain.Connection{
+				HostnameOrAddress: "localhost",
+				Password:          "password",
+				Port:              443,
+				Username:          "user",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, raw)
+
+		recorder, ctx := setupPost(e, "/v1/gettargetconfiguration", bytes.NewReader(raw))
+		require.NotNil(t, ctx)
+		require.NotNil(t, recorder)
+
+		mockClientServices.EXPECT().
+			NewClient(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(connection *domain.Connection, tenant string) *domain.Client {
+				return &domain.Client{
+					Connection: connection,
+					Tenant:     DefaultTenantName,
+				}
+			})
+		mockClientServices.EXPECT().
+			Connect(gomock.Any()).
+			Return(nil)
+		mockClientServices.EXPECT().
+			Close(gomock.Any())
+
+		mockClientServices.EXPECT().
+			GetControllerVersion(gomock.Any()).
+			Return("22.1.5", nil)
+		mockClientServices.EXPECT().
+			GetCluster(gomock.Any()).
+			Return(nil, nil)
+		mockClientServices.EXPECT().
+			GetSystemConfiguration(gomock.Any()).
+			Return(nil, nil)
+
+		// a full first page is followed by a partial second page
+		clouds := make([]*models.Cloud, 0, DefaultPageSize+1)
+		seGroups := make([]*models.ServiceEngineGroup, 0, DefaultPageSize+1)
+		tenants := make([]*models.Tenant, 0, DefaultPageSize+1)
+		for idx := 0; idx <= DefaultPageSize; idx++ {
+			name := fmt.Sprintf("item-%02d", idx)
+			clouds = append(clouds, &models.Cloud{Name: &name})
+			seGroups = append(seGroups, &models.ServiceEngineGroup{Name: &name})
+			tenants = append(tenants, &models.Tenant{Name: &name})
+		}
+
+		gomock.InOrder(
+			mockClientServices.EXPECT().
+				GetAllClouds(gomock.Any(), gomock.Any()).
+				Return(clouds[:DefaultPageSize], nil),
+			mockClientServices.EXPECT().
+				GetAllClouds(gomock.Any(), gomock.Any()).
+				Return(clouds[DefaultPageSize:], nil),
+		)
+
+		gomock.InOrder(
+			mockClientServices.EXPECT().
+				GetAllServiceEngineGroups(gomock.Any(), gomock.Any()).
+				Return(seGroups[:DefaultPageSize], nil),
+			mockClientServices.EXPECT().
+				GetAllServiceEngineGroups(gomock.Any(), gomock.Any()).
+				Return(seGroups[DefaultPageSize:], nil),
+		)
+
+		gomock.InOrder(
+			mockClientServices.EXPECT().
+				GetAllTenants(gomock.Any(), gomock.Any()).
+				Return(tenants[:DefaultPageSize], nil),
+			mockClientServices.EXPECT().
+				GetAllTenants(gomock.Any(), gomock.Any()).
+				Return(tenants[DefaultPageSize:], nil),
+		)
+
+		mockClientServices.EXPECT().
+			GetObjectCount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(1, nil).
+			Times(2 * (DefaultPageSize + 1))
+
+		err = whService.HandleGetTargetConfiguration(ctx)
+		require.NoError(t, err)
+
+		response := recorder.Result() // nolint:bodyclose
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		require.NotNil(t, response)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		res := GetTargetConfigurationResponse{}
+		err = json.Unmarshal(recorder.Body.Bytes(), &res)
+		require.NoError(t, err)
+
+		tc := res.TargetConfiguration
+		require.Len(t, tc.Clouds, DefaultPageSize+1)
+		require.Len(t, tc.ServiceEngineGroups, DefaultPageSize+1)
+		require.Len(t, tc.Tenants, DefaultPageSize+1)
+		require.Equal(t, fmt.Sprintf("item-%02d", DefaultPageSize), tc.Tenants[DefaultPageSize].Name)
+	})
+}
@@ -1,10 +1,72 @@
 package vmwareavi
 
+import "time"
+
 const (
+	// DefaultBindingConcurrency is the maximum number of virtual services updated in parallel during a bulk binding
+	DefaultBindingConcurrency = 5
+	// DefaultHealthCheckInterval is the time between virtual service health checks after binding a certificate
+	DefaultHealthCheckInterval = 5 * time.Second
+	// DefaultHealthCheckTimeout is the time allowed for a virtual service health check to succeed after binding a certificate
+	DefaultHealthCheckTimeout = 60 * time.Second
+	// DefaultPageSize is the number of results per paged collection request to VMware
+	DefaultPageSize = 50
+	// DefaultVirtualServiceLockTimeout is the time a request waits for another request updating the same virtual service
+	DefaultVirtualServiceLockTimeout = 2 * time.Minute
+	// MaxSnapshotsPerVirtualService is the number of snapshots kept for each virtual service
+	MaxSnapshotsPerVirtualService = 10
+	// MaxLookupPageSize is the maximum number of results per page returned by the lookup operations
+	MaxLookupPageSize = 200
 	// DefaultTenantName represents the default tenant
 	DefaultTenantName = "admin"
+	// WildcardTenantName represents all tenants visible to the session user
+	WildcardTenantName = "*"
+	// VirtualServiceTypeParent is the type of the parent of SNI child virtual services
+	VirtualServiceTypeParent = "VS_TYPE_VH_PARENT"
+	// VirtualServiceTypeChild is the type of an SNI child virtual service, selected by the parent for its domain names
+	VirtualServiceTypeChild = "VS_TYPE_VH_CHILD"
 )
 
 // TargetConfiguration contains the details of a VMware AVI host configuration
 type TargetConfiguration struct {
+	// ControllerVersion is the version of the VMware AVI controller
+	ControllerVersion string `json:"controllerVersion"`
+	// ClusterName is the name of the controller cluster
+	ClusterName string `json:"clusterName"`
+	// ClusterNodes is the collection of controller cluster nodes
+	ClusterNodes []*ClusterNode `json:"clusterNodes"`
+	// LicenseTier is the default license tier of the controller
+	LicenseTier string `json:"licenseTier"`
+	// Clouds is the collection of clouds configured on the controller
+	Clouds []*Cloud `json:"clouds"`
+	// ServiceEngineGroups is the collection of service engine groups configured on the controller
+	ServiceEngineGroups []*ServiceEngineGroup `json:"serviceEngineGroups"`
+	// Tenants is the collection of tenants with their inventory counts
+	Tenants []*TenantInventory `json:"tenants"`
+}
+
+// ClusterNode contains the details of a controller cluster node
+type ClusterNode struct {
+	Name      string `json:"name"`
+	IPAddress string `json:"ipAddress"`
+}
+
+// Cloud contains the details of a cloud configured on the controller
+type Cloud struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	LicenseTier string `json:"licenseTier,omitempty"`
+}
+
+// ServiceEngineGroup contains the details of a service engine group configured on the controller
+type ServiceEngineGroup struct {
+	Name  string `json:"name"`
+	Cloud string `json:"cloud"`
+}
+
+// TenantInventory contains the number of virtual services and certificates of a tenant
+type TenantInventory struct {
+	Name            string `json:"name"`
+	VirtualServices int    `json:"virtualServices"`
+	Certificates    int    `json:"certificates"`
 }
@@ -3,10 +3,18 @@ package vmwareavi
 import (
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
+
+	"github.com/venafi/vmware-avi-connector/internal/app/domain"
 )
 
 func getCertificateName(certificate *x509.Certificate, baseName string) (string, error) {
@@ -74,3 +82,139 @@ func parseCertificatePEM(content []byte) (*x509.Certificate, error) {
 
 	return nil, nil
 }
+
+// getAllVirtualServices will read every page of virtual services matching the supplied query parameters
+func getAllVirtualServices(clientServices ClientServices, client *domain.Client, params map[string]string, options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+	return GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.VirtualService, error) {
+		return clientServices.GetAllVirtualServices(client, options...)
+	}, params, options...)
+}
+
+// GetAllPages will read every page of a collection matching the supplied query parameters, since a collection request
+// only returns the first page. The pages hold DefaultPageSize objects unless the query parameters set the page_size,
+// and the null objects of a page are skipped.
+func GetAllPages[T any](read func(options ...session.ApiOptionsParams) ([]*T, error), params map[string]string, options ...session.ApiOptionsParams) ([]*T, error) {
+	pageSize := DefaultPageSize
+	if value, err := strconv.Atoi(params["page_size"]); err == nil && value > 0 {
+		pageSize = value
+	}
+
+	results := make([]*T, 0)
+
+	for page := 1; ; page++ {
+		query := map[string]string{
+			"page":      strconv.Itoa(page),
+			"page_size": strconv.Itoa(pageSize),
+		}
+		for key, value := range params {
+			query[key] = value
+		}
+
+		collection, err := read(append([]session.ApiOptionsParams{session.SetParams(query)}, options...)...)
+		if err != nil {
+			if IsNoResultsPage(err) {
+				break
+			}
+
+			return nil, err
+		}
+
+		for _, object := range collection {
+			if object != nil {
+				results = append(results, object)
+			}
+		}
+
+		if len(collection) < pageSize {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// IsNoResultsPage checks if the error is the VMware response for a page beyond the last page of a collection
+func IsNoResultsPage(err error) bool {
+	var ae session.AviError
+	if !errors.As(err, &ae) || ae.AviResult.Message == nil {
+		return false
+	}
+
+	return strings.Contains(*ae.AviResult.Message, "That page contains no results")
+}
+
+// isNotFound checks if the error is the VMware response for an object that does not exist
+func isNotFound(err error) bool {
+	var ae session.AviError
+	return errors.As(err, &ae) && ae.HttpStatusCode == http.StatusNotFound
+}
+
+func containsRef(refs []string, ref string) bool {
+	id := getUUIDFromRef(ref)
+	for _, value := range refs {
+		if getUUIDFromRef(value) == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getUUIDFromRef will return the UUID from an object reference such as https://host/api/sslkeyandcertificate/<uuid>#<name>
+func getUUIDFromRef(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if idx := strings.Index(ref, "#"); idx >= 0 {
+		ref = ref[:idx]
+	}
+	if idx := strings.Index(ref, "?"); idx >= 0 {
+		ref = ref[:idx]
+	}
+
+	ref = strings.TrimSuffix(ref, "/")
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// GetNameFromRef will return the object name from an object reference that includes the name, such as
+// https://host/api/cloud/<uuid>#<name>
+func GetNameFromRef(ref *string) string {
+	if ref == nil {
+		return ""
+	}
+
+	idx := strings.LastIndex(*ref, "#")
+	if idx < 0 {
+		return getUUIDFromRef(*ref)
+	}
+
+	return (*ref)[idx+1:]
+}
+
+// getTenantOptions will return the options scoping a request to the tenant when it differs from the tenant of the client session
+func getTenantOptions(client *domain.Client, tenant string) []session.ApiOptionsParams {
+	if len(tenant) == 0 || strings.EqualFold(tenant, client.Tenant) {
+		return nil
+	}
+
+	return []session.ApiOptionsParams{session.SetOptTenant(tenant)}
+}
+
+// getString will return the value, or an empty string when the value is not set
+func getString(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
+
+func getValue(value *string) string {
+	if value == nil {
+		return "nil"
+	}
+
+	if len(*value) == 0 {
+		return "empty"
+	}
+
+	return *value
+}
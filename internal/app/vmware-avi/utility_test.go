@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
 )
 
 func TestParseCertificateDer(t *testing.T) {
@@ -24,6 +26,53 @@ func TestParseCertificateDer(t *testing.T) {
 	})
 }
 
+func TestGetNameFromRef(t *testing.T) {
+	require.Equal(t, "", GetNameFromRef(nil))
+
+	value := "https://localhost/api/cloud/cloud-test"
+	require.Equal(t, "cloud-test", GetNameFromRef(&value))
+
+	value += "#Default-Cloud"
+	require.Equal(t, "Default-Cloud", GetNameFromRef(&value))
+}
+
+func TestGetAllPages(t *testing.T) {
+	t.Run("page_size", func(t *testing.T) {
+		// the page size of the query parameters ends the read at the first short page
+		pages := [][]*models.Pool{{{}, nil}, {{}, {}}, {{}}}
+
+		reads := 0
+		pools, err := GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.Pool, error) {
+			reads++
+			return pages[reads-1], nil
+		}, map[string]string{"page_size": "2"})
+		require.NoError(t, err)
+		require.Equal(t, 3, reads)
+		require.Equal(t, 4, len(pools))
+	})
+
+	t.Run("no_results_page", func(t *testing.T) {
+		page := make([]*models.Pool, DefaultPageSize)
+		for idx := range page {
+			page[idx] = &models.Pool{}
+		}
+
+		reads := 0
+		pools, err := GetAllPages(func(options ...session.ApiOptionsParams) ([]*models.Pool, error) {
+			reads++
+			if reads > 1 {
+				message := "That page contains no results"
+				return nil, session.AviError{AviResult: session.AviResult{Message: &message}}
+			}
+
+			return page, nil
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 2, reads)
+		require.Equal(t, DefaultPageSize, len(pools))
+	})
+}
+
 func TestGetCertificateName(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		certificate, err := parseCertificateDER(certificateDer)
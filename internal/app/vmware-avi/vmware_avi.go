@@ -1,24 +1,37 @@
 package vmwareavi
 
 import (
+	"os"
+	"time"
+
 	"github.com/labstack/echo/v4"
 )
 
 // DiscoveryService interfaces for connector discovery functions
 type DiscoveryService interface {
 	DiscoverCertificates(c echo.Context) error
+	DiscoverCertificatesStream(c echo.Context) error
 }
 
 // WebhookServiceImpl implementation of DiscoveryService
 type WebhookServiceImpl struct {
 	ClientServices ClientServices
 	Discovery      DiscoveryService
+
+	healthCheckInterval       time.Duration
+	snapshots                 *snapshotStore
+	virtualServiceLocks       *keyedLock
+	virtualServiceLockTimeout time.Duration
 }
 
 // NewWebhookService will return a new WebhookServiceImpl
 func NewWebhookService(clientServices ClientServices, discovery DiscoveryService) *WebhookServiceImpl {
 	return &WebhookServiceImpl{
-		ClientServices: clientServices,
-		Discovery:      discovery,
+		ClientServices:            clientServices,
+		Discovery:                 discovery,
+		healthCheckInterval:       DefaultHealthCheckInterval,
+		snapshots:                 newSnapshotStore(os.Getenv(SnapshotDirectoryEnvironmentVariable)),
+		virtualServiceLocks:       newKeyedLock(),
+		virtualServiceLockTimeout: DefaultVirtualServiceLockTimeout,
 	}
 }
@@ -21,8 +21,14 @@ import (
 type WebhookService interface {
 	HandleConfigureInstallationEndpoint(c echo.Context) error
 	HandleDiscoverCertificates(c echo.Context) error
+	HandleDiscoverCertificatesStream(c echo.Context) error
 	HandleGetTargetConfiguration(c echo.Context) error
 	HandleInstallCertificateBundle(c echo.Context) error
+	HandleListSnapshots(c echo.Context) error
+	HandleListTenants(c echo.Context) error
+	HandleListVirtualServices(c echo.Context) error
+	HandleRemoveInstallationEndpoint(c echo.Context) error
+	HandleRollback(c echo.Context) error
 	HandleTestConnection(c echo.Context) error
 }
 
@@ -65,7 +71,13 @@ func RegisterHandlers(e *echo.Echo, whService WebhookService) error {
 	g.POST("/gettargetconfiguration", whService.HandleGetTargetConfiguration)
 	g.POST("/configureinstallationendpoint", whService.HandleConfigureInstallationEndpoint)
 	g.POST("/installcertificatebundle", whService.HandleInstallCertificateBundle)
+	g.POST("/removeinstallationendpoint", whService.HandleRemoveInstallationEndpoint)
 	g.POST("/discovercertificates", whService.HandleDiscoverCertificates)
+	g.POST("/discovercertificatesstream", whService.HandleDiscoverCertificatesStream)
+	g.POST("/listtenants", whService.HandleListTenants)
+	g.POST("/listvirtualservices", whService.HandleListVirtualServices)
+	g.POST("/listsnapshots", whService.HandleListSnapshots)
+	g.POST("/rollback", whService.HandleRollback)
 
 	return nil
 }